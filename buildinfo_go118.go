@@ -0,0 +1,27 @@
+//go:build go1.18
+// +build go1.18
+
+package errors
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// mainPackagePath returns the import path of the main package. Test binaries have a ".test" suffix
+// on the end of their path, which isn't part of the import path.
+func mainPackagePath(info *debug.BuildInfo) string {
+	return strings.TrimSuffix(info.Path, ".test")
+}
+
+// vcsRevision returns the version control revision the binary was built from, if it was stamped
+// into the binary when it was built.
+func vcsRevision(info *debug.BuildInfo) string {
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+
+	return ""
+}
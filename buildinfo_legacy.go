@@ -0,0 +1,18 @@
+//go:build !go1.18
+// +build !go1.18
+
+package errors
+
+import "runtime/debug"
+
+// mainPackagePath returns the import path of the main package. Before Go 1.18, this isn't included
+// in the build information, so callers must fall back to something else.
+func mainPackagePath(info *debug.BuildInfo) string {
+	return ""
+}
+
+// vcsRevision returns the version control revision the binary was built from. Before Go 1.18, this
+// isn't included in the build information, so it must be set using SetSourceRevision.
+func vcsRevision(info *debug.BuildInfo) string {
+	return ""
+}
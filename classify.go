@@ -0,0 +1,170 @@
+package errors
+
+import (
+	"os"
+	"reflect"
+	"sync"
+)
+
+// Classifier decides which Kind an error should have. It returns false if it doesn't know.
+type Classifier func(err error) (Kind, bool)
+
+// classifiers holds the rules used by Classify. Custom rules are tried before default ones, so that
+// services can override the default taxonomy.
+var classifiers struct {
+	sync.RWMutex
+	custom   []Classifier
+	defaults []Classifier
+}
+
+// RegisterClassifier adds a custom rule used by Classify. Custom rules are tried in the order that
+// they were registered, before any of the default rules.
+func RegisterClassifier(c Classifier) {
+	classifiers.Lock()
+	classifiers.custom = append(classifiers.custom, c)
+	classifiers.Unlock()
+}
+
+// RegisterDefaultClassifier adds a default rule used by Classify. Default rules are tried in the
+// order that they were registered, after all of the custom rules, however late those are registered.
+// This is how the kinds package provides the standard rules for errors from the standard library,
+// without stopping services from overriding them.
+func RegisterDefaultClassifier(c Classifier) {
+	classifiers.Lock()
+	classifiers.defaults = append(classifiers.defaults, c)
+	classifiers.Unlock()
+}
+
+// RegisterSentinel adds a rule that classifies errors equal to the given sentinel error (e.g.
+// sql.ErrNoRows) as the given Kind.
+func RegisterSentinel(sentinel error, kind Kind) {
+	RegisterClassifier(sentinelClassifier(sentinel, kind))
+}
+
+// RegisterType adds a rule that classifies errors with the same type as the given example error
+// (e.g. &json.SyntaxError{}) as the given Kind.
+func RegisterType(example error, kind Kind) {
+	typ := reflect.TypeOf(example)
+
+	RegisterClassifier(func(err error) (Kind, bool) {
+		return kind, reflect.TypeOf(err) == typ
+	})
+}
+
+// RegisterPredicate adds a rule that classifies errors that the given predicate returns true for as
+// the given Kind.
+func RegisterPredicate(predicate func(err error) bool, kind Kind) {
+	RegisterClassifier(predicateClassifier(predicate, kind))
+}
+
+// Classify returns the Kind that the given error should have. If the error is an *Error with a Kind
+// somewhere in its stack, that Kind is returned. Otherwise, the classification rules are tried
+// against each error in the stack, including errors wrapped by standard library error types (e.g.
+// *os.PathError, or *net.OpError), until one matches. If nothing matches, an empty Kind is returned.
+//
+// There are no default rules unless the kinds package is imported, which registers rules for errors
+// from the standard library (e.g. sql.ErrNoRows is classified as kinds.NotFound).
+func Classify(err error) Kind {
+	classifiers.RLock()
+	rules := make([]Classifier, 0, len(classifiers.custom)+len(classifiers.defaults))
+	rules = append(rules, classifiers.custom...)
+	rules = append(rules, classifiers.defaults...)
+	classifiers.RUnlock()
+
+	for err != nil {
+		if e, ok := err.(*Error); ok && e.Kind != "" {
+			return e.Kind
+		}
+
+		for _, rule := range rules {
+			if kind, ok := rule(err); ok {
+				return kind
+			}
+		}
+
+		err = unwrapCause(err)
+	}
+
+	return ""
+}
+
+// WrapClassified works the same way as Wrap, but if no Kind is given, the Kind is set using
+// Classify, so that errors from other packages can be handled using Is.
+//
+// Example usage:
+//
+//    row := db.QueryRowContext(ctx, query, id)
+//    if err := row.Scan(&user.Name); err != nil {
+//        // If err is sql.ErrNoRows, Is(err, kinds.NotFound) will be true.
+//        return errors.WrapClassified(err, "users: failed to find user")
+//    }
+//
+func WrapClassified(cause error, args ...interface{}) *Error {
+	if cause == nil {
+		return nil
+	}
+
+	args = append(args, cause)
+	err := newError(args...)
+
+	if err.Kind == "" {
+		err.Kind = Classify(cause)
+	}
+
+	updateCaller(err)
+
+	return err
+}
+
+// sentinelClassifier returns a Classifier that matches errors equal to the given sentinel error.
+// Errors whose type isn't comparable (e.g. a struct with a slice field, used as a value) can't be
+// equal to the sentinel, and comparing them would panic, so they're never matched.
+func sentinelClassifier(sentinel error, kind Kind) Classifier {
+	return func(err error) (Kind, bool) {
+		if err == nil || !reflect.TypeOf(err).Comparable() {
+			return kind, false
+		}
+
+		return kind, err == sentinel
+	}
+}
+
+// predicateClassifier returns a Classifier that matches errors that the given predicate returns true
+// for.
+func predicateClassifier(predicate func(err error) bool, kind Kind) Classifier {
+	return func(err error) (Kind, bool) {
+		return kind, predicate(err)
+	}
+}
+
+// Unwrap returns the error wrapped by the given error, or nil if there isn't one. It understands
+// *Error, the standard library's error types that wrap other errors (e.g. *url.Error), and errors
+// that implement either an Unwrap or Cause method. This is how Classify and Is look through errors
+// from other packages, so it's useful for walking an error's chain the same way they do.
+func Unwrap(err error) error {
+	return unwrapCause(err)
+}
+
+// unwrapCause returns the error wrapped by the given error, if there is one. This understands
+// *Error, the standard library's error types that wrap other errors, and errors that implement
+// either an Unwrap or Cause method. The os package's error types are handled explicitly, because
+// they don't have an Unwrap method before Go 1.13; the net and net/url packages' error types (e.g.
+// *net.OpError) are only unwrapped from Go 1.13, to keep this package's dependencies small.
+func unwrapCause(err error) error {
+	switch v := err.(type) {
+	case *Error:
+		return v.Cause
+	case *os.PathError:
+		return v.Err
+	case *os.LinkError:
+		return v.Err
+	case *os.SyscallError:
+		return v.Err
+	case interface{ Unwrap() error }:
+		return v.Unwrap()
+	case interface{ Cause() error }:
+		return v.Cause()
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// uncomparableError is an error type that would panic if compared using ==.
+type uncomparableError struct {
+	causes []error
+}
+
+func (uncomparableError) Error() string {
+	return "uncomparable"
+}
+
+func TestClassify(t *testing.T) {
+	tt := []struct {
+		name     string
+		err      error
+		expected Kind
+	}{
+		{name: "nil", err: nil, expected: ""},
+		{name: "unknown standard error", err: errors.New("oops"), expected: ""},
+		{name: "uncomparable error", err: uncomparableError{}, expected: ""},
+		{name: "error with a kind", err: Wrap(New(ErrKindTest), "oops"), expected: ErrKindTest},
+		{
+			name:     "error with a kind wrapped by a standard library error",
+			err:      &os.PathError{Op: "open", Path: "/tmp", Err: New(ErrKindTest)},
+			expected: ErrKindTest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Classify(tc.err))
+		})
+	}
+}
+
+func TestRegisterClassifier(t *testing.T) {
+	defer resetClassifiers()
+
+	sentinel := errors.New("sentinel")
+	defaultSentinel := errors.New("default sentinel")
+	sentinelKind := Kind("classify test: sentinel")
+	typeKind := Kind("classify test: type")
+	predicateKind := Kind("classify test: predicate")
+	defaultKind := Kind("classify test: default")
+	overrideKind := Kind("classify test: override")
+
+	RegisterDefaultClassifier(func(err error) (Kind, bool) {
+		return defaultKind, err == defaultSentinel
+	})
+
+	RegisterSentinel(sentinel, sentinelKind)
+	RegisterType(&json.SyntaxError{}, typeKind)
+	RegisterPredicate(func(err error) bool { return err.Error() == "predicate" }, predicateKind)
+	RegisterSentinel(defaultSentinel, overrideKind)
+
+	t.Run("should classify registered sentinel errors", func(t *testing.T) {
+		assert.Equal(t, sentinelKind, Classify(Wrap(sentinel)))
+	})
+
+	t.Run("should classify registered error types", func(t *testing.T) {
+		assert.Equal(t, typeKind, Classify(json.Unmarshal([]byte("{"), &struct{}{})))
+	})
+
+	t.Run("should classify errors matching registered predicates", func(t *testing.T) {
+		assert.Equal(t, predicateKind, Classify(errors.New("predicate")))
+	})
+
+	t.Run("should try custom rules before default ones", func(t *testing.T) {
+		assert.Equal(t, overrideKind, Classify(defaultSentinel))
+	})
+
+	t.Run("should not panic when comparing uncomparable errors with sentinels", func(t *testing.T) {
+		assert.NotPanics(t, func() {
+			assert.Equal(t, Kind(""), Classify(Wrap(uncomparableError{})))
+		})
+	})
+}
+
+func TestWrapClassified(t *testing.T) {
+	defer resetClassifiers()
+
+	sentinel := errors.New("sentinel")
+	sentinelKind := Kind("classify test: sentinel")
+
+	RegisterSentinel(sentinel, sentinelKind)
+
+	t.Run("should return nil if the given cause is nil", func(t *testing.T) {
+		assert.Nil(t, WrapClassified(nil))
+	})
+
+	t.Run("should set the kind of the error", func(t *testing.T) {
+		err := WrapClassified(sentinel, "users: failed to find user")
+
+		assert.Equal(t, sentinelKind, err.Kind)
+		assert.True(t, Is(err, sentinelKind))
+		assert.Equal(t, "go-errors.TestWrapClassified.func2", Stack(err)[0].Caller)
+	})
+
+	t.Run("should not override a given kind", func(t *testing.T) {
+		err := WrapClassified(sentinel, ErrKindTest)
+
+		assert.Equal(t, ErrKindTest, err.Kind)
+	})
+
+	t.Run("should leave the kind empty if the error can't be classified", func(t *testing.T) {
+		err := WrapClassified(errors.New("oops"))
+
+		assert.Equal(t, Kind(""), err.Kind)
+	})
+}
+
+// resetClassifiers removes the rules registered by a test.
+func resetClassifiers() {
+	classifiers.Lock()
+	classifiers.custom = nil
+	classifiers.defaults = nil
+	classifiers.Unlock()
+}
+
+func TestUnwrap(t *testing.T) {
+	t.Run("should return nil for errors that don't wrap anything", func(t *testing.T) {
+		assert.Nil(t, Unwrap(nil))
+		assert.Nil(t, Unwrap(errors.New("oops")))
+		assert.Nil(t, Unwrap(New("oops")))
+	})
+
+	t.Run("should return the cause of an *Error", func(t *testing.T) {
+		cause := errors.New("oops")
+		assert.Equal(t, cause, Unwrap(Wrap(cause, "wrapped")))
+	})
+
+	t.Run("should unwrap the standard library's error types", func(t *testing.T) {
+		cause := New("oops")
+
+		assert.Equal(t, cause, Unwrap(&os.PathError{Op: "open", Path: "/tmp", Err: cause}))
+		assert.Equal(t, cause, Unwrap(&net.OpError{Op: "dial", Err: cause}))
+	})
+}
@@ -0,0 +1,523 @@
+// Command errfmt makes error stacks in logs readable. It reads log lines from stdin, and wherever a
+// line contains a JSON object with a stack (as produced by errors.Stack) under a given key, it
+// writes the rest of the line, followed by the stack in the verbose (%+v) format, or as a coloured
+// tree (see errors.Printer). Lines without a stack are passed through unchanged, so it can be used
+// as a filter on any log output.
+//
+// Usage:
+//
+//    kubectl logs deploy/users | errfmt [flags]
+//
+// The value under the key may be a stack, an encoded error with a "stack" property (e.g. a
+// report.Entry), or either of those encoded as a JSON string. The key may be a path to a nested
+// property, e.g. "error.stack". Lines that are just a JSON array of stack frames are rendered too.
+// Anything before or after the JSON object on a line, e.g. a prefix added by a log collector, is
+// kept, as is the rest of the object, exactly as it was written.
+//
+// Examples:
+//
+//    # Render stacks logged under the "stack" key as coloured trees.
+//    kubectl logs deploy/users | errfmt -format tree
+//
+//    # Render errors logged under the "error" key, keeping the original lines.
+//    errfmt -key error -keep < app.log
+//
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/icelolly/go-errors"
+)
+
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// maxLine is the longest line that will be read.
+const maxLine = 16 << 20
+
+// Formats that stacks can be rendered in.
+const (
+	formatVerbose = "verbose"
+	formatTree    = "tree"
+)
+
+// Colour modes for the tree format.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// frameKeys are the properties of a StackFrame, at least one of which must be present in every
+// element of an array for it to be treated as a stack.
+var frameKeys = []string{"kind", "message", "caller", "file"}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command with the given arguments, returning its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var f formatter
+
+	flags := flag.NewFlagSet("errfmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var key, format, color string
+
+	flags.StringVar(&key, "key", "stack", "the `key` of the stack, or encoded error, in each line (dot-separated for nested keys)")
+	flags.StringVar(&format, "format", formatVerbose, "the `format` to render stacks in: verbose (%+v), or tree")
+	flags.StringVar(&color, "color", colorAuto, "whether trees are coloured: auto, always, or never")
+	flags.BoolVar(&f.keep, "keep", false, "keep the stack in the original line, rather than removing it")
+
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: errfmt [flags] < input")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "errfmt: unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return exitUsage
+	}
+
+	if key == "" {
+		fmt.Fprintln(stderr, "errfmt: -key must not be empty")
+		return exitUsage
+	}
+
+	f.path = strings.Split(key, ".")
+
+	switch format {
+	case formatVerbose:
+	case formatTree:
+		f.tree = true
+	default:
+		fmt.Fprintf(stderr, "errfmt: invalid -format %q\n", format)
+		return exitUsage
+	}
+
+	switch color {
+	case colorAuto:
+		f.color = errors.NewPrinter(stdout).Color
+	case colorAlways:
+		f.color = true
+	case colorNever:
+	default:
+		fmt.Fprintf(stderr, "errfmt: invalid -color %q\n", color)
+		return exitUsage
+	}
+
+	w := bufio.NewWriter(stdout)
+
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLine)
+
+	for scanner.Scan() {
+		w.WriteString(f.format(scanner.Text()))
+		w.WriteString("\n")
+
+		// Output is flushed after each line, so that it can be used to follow logs as they're written.
+		if err := w.Flush(); err != nil {
+			fmt.Fprintf(stderr, "errfmt: %v\n", err)
+			return exitError
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(stderr, "errfmt: %v\n", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// formatter renders the stacks found in log lines.
+type formatter struct {
+	path  []string
+	keep  bool
+	tree  bool
+	color bool
+}
+
+// format returns the given line with its stack rendered, if it has one, or the line unchanged
+// otherwise. The result doesn't end in a newline. The rest of the line is kept byte for byte, so
+// that values like large numbers, and the order of keys, aren't changed.
+func (f formatter) format(line string) string {
+	ends := bracketEnds(line)
+
+	for i := 0; i < len(line); {
+		end, ok := ends[i]
+		if !ok {
+			i++
+			continue
+		}
+
+		// Whatever is between the brackets has already been looked at, so if it's not a stack, we
+		// can carry on from the end of it, rather than trying every bracket inside it too.
+		if !json.Valid([]byte(line[i:end])) {
+			i = end
+			continue
+		}
+
+		rest, stack, ok := f.extract(line[i:end])
+		if !ok {
+			i = end
+			continue
+		}
+
+		out := line
+		if !f.keep {
+			out = line[:i] + rest + line[end:]
+		}
+
+		rendered := strings.TrimRight(f.render(stack), "\n")
+
+		// If there's nothing left of the line once the stack is removed, just show the stack.
+		if out = strings.TrimRight(out, " "); out == "" {
+			return rendered
+		}
+
+		return out + "\n" + rendered
+	}
+
+	return line
+}
+
+// extract finds the stack in the given JSON value, returning the value without the stack, or an
+// empty string if nothing is left once it's removed.
+func (f formatter) extract(value string) (string, []errors.StackFrame, bool) {
+	if value[0] == '[' {
+		stack, ok := stackFrom(value)
+		return "", stack, ok
+	}
+
+	rest, stack, ok := f.remove(value, f.path)
+	if !ok {
+		return "", nil, false
+	}
+
+	if ms, _ := members(rest); len(ms) == 0 {
+		return "", stack, true
+	}
+
+	return rest, stack, true
+}
+
+// remove finds the stack at the given path in the given JSON object, returning the object without
+// it.
+func (f formatter) remove(obj string, path []string) (string, []errors.StackFrame, bool) {
+	if obj[0] != '{' {
+		return "", nil, false
+	}
+
+	ms, ok := members(obj)
+	if !ok {
+		return "", nil, false
+	}
+
+	// If a key appears more than once, the last one wins, as it does when decoding JSON.
+	idx := -1
+	for i, m := range ms {
+		if m.key == path[0] {
+			idx = i
+		}
+	}
+
+	if idx < 0 {
+		return "", nil, false
+	}
+
+	m := ms[idx]
+
+	if len(path) > 1 {
+		child, stack, ok := f.remove(obj[m.valueStart:m.valueEnd], path[1:])
+		if !ok {
+			return "", nil, false
+		}
+
+		return obj[:m.valueStart] + child + obj[m.valueEnd:], stack, true
+	}
+
+	stack, ok := stackFrom(obj[m.valueStart:m.valueEnd])
+	if !ok {
+		return "", nil, false
+	}
+
+	// The member is removed along with one of the commas next to it.
+	switch {
+	case idx+1 < len(ms):
+		return obj[:m.start] + obj[ms[idx+1].start:], stack, true
+	case idx > 0:
+		return obj[:ms[idx-1].valueEnd] + obj[m.valueEnd:], stack, true
+	default:
+		return obj[:m.start] + obj[m.valueEnd:], stack, true
+	}
+}
+
+// stackFrom returns the stack in the given JSON value, which may be a stack, an encoded error with
+// a "stack" property, or either of those encoded as a string.
+func stackFrom(value string) ([]errors.StackFrame, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, false
+	}
+
+	switch value[0] {
+	case '"':
+		var decoded string
+		if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+			return nil, false
+		}
+
+		decoded = strings.TrimSpace(decoded)
+		if decoded == "" || decoded[0] == '"' || !json.Valid([]byte(decoded)) {
+			return nil, false
+		}
+
+		return stackFrom(decoded)
+	case '{':
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(value), &obj); err != nil {
+			return nil, false
+		}
+
+		stack, ok := obj["stack"]
+		if !ok {
+			return nil, false
+		}
+
+		return stackFrom(string(stack))
+	case '[':
+		var elems []map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(value), &elems); err != nil {
+			return nil, false
+		}
+
+		if len(elems) == 0 || !isStack(elems) {
+			return nil, false
+		}
+
+		// Numbers are decoded as they were written, so that field values aren't rounded.
+		dec := json.NewDecoder(strings.NewReader(value))
+		dec.UseNumber()
+
+		var stack []errors.StackFrame
+		if err := dec.Decode(&stack); err != nil {
+			return nil, false
+		}
+
+		return stack, true
+	}
+
+	return nil, false
+}
+
+// isStack reports whether every element of the given array looks like a stack frame.
+func isStack(elems []map[string]json.RawMessage) bool {
+	for _, elem := range elems {
+		if !hasAny(elem, frameKeys) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasAny reports whether the given object has any of the given keys.
+func hasAny(obj map[string]json.RawMessage, keys []string) bool {
+	for _, k := range keys {
+		if _, ok := obj[k]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// member is a key/value pair in a JSON object, with the offsets of where it starts, and where its
+// value starts and ends.
+type member struct {
+	key        string
+	start      int
+	valueStart int
+	valueEnd   int
+}
+
+// members returns the members of the given JSON object, which must be valid JSON.
+func members(obj string) ([]member, bool) {
+	var ms []member
+
+	i := skipSpace(obj, 1)
+	if i < len(obj) && obj[i] == '}' {
+		return ms, true
+	}
+
+	for i < len(obj) && obj[i] == '"' {
+		m := member{start: i}
+
+		keyEnd := stringEnd(obj, i)
+		if keyEnd < 0 || json.Unmarshal([]byte(obj[i:keyEnd]), &m.key) != nil {
+			return nil, false
+		}
+
+		i = skipSpace(obj, keyEnd)
+		if i >= len(obj) || obj[i] != ':' {
+			return nil, false
+		}
+
+		m.valueStart = skipSpace(obj, i+1)
+		if m.valueEnd = valueEnd(obj, m.valueStart); m.valueEnd < 0 {
+			return nil, false
+		}
+
+		ms = append(ms, m)
+
+		i = skipSpace(obj, m.valueEnd)
+		if i >= len(obj) || obj[i] != ',' {
+			break
+		}
+
+		i = skipSpace(obj, i+1)
+	}
+
+	if i >= len(obj) || obj[i] != '}' {
+		return nil, false
+	}
+
+	return ms, true
+}
+
+// bracketEnds returns the offset just after the matching closing bracket for each opening bracket in
+// the given line that has one. It's done in a single pass, so that long lines with lots of brackets
+// that don't match don't take long. Quotes are only treated as strings inside brackets, as the rest
+// of the line may be plain text.
+func bracketEnds(line string) map[int]int {
+	ends := make(map[int]int)
+
+	var open []int
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			if len(open) == 0 {
+				continue
+			}
+
+			end := stringEnd(line, i)
+			if end < 0 {
+				return ends
+			}
+
+			i = end - 1 // The loop moves past the end of the string.
+		case '{', '[':
+			open = append(open, i)
+		case '}', ']':
+			if len(open) == 0 {
+				continue
+			}
+
+			ends[open[len(open)-1]] = i + 1
+			open = open[:len(open)-1]
+		}
+	}
+
+	return ends
+}
+
+// valueEnd returns the offset just after the JSON value that starts at the given offset, or -1 if
+// the value doesn't end. It only finds where the value ends; it doesn't check that it's valid.
+func valueEnd(s string, i int) int {
+	if i >= len(s) {
+		return -1
+	}
+
+	switch s[i] {
+	case '"':
+		return stringEnd(s, i)
+	case '{', '[':
+		depth := 0
+
+		for j := i; j < len(s); j++ {
+			switch s[j] {
+			case '"':
+				if j = stringEnd(s, j); j < 0 {
+					return -1
+				}
+
+				j-- // The loop moves past the end of the string.
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return j + 1
+				}
+			}
+		}
+
+		return -1
+	}
+
+	// Anything else is a number, or a literal (e.g. true), which ends at the next delimiter.
+	j := i
+	for j < len(s) && !strings.ContainsRune(",:{}[] \t\r\n", rune(s[j])) {
+		j++
+	}
+
+	return j
+}
+
+// stringEnd returns the offset just after the JSON string that starts at the given offset, or -1
+// if the string doesn't end.
+func stringEnd(s string, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+
+	return -1
+}
+
+// skipSpace returns the offset of the first character at or after the given offset that isn't
+// whitespace.
+func skipSpace(s string, i int) int {
+	for i < len(s) && strings.ContainsRune(" \t\r\n", rune(s[i])) {
+		i++
+	}
+
+	return i
+}
+
+// render renders the given stack in the formatter's format.
+func (f formatter) render(stack []errors.StackFrame) string {
+	if !f.tree {
+		return errors.FormatStack(stack)
+	}
+
+	buf := bytes.Buffer{}
+
+	p := errors.NewPrinter(&buf)
+	p.Color = f.color
+	p.PrintStack(stack)
+
+	return buf.String()
+}
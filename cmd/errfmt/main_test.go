@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStack returns the stack of an error with a couple of frames.
+func testStack() []errors.StackFrame {
+	err := errors.Wrap(errors.New(kinds.NotFound, "user not found").WithField("user_id", 42), "lookup failed")
+	return errors.Stack(err)
+}
+
+// mustJSON returns the given value encoded as JSON.
+func mustJSON(t *testing.T, v interface{}) string {
+	bs, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	return string(bs)
+}
+
+// runErrfmt runs the command with the given arguments and stdin, returning its exit code and output.
+func runErrfmt(args []string, stdin string) (int, string, string) {
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	stack := testStack()
+	verbose := errors.FormatStack(stack)
+
+	t.Run("should pass through lines without a stack unchanged", func(t *testing.T) {
+		input := "plain text\n{\"level\":\"info\",\"msg\":\"hello\"}\n[1,2,3]\n{not json\n"
+
+		code, stdout, stderr := runErrfmt(nil, input)
+		assert.Equal(t, exitOK, code)
+		assert.Equal(t, input, stdout)
+		assert.Empty(t, stderr)
+	})
+
+	t.Run("should render stacks under the default key", func(t *testing.T) {
+		line := `{"level":"error","stack":` + mustJSON(t, stack) + `}`
+
+		code, stdout, _ := runErrfmt(nil, line+"\n")
+		assert.Equal(t, exitOK, code)
+		assert.Equal(t, `{"level":"error"}`+"\n"+strings.TrimRight(verbose, "\n")+"\n", stdout)
+	})
+
+	t.Run("should keep the prefix before the JSON", func(t *testing.T) {
+		line := `2020-01-01T12:00:00Z pod-1 {"stack":` + mustJSON(t, stack) + `}`
+
+		code, stdout, _ := runErrfmt(nil, line)
+		assert.Equal(t, exitOK, code)
+		assert.True(t, strings.HasPrefix(stdout, "2020-01-01T12:00:00Z pod-1\nError: "))
+	})
+
+	t.Run("should keep the original line if asked", func(t *testing.T) {
+		line := `{"level":"error","stack":` + mustJSON(t, stack) + `}`
+
+		_, stdout, _ := runErrfmt([]string{"-keep"}, line)
+		assert.True(t, strings.HasPrefix(stdout, line+"\nError: "))
+	})
+
+	t.Run("should render stacks under nested keys", func(t *testing.T) {
+		line := `{"msg":"failed","error":{"stack":` + mustJSON(t, stack) + `,"code":1}}`
+
+		_, stdout, _ := runErrfmt([]string{"-key", "error.stack"}, line)
+		assert.Equal(t, `{"msg":"failed","error":{"code":1}}`+"\n"+verbose, stdout)
+	})
+
+	t.Run("should keep the rest of the line as it was", func(t *testing.T) {
+		line := `{"user_id":9007199254740993, "level":"error","stack":` + mustJSON(t, stack) + ` ,"at":1.50}`
+
+		_, stdout, _ := runErrfmt(nil, line)
+		assert.Equal(t, `{"user_id":9007199254740993, "level":"error","at":1.50}`+"\n"+verbose, stdout)
+	})
+
+	t.Run("should keep text after the JSON", func(t *testing.T) {
+		line := `pod-1 {"level":"error","stack":` + mustJSON(t, stack) + `} (truncated)`
+
+		_, stdout, _ := runErrfmt(nil, line)
+		assert.Equal(t, `pod-1 {"level":"error"} (truncated)`+"\n"+verbose, stdout)
+	})
+
+	t.Run("should skip bracketed text before the JSON", func(t *testing.T) {
+		line := `[ERROR] [1] {"stack":` + mustJSON(t, stack) + `}`
+
+		_, stdout, _ := runErrfmt(nil, line)
+		assert.Equal(t, "[ERROR] [1]\n"+verbose, stdout)
+	})
+
+	t.Run("should not round large numbers in fields", func(t *testing.T) {
+		line := `{"stack":[{"message":"oops","caller":"main.main","fields":{"id":9007199254740993}}]}`
+
+		_, stdout, _ := runErrfmt(nil, line)
+		assert.Contains(t, stdout, "9007199254740993")
+	})
+
+	t.Run("should not take long on lines with lots of brackets", func(t *testing.T) {
+		line := strings.Repeat(`{"a":[1,`, 10000) + strings.Repeat("{", 10000)
+
+		_, stdout, _ := runErrfmt(nil, line)
+		assert.Equal(t, line+"\n", stdout)
+	})
+
+	t.Run("should render encoded errors with a stack", func(t *testing.T) {
+		encoded := mustJSON(t, map[string]interface{}{"message": "lookup failed", "stack": stack})
+		line := `{"error":` + encoded + `}`
+
+		_, stdout, _ := runErrfmt([]string{"-key", "error"}, line)
+		assert.Equal(t, verbose, stdout)
+	})
+
+	t.Run("should render stacks encoded as strings", func(t *testing.T) {
+		line := `{"msg":"failed","stack":` + mustJSON(t, mustJSON(t, stack)) + `}`
+
+		_, stdout, _ := runErrfmt(nil, line)
+		assert.Equal(t, `{"msg":"failed"}`+"\n"+verbose, stdout)
+	})
+
+	t.Run("should render lines that are just a stack", func(t *testing.T) {
+		_, stdout, _ := runErrfmt(nil, mustJSON(t, stack))
+		assert.Equal(t, verbose, stdout)
+	})
+
+	t.Run("should not render arrays that aren't stacks", func(t *testing.T) {
+		line := `{"stack":[{"foo":"bar"}]}`
+
+		_, stdout, _ := runErrfmt(nil, line)
+		assert.Equal(t, line+"\n", stdout)
+	})
+
+	t.Run("should render stacks as trees", func(t *testing.T) {
+		line := `{"stack":` + mustJSON(t, stack) + `}`
+
+		buf := bytes.Buffer{}
+		p := errors.NewPrinter(&buf)
+		p.Color = false
+		require.NoError(t, p.PrintStack(stack))
+
+		_, stdout, _ := runErrfmt([]string{"-format", "tree", "-color", "never"}, line)
+		assert.Equal(t, buf.String(), stdout)
+	})
+
+	t.Run("should colour trees if asked", func(t *testing.T) {
+		line := `{"stack":` + mustJSON(t, stack) + `}`
+
+		_, stdout, _ := runErrfmt([]string{"-format", "tree", "-color", "always"}, line)
+		assert.Contains(t, stdout, "\x1b[")
+
+		_, stdout, _ = runErrfmt([]string{"-format", "tree"}, line)
+		assert.NotContains(t, stdout, "\x1b[")
+	})
+
+	t.Run("should reject invalid flags", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"-format", "yaml"},
+			{"-color", "sometimes"},
+			{"-key", ""},
+			{"-nope"},
+			{"file.log"},
+		} {
+			code, _, stderr := runErrfmt(args, "")
+			assert.Equal(t, exitUsage, code, "args: %v", args)
+			assert.NotEmpty(t, stderr, "args: %v", args)
+		}
+	})
+}
@@ -0,0 +1,364 @@
+// Command errq queries the files written by report.Journal, or any other NDJSON file of
+// report.Entry values, e.g. from a report.WriterSink. Entries can be filtered by kind, field, time,
+// caller, and fingerprint, and are printed in the verbose (%+v) format, as JSON, or counted by
+// fingerprint.
+//
+// Usage:
+//
+//    errq [flags] [file ...]
+//
+// If no files are given, or a file is "-", entries are read from stdin. Rotated journal files can
+// be read together using a glob, e.g. "errq -count errors.ndjson*".
+//
+// Examples:
+//
+//    # Errors of the "not found" kind, anywhere in their stack, in the last hour.
+//    errq -kind "not found" -since 1h errors.ndjson
+//
+//    # How many times each error has happened for a user.
+//    errq -field user_id=42 -count errors.ndjson*
+//
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/report"
+)
+
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, time.Now()))
+}
+
+// run runs the command with the given arguments, returning its exit code. Relative times given to
+// -since and -until are relative to now.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, now time.Time) int {
+	var (
+		q                 query
+		kinds, fields     listFlag
+		since, until      string
+		count, jsonOutput bool
+	)
+
+	flags := flag.NewFlagSet("errq", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	flags.Var(&kinds, "kind", "only show errors with this `kind` anywhere in their stack (repeatable)")
+	flags.Var(&fields, "field", "only show errors with this field, given as `key[=value]` (repeatable)")
+	flags.StringVar(&since, "since", "", "only show errors since this `time` (RFC 3339, or a duration ago, e.g. 1h)")
+	flags.StringVar(&until, "until", "", "only show errors before this `time` (RFC 3339, or a duration ago, e.g. 1h)")
+	flags.StringVar(&q.caller, "caller", "", "only show errors with a caller containing this `text`")
+	flags.StringVar(&q.fingerprint, "fingerprint", "", "only show errors with a fingerprint starting with this `prefix`")
+	flags.BoolVar(&count, "count", false, "count errors by fingerprint, rather than showing each one")
+	flags.BoolVar(&jsonOutput, "json", false, "show errors as JSON, rather than in the %+v format")
+
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: errq [flags] [file ...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	q.kinds = kinds
+
+	q.fields = make(map[string]*string, len(fields))
+	for _, field := range fields {
+		if idx := strings.IndexByte(field, '='); idx >= 0 {
+			value := field[idx+1:]
+			q.fields[field[:idx]] = &value
+		} else {
+			q.fields[field] = nil
+		}
+	}
+
+	var err error
+	if q.since, err = parseTime(since, now); err != nil {
+		fmt.Fprintf(stderr, "errq: invalid -since: %v\n", err)
+		return exitUsage
+	}
+
+	if q.until, err = parseTime(until, now); err != nil {
+		fmt.Fprintf(stderr, "errq: invalid -until: %v\n", err)
+		return exitUsage
+	}
+
+	var out output
+	switch {
+	case count:
+		out = &counter{groups: make(map[string]*group)}
+	case jsonOutput:
+		out = &jsonPrinter{enc: json.NewEncoder(stdout)}
+	default:
+		out = &stackPrinter{w: stdout}
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	for _, file := range files {
+		if err := readFile(file, stdin, func(entry report.Entry) error {
+			if q.match(entry) {
+				return out.add(entry)
+			}
+
+			return nil
+		}); err != nil {
+			fmt.Fprintf(stderr, "errq: %s: %v\n", file, err)
+			return exitError
+		}
+	}
+
+	if c, ok := out.(*counter); ok {
+		c.print(stdout)
+	}
+
+	return exitOK
+}
+
+// readFile reads the entries in the given file, or stdin if the file is "-".
+func readFile(file string, stdin io.Reader, fn func(entry report.Entry) error) error {
+	if file == "-" {
+		return report.ReadEntries(stdin, fn)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	return report.ReadEntries(f, fn)
+}
+
+// parseTime parses a time given as RFC 3339, or as a duration before now. An empty string is the
+// zero time.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+// listFlag is a flag that can be given more than once.
+type listFlag []string
+
+// String returns the flag's values.
+func (f *listFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+// Set adds a value to the flag.
+func (f *listFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// query is the set of filters that entries must match to be shown.
+type query struct {
+	kinds       []string
+	fields      map[string]*string
+	since       time.Time
+	until       time.Time
+	caller      string
+	fingerprint string
+}
+
+// match reports whether the given entry matches every filter in the query.
+func (q query) match(entry report.Entry) bool {
+	if !q.since.IsZero() && entry.Time.Before(q.since) {
+		return false
+	}
+
+	if !q.until.IsZero() && !entry.Time.Before(q.until) {
+		return false
+	}
+
+	if !strings.HasPrefix(entry.Fingerprint, q.fingerprint) {
+		return false
+	}
+
+	if len(q.kinds) > 0 && !q.matchKind(entry) {
+		return false
+	}
+
+	for key, value := range q.fields {
+		v, ok := entry.Fields[key]
+		if !ok || value != nil && fmt.Sprint(v) != *value {
+			return false
+		}
+	}
+
+	if q.caller != "" && !q.matchCaller(entry) {
+		return false
+	}
+
+	return true
+}
+
+// matchKind reports whether the given entry, or any error in its stack, is of any of the query's
+// kinds. Kinds are compared as they are, as the kinds that an application derives from others
+// aren't registered here.
+func (q query) matchKind(entry report.Entry) bool {
+	for _, kind := range q.kinds {
+		if entry.Kind == kind {
+			return true
+		}
+
+		for _, frame := range entry.Stack {
+			if frame.Kind == kind {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchCaller reports whether any frame in the given entry's stack has a caller containing the
+// query's caller.
+func (q query) matchCaller(entry report.Entry) bool {
+	for _, frame := range entry.Stack {
+		if strings.Contains(frame.Caller, q.caller) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// output is where matching entries are sent.
+type output interface {
+	add(entry report.Entry) error
+}
+
+// stackPrinter prints each entry in the verbose (%+v) format, with a header line.
+type stackPrinter struct {
+	w io.Writer
+}
+
+// add prints the given entry.
+func (p *stackPrinter) add(entry report.Entry) error {
+	header := entry.Time.Format(time.RFC3339Nano) + " " + entry.Fingerprint
+	if entry.ReferenceID != "" {
+		header += " " + entry.ReferenceID
+	}
+
+	if entry.Suppressed > 0 {
+		header += fmt.Sprintf(" (+%d suppressed)", entry.Suppressed)
+	}
+
+	_, err := fmt.Fprintf(p.w, "--- %s\n%s\n", header, strings.TrimRight(errors.FormatStack(entry.Stack), "\n"))
+
+	return err
+}
+
+// jsonPrinter prints each entry as a line of JSON.
+type jsonPrinter struct {
+	enc *json.Encoder
+}
+
+// add prints the given entry.
+func (p *jsonPrinter) add(entry report.Entry) error {
+	return p.enc.Encode(entry)
+}
+
+// counter counts entries by fingerprint.
+type counter struct {
+	groups map[string]*group
+}
+
+// group is the count of entries with the same fingerprint.
+type group struct {
+	count    int
+	lastSeen time.Time
+	latest   report.Entry
+}
+
+// add counts the given entry, including any errors with the same fingerprint that were suppressed.
+func (c *counter) add(entry report.Entry) error {
+	g, ok := c.groups[entry.Fingerprint]
+	if !ok {
+		g = &group{}
+		c.groups[entry.Fingerprint] = g
+	}
+
+	g.count += 1 + entry.Suppressed
+
+	if !entry.Time.Before(g.lastSeen) {
+		g.lastSeen = entry.Time
+		g.latest = entry
+	}
+
+	return nil
+}
+
+// print prints the counts as a table, with the most common errors first.
+func (c *counter) print(w io.Writer) {
+	groups := make([]*group, 0, len(c.groups))
+	for _, g := range c.groups {
+		groups = append(groups, g)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+
+		return groups[i].latest.Fingerprint < groups[j].latest.Fingerprint
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "COUNT\tFINGERPRINT\tLAST SEEN\tKIND\tMESSAGE")
+
+	for _, g := range groups {
+		fingerprint := g.latest.Fingerprint
+		if len(fingerprint) > 12 {
+			fingerprint = fingerprint[:12]
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n",
+			g.count,
+			fingerprint,
+			g.lastSeen.Format(time.RFC3339),
+			g.latest.Kind,
+			firstLine(g.latest.Message),
+		)
+	}
+
+	tw.Flush()
+}
+
+// firstLine returns the first line of the given string.
+func firstLine(s string) string {
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		return s[:idx]
+	}
+
+	return s
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/icelolly/go-errors/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNow is the time that relative times are relative to in tests.
+var testNow = time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+var (
+	errUserNotFound = kinds.Derive(kinds.NotFound, "errq test: user not found")
+	errConflict     = errors.New(kinds.Conflict, "conflict").WithField("user_id", 7)
+)
+
+// testJournal returns a journal containing a few entries.
+func testJournal(t *testing.T) string {
+	notFound := func() error {
+		return errors.Wrap(errors.New(errUserNotFound, "user not found").WithField("user_id", 42), "lookup failed")
+	}
+
+	entries := []report.Entry{
+		entryAt(notFound(), testNow.Add(-3*time.Hour)),
+		entryAt(notFound(), testNow.Add(-30*time.Minute)),
+		entryAt(errConflict, testNow.Add(-10*time.Minute)),
+	}
+
+	entries[1].Suppressed = 2
+
+	buf := bytes.Buffer{}
+	require.NoError(t, (&report.WriterSink{Writer: &buf}).Send(context.Background(), entries))
+
+	return buf.String()
+}
+
+// entryAt returns an entry for the given error, reported at the given time.
+func entryAt(err error, at time.Time) report.Entry {
+	ring := report.NewRing(1)
+	ring.Record(context.Background(), err)
+
+	entry := ring.Entries()[0]
+	entry.Time = at
+
+	return entry
+}
+
+// runErrq runs the command with the given arguments and stdin, returning its exit code and output.
+func runErrq(stdin string, args ...string) (int, string, string) {
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr, testNow)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	journal := testJournal(t)
+
+	t.Run("should print entries in the verbose format", func(t *testing.T) {
+		code, stdout, _ := runErrq(journal)
+		require.Equal(t, exitOK, code)
+
+		assert.Equal(t, 3, strings.Count(stdout, "--- "))
+		assert.Contains(t, stdout, "Error: [errq.testJournal.func1]: lookup failed\n")
+		assert.Contains(t, stdout, "Caused by: [errq.testJournal.func1]: user not found (errq test: user not found)\n")
+		assert.Contains(t, stdout, "(+2 suppressed)")
+		assert.Contains(t, stdout, `- "user_id": 42`)
+	})
+
+	t.Run("should filter by kind", func(t *testing.T) {
+		_, stdout, _ := runErrq(journal, "-kind", string(errUserNotFound))
+		assert.Equal(t, 2, strings.Count(stdout, "--- "))
+
+		_, stdout, _ = runErrq(journal, "-kind", string(kinds.Conflict), "-kind", string(kinds.Internal))
+		assert.Equal(t, 1, strings.Count(stdout, "--- "))
+		assert.Contains(t, stdout, "conflict")
+	})
+
+	t.Run("should not match kinds that the kind was derived from", func(t *testing.T) {
+		// Kinds derived by an application aren't registered in errq, so they can't be matched.
+		_, stdout, _ := runErrq(journal, "-kind", string(kinds.NotFound))
+		assert.Empty(t, stdout)
+	})
+
+	t.Run("should filter by field", func(t *testing.T) {
+		_, stdout, _ := runErrq(journal, "-field", "user_id=7")
+		assert.Equal(t, 1, strings.Count(stdout, "--- "))
+
+		_, stdout, _ = runErrq(journal, "-field", "user_id")
+		assert.Equal(t, 3, strings.Count(stdout, "--- "))
+
+		_, stdout, _ = runErrq(journal, "-field", "missing")
+		assert.Empty(t, stdout)
+	})
+
+	t.Run("should filter by time", func(t *testing.T) {
+		_, stdout, _ := runErrq(journal, "-since", "1h")
+		assert.Equal(t, 2, strings.Count(stdout, "--- "))
+
+		_, stdout, _ = runErrq(journal, "-until", testNow.Add(-time.Hour).Format(time.RFC3339))
+		assert.Equal(t, 1, strings.Count(stdout, "--- "))
+
+		_, stdout, _ = runErrq(journal, "-since", "1h", "-until", "20m")
+		assert.Equal(t, 1, strings.Count(stdout, "--- "))
+	})
+
+	t.Run("should filter by caller", func(t *testing.T) {
+		_, stdout, _ := runErrq(journal, "-caller", "testJournal")
+		assert.Equal(t, 2, strings.Count(stdout, "--- "))
+	})
+
+	t.Run("should filter by fingerprint prefix", func(t *testing.T) {
+		_, stdout, _ := runErrq(journal, "-fingerprint", errors.Fingerprint(errConflict)[:8])
+		assert.Equal(t, 1, strings.Count(stdout, "--- "))
+	})
+
+	t.Run("should print entries as JSON", func(t *testing.T) {
+		_, stdout, _ := runErrq(journal, "-json", "-kind", string(kinds.Conflict))
+
+		var entry report.Entry
+		require.NoError(t, json.Unmarshal([]byte(stdout), &entry))
+
+		assert.Equal(t, errors.Fingerprint(errConflict), entry.Fingerprint)
+	})
+
+	t.Run("should count entries by fingerprint", func(t *testing.T) {
+		_, stdout, _ := runErrq(journal, "-count")
+
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		require.Len(t, lines, 3)
+
+		assert.True(t, strings.HasPrefix(lines[0], "COUNT"))
+		assert.Contains(t, lines[1], "errq test: user not found")
+		assert.Contains(t, lines[1], "lookup failed")
+		assert.Contains(t, lines[1], "2020-01-01T11:30:00Z")
+		assert.True(t, strings.HasPrefix(lines[1], "4 "))
+		assert.True(t, strings.HasPrefix(lines[2], "1 "))
+	})
+
+	t.Run("should read files", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "errq-test")
+		require.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "errors.ndjson")
+		require.NoError(t, ioutil.WriteFile(path, []byte(journal), 0644))
+
+		code, stdout, _ := runErrq("", "-count", path, path)
+		require.Equal(t, exitOK, code)
+
+		assert.True(t, strings.HasPrefix(strings.Split(stdout, "\n")[1], "8 "))
+	})
+
+	t.Run("should fail on invalid input", func(t *testing.T) {
+		code, _, stderr := runErrq("nope\n")
+		assert.Equal(t, exitError, code)
+		assert.Contains(t, stderr, "errq: -:")
+
+		code, _, stderr = runErrq("", "missing.ndjson")
+		assert.Equal(t, exitError, code)
+		assert.Contains(t, stderr, "missing.ndjson")
+	})
+
+	t.Run("should fail on invalid flags", func(t *testing.T) {
+		code, _, _ := runErrq("", "-nope")
+		assert.Equal(t, exitUsage, code)
+
+		code, _, stderr := runErrq("", "-since", "yesterday")
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr, "invalid -since")
+	})
+}
+
+func TestQuery_MatchKind(t *testing.T) {
+	entry := report.Entry{
+		Kind: "unavailable",
+		Stack: []errors.StackFrame{
+			{Message: "lookup failed", Kind: "internal"},
+			{Message: "connection refused", Kind: "unavailable"},
+		},
+	}
+
+	assert.True(t, query{kinds: []string{"unavailable"}}.matchKind(entry))
+	assert.True(t, query{kinds: []string{"internal"}}.matchKind(entry))
+	assert.True(t, query{kinds: []string{"not found", "internal"}}.matchKind(entry))
+	assert.False(t, query{kinds: []string{"not found"}}.matchKind(entry))
+	assert.False(t, query{kinds: []string{"not found"}}.matchKind(report.Entry{}))
+}
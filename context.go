@@ -0,0 +1,132 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+)
+
+// contextFieldsKey is the key that fields are stored under in a context.Context.
+type contextFieldsKey struct{}
+
+// WithContextFields returns a copy of the given context with the given key/value pairs stored in it
+// as fields. Errors created using NewCtx or WrapCtx, or by passing the context to New or Wrap, have
+// these fields added to them, so that things like request IDs don't have to be added to every error
+// by hand. Fields already stored in the context are kept, unless they're replaced by a field with
+// the same key.
+//
+// Example usage:
+//
+//    ctx = errors.WithContextFields(ctx, "request_id", requestID, "user_id", user.ID)
+//
+//    // ...
+//
+//    if err != nil {
+//        // The error will have the "request_id" and "user_id" fields.
+//        return errors.WrapCtx(ctx, err, "users: failed to update user")
+//    }
+//
+func WithContextFields(ctx context.Context, kvs ...interface{}) context.Context {
+	kvc := len(kvs)
+
+	if kvc%2 != 0 {
+		Fatal(New(fmt.Sprintf(
+			"errors: invalid argument count for WithContextFields, expected even number of fields, got %d",
+			kvc,
+		)))
+	}
+
+	existing := ContextFields(ctx)
+
+	fields := make(map[string]interface{}, len(existing)+kvc/2)
+	for k, v := range existing {
+		fields[k] = v
+	}
+
+	for i := 0; i < kvc; i = i + 2 {
+		key, ok := kvs[i].(string)
+		if !ok {
+			Fatal(New(fmt.Sprintf(
+				"errors: invalid field key for WithContextFields, expected string, got %T",
+				kvs[i],
+			)))
+		}
+
+		fields[key] = kvs[i+1]
+	}
+
+	return context.WithValue(ctx, contextFieldsKey{}, fields)
+}
+
+// ContextFields returns a copy of the fields stored in the given context using WithContextFields,
+// or nil if there aren't any.
+func ContextFields(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	stored, _ := ctx.Value(contextFieldsKey{}).(map[string]interface{})
+	if len(stored) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]interface{}, len(stored))
+	for k, v := range stored {
+		fields[k] = v
+	}
+
+	return fields
+}
+
+// NewCtx works the same way as New, but adds any fields stored in the given context using
+// WithContextFields to the new error.
+func NewCtx(ctx context.Context, args ...interface{}) *Error {
+	if ctx != nil {
+		args = append(args, ctx)
+	}
+
+	err := newError(args...)
+
+	updateCaller(err)
+
+	return err
+}
+
+// WrapCtx works the same way as Wrap, but adds any fields stored in the given context using
+// WithContextFields to the new error. If the given cause is nil, WrapCtx will return nil.
+func WrapCtx(ctx context.Context, cause error, args ...interface{}) *Error {
+	if cause == nil {
+		return nil
+	}
+
+	if ctx != nil {
+		args = append(args, ctx)
+	}
+
+	args = append(args, cause)
+	err := newError(args...)
+
+	updateCaller(err)
+
+	return err
+}
+
+// addContextFields adds the fields stored in the given context to the error, without replacing any
+// fields that the error already has. The error's fields are copied, as they may have been given to
+// New as a map that the caller still holds.
+func (e *Error) addContextFields(ctx context.Context) {
+	stored, _ := ctx.Value(contextFieldsKey{}).(map[string]interface{})
+	if len(stored) == 0 {
+		return
+	}
+
+	fields := make(map[string]interface{}, len(stored)+len(e.Fields))
+	for k, v := range stored {
+		fields[k] = v
+	}
+
+	for k, v := range e.Fields {
+		fields[k] = v
+	}
+
+	e.Fields = fields
+}
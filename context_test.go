@@ -0,0 +1,117 @@
+package errors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithContextFields(t *testing.T) {
+	t.Run("should store fields in the context", func(t *testing.T) {
+		ctx := WithContextFields(context.Background(), "request_id", "abc123", "user_id", 42)
+
+		assert.Equal(t, map[string]interface{}{
+			"request_id": "abc123",
+			"user_id":    42,
+		}, ContextFields(ctx))
+	})
+
+	t.Run("should keep fields from parent contexts", func(t *testing.T) {
+		parent := WithContextFields(context.Background(), "request_id", "abc123", "user_id", 42)
+		ctx := WithContextFields(parent, "user_id", 43, "tenant_id", "acme")
+
+		assert.Equal(t, map[string]interface{}{
+			"request_id": "abc123",
+			"user_id":    43,
+			"tenant_id":  "acme",
+		}, ContextFields(ctx))
+
+		assert.Equal(t, 42, ContextFields(parent)["user_id"])
+	})
+
+	t.Run("should panic if given an odd number of arguments", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithContextFields(context.Background(), "request_id")
+		})
+	})
+
+	t.Run("should panic if given a key that isn't a string", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithContextFields(context.Background(), 42, "abc123")
+		})
+	})
+}
+
+func TestContextFields(t *testing.T) {
+	t.Run("should return nil if there are no fields", func(t *testing.T) {
+		assert.Nil(t, ContextFields(context.Background()))
+		assert.Nil(t, ContextFields(nil))
+	})
+
+	t.Run("should return a copy of the fields", func(t *testing.T) {
+		ctx := WithContextFields(context.Background(), "request_id", "abc123")
+		ContextFields(ctx)["request_id"] = "changed"
+
+		assert.Equal(t, "abc123", ContextFields(ctx)["request_id"])
+	})
+}
+
+func TestNewCtx(t *testing.T) {
+	ctx := WithContextFields(context.Background(), "request_id", "abc123", "user_id", 42)
+
+	t.Run("should add the context's fields", func(t *testing.T) {
+		err := NewCtx(ctx, "oops")
+
+		assert.Equal(t, "oops", err.Message)
+		assert.Equal(t, map[string]interface{}{
+			"request_id": "abc123",
+			"user_id":    42,
+		}, err.Fields)
+	})
+
+	t.Run("should prefer fields given explicitly", func(t *testing.T) {
+		fields := map[string]interface{}{"user_id": 43}
+		err := NewCtx(ctx, "oops", fields)
+
+		assert.Equal(t, 43, err.Fields["user_id"])
+		assert.Equal(t, "abc123", err.Fields["request_id"])
+		assert.Len(t, fields, 1, "the given map should not be changed")
+	})
+
+	t.Run("should set the caller", func(t *testing.T) {
+		err := NewCtx(ctx, "oops")
+		assert.Contains(t, err.caller, "TestNewCtx")
+	})
+
+	t.Run("should work with a context without fields", func(t *testing.T) {
+		err := NewCtx(context.Background(), "oops")
+		assert.Nil(t, err.Fields)
+	})
+
+	t.Run("should be accepted by New", func(t *testing.T) {
+		err := New("oops", ctx)
+		assert.Equal(t, "abc123", err.Fields["request_id"])
+	})
+}
+
+func TestWrapCtx(t *testing.T) {
+	ctx := WithContextFields(context.Background(), "request_id", "abc123")
+
+	t.Run("should return nil if the cause is nil", func(t *testing.T) {
+		assert.Nil(t, WrapCtx(ctx, nil, "oops"))
+	})
+
+	t.Run("should add the context's fields", func(t *testing.T) {
+		cause := New("inner").WithField("user_id", 42)
+		err := WrapCtx(ctx, cause, "outer")
+
+		assert.Equal(t, cause.Message, err.Cause.(*Error).Message)
+		assert.Contains(t, err.caller, "TestWrapCtx")
+		assert.Equal(t, map[string]interface{}{
+			"request_id": "abc123",
+			"user_id":    42,
+		}, Fields(err))
+		assert.Equal(t, []interface{}{"request_id", "abc123", "user_id", 42}, FieldsSlice(err))
+	})
+}
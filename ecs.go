@@ -0,0 +1,56 @@
+package errors
+
+import (
+	"fmt"
+)
+
+// ECSFields returns fields that describe the given error using the Elastic Common Schema (ECS), so
+// that errors logged as JSON can be searched in Elasticsearch, and linked to their distributed trace
+// in Elastic APM. The keys are dotted ECS field names, so they can be added to a log entry alongside
+// its other fields, e.g. using a logger's WithFields method.
+//
+// The error's reference ID (see ReferenceID) is "error.id", its kind (see Classify), or the Go type
+// of the error at the bottom of its stack if it doesn't have one, is "error.type", and its message,
+// as returned by Error, is "error.message". Its stack is "error.stack_trace", in the same format as
+// the verbose (%+v) format. If the error has a trace context (see TraceOf), its IDs are "trace.id"
+// and "span.id". If the given error is nil, nil is returned.
+func ECSFields(err error) map[string]interface{} {
+	if err == nil {
+		return nil
+	}
+
+	fields := map[string]interface{}{
+		"error.type":    ecsType(err),
+		"error.message": err.Error(),
+	}
+
+	if ref := ReferenceID(err); ref != "" {
+		fields["error.id"] = ref
+	}
+
+	fields["error.stack_trace"] = fmt.Sprintf("%+v", err)
+
+	if tc, ok := TraceOf(err); ok {
+		fields["trace.id"] = tc.TraceIDHex
+		fields["span.id"] = tc.SpanIDHex
+	}
+
+	return fields
+}
+
+// ecsType returns the ECS error type for the given error; its kind, or the Go type of the error at
+// the bottom of its stack if it doesn't have one.
+func ecsType(err error) string {
+	if kind := Classify(err); kind != "" {
+		return string(kind)
+	}
+
+	for {
+		e, ok := err.(*Error)
+		if !ok || e.Cause == nil {
+			return fmt.Sprintf("%T", err)
+		}
+
+		err = e.Cause
+	}
+}
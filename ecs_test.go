@@ -0,0 +1,50 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestECSFields(t *testing.T) {
+	t.Run("should return nil for a nil error", func(t *testing.T) {
+		assert.Nil(t, ECSFields(nil))
+	})
+
+	t.Run("should describe the error", func(t *testing.T) {
+		ctx := ContextWithTrace(context.Background(), TraceContext{TraceIDHex: testTraceID, SpanIDHex: testSpanID})
+		err := Wrap(NewCtx(ctx, ErrKindTest, "oops"), "wrapped")
+
+		fields := ECSFields(err)
+
+		assert.Equal(t, string(ErrKindTest), fields["error.type"])
+		assert.Equal(t, err.Error(), fields["error.message"])
+		assert.Equal(t, ReferenceID(err), fields["error.id"])
+		assert.Equal(t, fmt.Sprintf("%+v", err), fields["error.stack_trace"])
+		assert.Equal(t, testTraceID, fields["trace.id"])
+		assert.Equal(t, testSpanID, fields["span.id"])
+
+		_, jerr := json.Marshal(fields)
+		require.NoError(t, jerr)
+	})
+
+	t.Run("should use the Go type of errors without a kind", func(t *testing.T) {
+		fields := ECSFields(Wrap(errors.New("oops"), "wrapped"))
+
+		assert.Equal(t, "*errors.errorString", fields["error.type"])
+		assert.NotContains(t, fields, "trace.id")
+		assert.NotContains(t, fields, "span.id")
+	})
+
+	t.Run("should not have an ID for errors that aren't *Error", func(t *testing.T) {
+		fields := ECSFields(errors.New("oops"))
+
+		assert.NotContains(t, fields, "error.id")
+		assert.Equal(t, "oops", fields["error.message"])
+	})
+}
@@ -2,12 +2,10 @@ package errors
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"runtime"
-	"sort"
-	"strconv"
-	"strings"
 )
 
 // Kind is simply a string, but it allows New to function the way it does, and limits what can be
@@ -38,10 +36,32 @@ type Error struct {
 	// caller is the function that was called when this error occurred. Useful for identifying where
 	// an error occurred, or providing information to developers (i.e. this should not be revealed
 	// or used in responses / sent to the front-end). This may be something as simple as the method
-	// name being called, or perhaps include more information to do with parameters.
+	// name being called, or perhaps include more information to do with parameters. The fully
+	// qualified function name is stored, and trimmed when it's output (see SetFullCallerNames).
 	caller string
 
-	// Stack location information.
+	// template is used to build Message lazily, set by passing a MessageTemplate to New or Wrap, or
+	// by using Newf or Wrapf.
+	template *MessageTemplate
+
+	// localMessage is a user-facing message to look up in a Catalog, set by passing a LocalMessage
+	// to New or Wrap.
+	localMessage *LocalMessage
+
+	// reference is the error's reference ID, if it was given one explicitly (see WithReferenceID).
+	reference string
+
+	// generated holds the reference ID generated for this error by ReferenceID. It's allocated when
+	// the error is created, and isn't copied when the error is wrapped, so that wrapping a shared
+	// error doesn't reuse an ID that was generated for it somewhere else.
+	generated *referenceCell
+
+	// trace identifies the distributed trace and span that this error occurred in, set by passing a
+	// context.Context to New or Wrap (see ContextWithTrace).
+	trace TraceContext
+
+	// Stack location information. The file path is stored as it's reported by the runtime, and is
+	// normalised when it's output (see SetPathStyle).
 	file string
 	line int
 }
@@ -58,7 +78,8 @@ func (e *Error) Error() string {
 //
 // %v:  Standard formatting: shows callers, and shows messages, for the whole stack.
 // %+v: Verbose formatting: shows callers, and shows messages, for the whole stack, with file and
-//      line, information, across multiple lines.
+//      line, information, across multiple lines. In development mode, this also includes a snippet
+//      of source code around each line.
 func (e *Error) Format(s fmt.State, c rune) {
 	if c == 'v' && s.Flag('+') {
 		io.WriteString(s, e.format(true))
@@ -106,33 +127,75 @@ func (e *Error) WithField(fieldKey string, fieldValue interface{}) *Error {
 	return e
 }
 
+// Caller returns the name of the function that created this error, trimmed the same way as it is
+// in output (see SetFullCallerNames).
+func (e *Error) Caller() string {
+	return callerName(e.caller)
+}
+
+// message returns this error's message, rendering it from its template if necessary.
+func (e *Error) message() string {
+	if e.Message == "" && e.template != nil {
+		return e.template.render()
+	}
+
+	return e.Message
+}
+
+// text returns the message to show for this error in logs. If this error only has a LocalMessage,
+// the key is shown, as we don't know which language the reader would want.
+func (e *Error) text() string {
+	msg := e.message()
+	if msg == "" && e.localMessage != nil {
+		return e.localMessage.Key
+	}
+
+	return msg
+}
+
 // format returns this error, and all previous errors, as a string. The result can be represented as
-// a multi-line stack-trace by setting `asStack` to true.
+// a multi-line stack-trace by setting `asStack` to true, which is rendered from the error's stack
+// frames, the same way as FormatStack renders them.
 func (e *Error) format(asStack bool) string {
+	if asStack {
+		frames := stack(e, currentPathStyle(), currentFullCallerNames())
+		return formatStack(frames, stackSources(e, len(frames)), hasForeignCause(e))
+	}
+
 	// Buffer is shared between recursive calls to avoid some unnecessary re-allocations.
 	buf := bytes.Buffer{}
 
-	e.formatAccumulator(&buf, asStack, false)
+	e.formatAccumulator(&buf)
 
 	return buf.String()
 }
 
-// formatAccumulator is a recursive error formatting function.
-func (e *Error) formatAccumulator(buf *bytes.Buffer, asStack, isCause bool) {
-	if asStack && !isCause {
-		buf.WriteString("Error")
+// hasForeignCause reports whether the error at the bottom of the given error's stack isn't an *Error.
+func hasForeignCause(e *Error) bool {
+	for e.Cause != nil {
+		cause, ok := e.Cause.(*Error)
+		if !ok {
+			return true
+		}
+
+		e = cause
 	}
 
+	return false
+}
+
+// formatAccumulator is a recursive error formatting function.
+func (e *Error) formatAccumulator(buf *bytes.Buffer) {
 	if e.caller != "" {
 		pad(buf, ": ")
 		buf.WriteString("[")
-		buf.WriteString(e.caller)
+		buf.WriteString(callerName(e.caller))
 		buf.WriteString("]")
 	}
 
-	if e.Message != "" {
+	if text := e.text(); text != "" {
 		pad(buf, ": ")
-		buf.WriteString(e.Message)
+		buf.WriteString(text)
 	}
 
 	if e.Kind != "" {
@@ -142,50 +205,12 @@ func (e *Error) formatAccumulator(buf *bytes.Buffer, asStack, isCause bool) {
 		buf.WriteString(")")
 	}
 
-	if asStack {
-		buf.WriteString("\n")
-		buf.WriteString("    ")
-		buf.WriteString("File: \"")
-		buf.WriteString(e.file)
-		buf.WriteString("\", line ")
-		buf.WriteString(strconv.Itoa(e.line))
-		buf.WriteString("\n")
-
-		if len(e.Fields) > 0 {
-			buf.WriteString("    ")
-			buf.WriteString("With fields:\n")
-
-			fieldKeys := make([]string, 0, len(e.Fields))
-			for k := range e.Fields {
-				fieldKeys = append(fieldKeys, k)
-			}
-
-			sort.Strings(fieldKeys)
-
-			for _, k := range fieldKeys {
-				buf.WriteString("    ")
-				buf.WriteString("- \"")
-				buf.WriteString(k)
-				buf.WriteString("\": ")
-				buf.WriteString(fmt.Sprintf("%v", e.Fields[k]))
-				buf.WriteString("\n")
-			}
-		}
-	}
-
-	if e.Cause != nil {
-		if !asStack {
-		} else {
-			buf.WriteString("Caused by")
-		}
-
-		switch cause := e.Cause.(type) {
-		case *Error:
-			cause.formatAccumulator(buf, asStack, true)
-		case error:
-			pad(buf, ": ")
-			buf.WriteString(cause.Error())
-		}
+	switch cause := e.Cause.(type) {
+	case *Error:
+		cause.formatAccumulator(buf)
+	case error:
+		pad(buf, ": ")
+		buf.WriteString(cause.Error())
 	}
 }
 
@@ -210,7 +235,8 @@ func pad(buf *bytes.Buffer, pad string) {
 //    err = errors.New(err, "accom: fetch failed", errors.WithField("tti_code", ttiCode))
 //
 // As you can see, this usage is flexible, and includes the ability to construct pretty much any
-// kind of error your application should need.
+// kind of error your application should need. If a context.Context is given, any fields stored in
+// it using WithContextFields are added to the error too (see NewCtx).
 func New(args ...interface{}) *Error {
 	err := newError(args...)
 
@@ -226,13 +252,19 @@ func newError(args ...interface{}) *Error {
 		panic("errors: call to errors.New with no arguments")
 	}
 
-	err := &Error{}
+	var ctx context.Context
+
+	err := &Error{generated: &referenceCell{}}
 	for _, arg := range args {
 		switch v := arg.(type) {
 		case Kind:
 			err.Kind = v
 		case string:
 			err.Message = v
+		case *LocalMessage:
+			err.localMessage = v
+		case *MessageTemplate:
+			err.template = v
 		case *Error:
 			// Can't dereference a nil pointer, so bail early. This is a developer error.
 			if v == nil {
@@ -241,16 +273,49 @@ func newError(args ...interface{}) *Error {
 
 			// Make a shallow copy of the value, so that we don't change the original error.
 			cv := *v
+			cv.generated = nil
 			err.Cause = &cv
 		case error:
 			err.Cause = v
 		case map[string]interface{}:
 			err.Fields = v
+		case context.Context:
+			ctx = v
 		default:
 			panic(fmt.Sprintf("errors: bad call to errors.New: unknown type %T, value %v", arg, arg))
 		}
 	}
 
+	// Fields from the context are added once all of the arguments have been seen, so that fields
+	// given explicitly take precedence over them, regardless of the order of the arguments.
+	if ctx != nil {
+		err.addContextFields(ctx)
+		err.trace, _ = TraceFromContext(ctx)
+	}
+
+	// Named template arguments are added as fields once all of the arguments have been seen, so
+	// that they aren't lost if a map of fields is given after the template. The error's fields are
+	// copied first, as they may have been given to New as a map that the caller still holds.
+	if err.template != nil {
+		var named []NamedArg
+		err.template.namedArgs(func(na NamedArg) {
+			named = append(named, na)
+		})
+
+		if len(named) > 0 {
+			fields := make(map[string]interface{}, len(err.Fields)+len(named))
+			for k, v := range err.Fields {
+				fields[k] = v
+			}
+
+			for _, na := range named {
+				fields[na.Name] = na.Value
+			}
+
+			err.Fields = fields
+		}
+	}
+
 	return err
 }
 
@@ -274,8 +339,11 @@ func Wrap(cause error, args ...interface{}) *Error {
 }
 
 // updateCaller takes an error and sets the calling function information on it. Safe to use in error
-// constructors, but no deeper.
+// constructors, but no deeper. As it's the last thing every constructor does, it also passes the
+// finished error to the hook set by SetCreateHook.
 func updateCaller(err *Error) {
+	defer created(err)
+
 	fpcs := make([]uintptr, 1)
 	ptr := runtime.Callers(3, fpcs)
 	if ptr == 0 {
@@ -284,10 +352,7 @@ func updateCaller(err *Error) {
 
 	fun := runtime.FuncForPC(fpcs[0] - 1)
 	if fun != nil {
-		li := strings.LastIndex(fun.Name(), "/") + 1
-
-		funcName := fun.Name()[li:]
-		err.caller = funcName
+		err.caller = fun.Name()
 		err.file, err.line = fun.FileLine(fpcs[0] - 1)
 	}
 }
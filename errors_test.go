@@ -106,7 +106,11 @@ func TestNew(t *testing.T) {
 		cause := New("oops")
 		err := New(cause)
 
-		assert.Equal(t, cause, err.Cause)
+		// The cause is copied, without the reference ID generated for it (see ReferenceID).
+		expected := *cause
+		expected.generated = nil
+
+		assert.Equal(t, &expected, err.Cause)
 	})
 
 	t.Run("should assign the given fields", func(t *testing.T) {
@@ -169,7 +173,11 @@ func TestWrap(t *testing.T) {
 		cause := New("oops")
 		err := Wrap(cause)
 
-		assert.Equal(t, cause, err.Cause)
+		// The cause is copied, without the reference ID generated for it (see ReferenceID).
+		expected := *cause
+		expected.generated = nil
+
+		assert.Equal(t, &expected, err.Cause)
 	})
 
 	t.Run("should assign the given fields", func(t *testing.T) {
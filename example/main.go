@@ -46,7 +46,8 @@ func main() {
 		// well as something passed to a structured logger.
 		spew.Dump(errors.Stack(err))
 
-		// Or if you just want to output something and exit:
+		// Or if you just want to output something and exit (errors.Fprint can print the error in a
+		// more readable, coloured format whilst you're debugging locally):
 		errors.Fatal(err)
 	}
 
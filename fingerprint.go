@@ -0,0 +1,90 @@
+package errors
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// FingerprintOption changes which information is used to produce a fingerprint.
+type FingerprintOption int
+
+const (
+	// FingerprintMessages includes each error's message in the fingerprint. Messages often contain
+	// variable data (e.g. IDs), so they're excluded by default. If a message was built from a
+	// template (see Newf), the template is used instead.
+	FingerprintMessages FingerprintOption = iota + 1
+
+	// FingerprintFieldValues includes the value of each field in the fingerprint. Field values are
+	// almost always variable, so they're excluded by default. Field keys are always included.
+	FingerprintFieldValues
+)
+
+// Fingerprint returns a stable hash identifying "the same error", no matter when, where, or how
+// many times it has occurred. It's useful for grouping and de-duplicating errors, e.g. in alerts.
+//
+// The fingerprint is produced from the same information that Stack exposes; the kind, caller,
+// file, line, and field keys of each error in the stack. File paths are normalised (the same way
+// as PathImport does), so the fingerprint is the same no matter which machine built the binary.
+// Messages and field values may also be included by passing FingerprintOptions.
+func Fingerprint(err error, opts ...FingerprintOption) string {
+	if err == nil {
+		return ""
+	}
+
+	var withMessages, withFieldValues bool
+	for _, opt := range opts {
+		switch opt {
+		case FingerprintMessages:
+			withMessages = true
+		case FingerprintFieldValues:
+			withFieldValues = true
+		}
+	}
+
+	h := sha256.New()
+
+	// Each part is terminated so that different stacks can't produce the same input to the hash just
+	// by moving characters between adjacent parts.
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+
+	for _, frame := range stack(err, PathImport, true) {
+		write(frame.Kind)
+		write(frame.Caller)
+		write(frame.File)
+		write(strconv.Itoa(frame.Line))
+
+		if withMessages {
+			// Templates don't contain variable data, so they're preferred over rendered messages.
+			if frame.Template != "" {
+				write(frame.Template)
+			} else {
+				write(frame.Message)
+			}
+		}
+
+		keys := make([]string, 0, len(frame.Fields))
+		for k := range frame.Fields {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			write(k)
+
+			if withFieldValues {
+				write(fmt.Sprintf("%v", frame.Fields[k]))
+			}
+		}
+
+		h.Write([]byte{0x1e})
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -0,0 +1,75 @@
+package errors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	// newUserError produces an error from the same place every time it's called, as if it was
+	// returned from some function in an application.
+	newUserError := func(id int, cause error) error {
+		return Wrap(cause, ErrKindTest, "user not found").WithField("user_id", id)
+	}
+
+	t.Run("should return an empty fingerprint for a nil error", func(t *testing.T) {
+		assert.Equal(t, "", Fingerprint(nil))
+	})
+
+	t.Run("should return a short hex string", func(t *testing.T) {
+		assert.Regexp(t, "^[0-9a-f]{16}$", Fingerprint(New("oops")))
+	})
+
+	t.Run("should be the same for errors from the same place", func(t *testing.T) {
+		err1 := newUserError(1, errors.New("no rows for user 1"))
+		err2 := newUserError(2, errors.New("no rows for user 2"))
+
+		assert.Equal(t, Fingerprint(err1), Fingerprint(err2))
+	})
+
+	t.Run("should be different for errors from different places", func(t *testing.T) {
+		err1 := New("oops")
+		err2 := New("oops")
+
+		assert.NotEqual(t, Fingerprint(err1), Fingerprint(err2))
+	})
+
+	t.Run("should be different for errors of different kinds", func(t *testing.T) {
+		var fps []string
+		for _, kind := range []Kind{"kind 1", "kind 2"} {
+			fps = append(fps, Fingerprint(New(kind)))
+		}
+
+		assert.NotEqual(t, fps[0], fps[1])
+	})
+
+	t.Run("should optionally include messages", func(t *testing.T) {
+		err1 := newUserError(1, errors.New("no rows for user 1"))
+		err2 := newUserError(1, errors.New("no rows for user 2"))
+
+		assert.Equal(t, Fingerprint(err1), Fingerprint(err2))
+		assert.NotEqual(t, Fingerprint(err1, FingerprintMessages), Fingerprint(err2, FingerprintMessages))
+	})
+
+	t.Run("should optionally include field values", func(t *testing.T) {
+		cause := errors.New("no rows")
+
+		err1 := newUserError(1, cause)
+		err2 := newUserError(2, cause)
+
+		assert.Equal(t, Fingerprint(err1, FingerprintMessages), Fingerprint(err2, FingerprintMessages))
+		assert.NotEqual(t, Fingerprint(err1, FingerprintFieldValues), Fingerprint(err2, FingerprintFieldValues))
+	})
+
+	t.Run("should not depend on the path style", func(t *testing.T) {
+		err := New("oops")
+		fp := Fingerprint(err)
+
+		SetPathStyle(PathModule)
+		defer SetPathStyle(PathAbsolute)
+
+		assert.Equal(t, fp, Fingerprint(err))
+	})
+}
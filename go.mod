@@ -11,4 +11,5 @@ require (
 	github.com/gogo/protobuf v1.2.1 // indirect
 	github.com/pkg/errors v0.8.1 // indirect
 	github.com/stretchr/testify v1.3.0
+	gopkg.in/yaml.v2 v2.2.8
 )
@@ -0,0 +1,33 @@
+package errors
+
+import (
+	"sync/atomic"
+)
+
+// createHook holds the function set by SetCreateHook. It's called every time an error is created,
+// so it's accessed atomically rather than being guarded by a lock.
+var createHook atomic.Value
+
+// createHookFunc wraps the function set by SetCreateHook, as atomic.Value can't store nil.
+type createHookFunc struct {
+	fn func(err *Error)
+}
+
+// SetCreateHook sets a function that's called every time an error is created, by New, Wrap, or any
+// of the other constructors in this package, once the error is complete. It's useful for gathering
+// statistics about errors (see the metrics package), without changing every place that errors are
+// created. Errors that wrap another *Error are passed to the hook too, so the hook should check the
+// error's cause if it only wants to see each error once. Passing nil removes the hook.
+//
+// The hook is called synchronously by the goroutine creating the error, so it should be fast, and
+// it must be safe to call concurrently. It must not change the error.
+func SetCreateHook(fn func(err *Error)) {
+	createHook.Store(createHookFunc{fn: fn})
+}
+
+// created calls the hook set by SetCreateHook with the given error, if a hook has been set.
+func created(err *Error) {
+	if hook, ok := createHook.Load().(createHookFunc); ok && hook.fn != nil {
+		hook.fn(err)
+	}
+}
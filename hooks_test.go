@@ -0,0 +1,61 @@
+package errors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetCreateHook(t *testing.T) {
+	defer SetCreateHook(nil)
+
+	t.Run("should be called with every error created", func(t *testing.T) {
+		var errs []*Error
+		SetCreateHook(func(err *Error) {
+			errs = append(errs, err)
+		})
+
+		err1 := New("oops")
+		err2 := Wrap(err1, "wrapped")
+		err3 := Newf("formatted %d", 1)
+
+		require.Len(t, errs, 3)
+		assert.Equal(t, err1, errs[0])
+		assert.Equal(t, err2, errs[1])
+		assert.Equal(t, err3, errs[2])
+	})
+
+	t.Run("should be called once the error is complete", func(t *testing.T) {
+		var caller string
+		SetCreateHook(func(err *Error) {
+			caller = err.Caller()
+		})
+
+		New("oops")
+
+		assert.Equal(t, "go-errors.TestSetCreateHook.func2", caller)
+	})
+
+	t.Run("should not be called once removed", func(t *testing.T) {
+		var calls int
+		SetCreateHook(func(err *Error) {
+			calls++
+		})
+
+		SetCreateHook(nil)
+		New("oops")
+
+		assert.Equal(t, 0, calls)
+	})
+}
+
+func TestError_Caller(t *testing.T) {
+	err := New("oops")
+	assert.Equal(t, "go-errors.TestError_Caller", err.Caller())
+
+	SetFullCallerNames(true)
+	defer SetFullCallerNames(false)
+
+	assert.Equal(t, "github.com/icelolly/go-errors.TestError_Caller", err.Caller())
+}
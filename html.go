@@ -0,0 +1,199 @@
+package errors
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// htmlPreviewLength is the maximum length of the preview of a field's value shown before the value
+// is expanded.
+const htmlPreviewLength = 60
+
+// htmlPage is the template used by FprintHTML. All values are escaped by html/template, including
+// URLs, which are also checked for unsafe schemes.
+var htmlPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; padding: 2rem; font: 14px/1.5 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #24292e; background: #f6f8fa; }
+code, pre { font: 13px/1.45 SFMono-Regular, Consolas, Menlo, monospace; }
+h1 { margin: 0 0 1.5rem; font-size: 1.5rem; color: #cb2431; }
+.frame { margin-bottom: 1rem; padding: 1rem 1.25rem; background: #fff; border: 1px solid #e1e4e8; border-radius: 6px; }
+.frame h2 { margin: 0; font-size: 1rem; }
+.label { color: #6a737d; font-weight: normal; }
+.kind { margin-left: .5rem; padding: .1rem .4rem; background: #fff5b1; border-radius: 3px; font-size: .85rem; }
+.caller { color: #005cc5; }
+.location { margin: .25rem 0 0; color: #6a737d; }
+.location a { color: inherit; }
+.snippet { margin: .75rem 0 0; padding: .5rem 0; background: #f6f8fa; border-radius: 3px; overflow-x: auto; }
+.snippet span { display: block; padding: 0 .75rem; color: #6a737d; white-space: pre; }
+.snippet .current { background: #ffeef0; color: #24292e; font-weight: bold; }
+.fields { margin-top: .75rem; }
+.fields summary { cursor: pointer; }
+.fields summary .preview { color: #6a737d; }
+.fields pre { margin: .25rem 0 .5rem 1rem; padding: .5rem .75rem; background: #f6f8fa; border-radius: 3px; overflow-x: auto; }
+.reference { color: #6a737d; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{- if .ReferenceID}}
+<p class="reference">Reference: <code>{{.ReferenceID}}</code></p>
+{{- end}}
+{{- range .Frames}}
+<section class="frame">
+<h2>
+<span class="label">{{.Label}}</span>
+{{- if .Caller}} <code class="caller">[{{.Caller}}]</code>{{end}}
+{{- if .Message}} {{.Message}}{{end}}
+{{- if .Kind}} <code class="kind">{{.Kind}}</code>{{end}}
+</h2>
+{{- if .Location}}
+<p class="location"><code>{{if .URL}}<a href="{{.URL}}">{{.Location}}</a>{{else}}{{.Location}}{{end}}</code></p>
+{{- end}}
+{{- if .Snippet}}
+<pre class="snippet">{{range .Snippet}}<span{{if .Current}} class="current"{{end}}>{{.Number}} | {{.Text}}</span>{{end}}</pre>
+{{- end}}
+{{- if .Fields}}
+<div class="fields">
+{{- range .Fields}}
+<details>
+<summary><code>{{.Key}}</code> <span class="preview">{{.Preview}}</span></summary>
+<pre>{{.Value}}</pre>
+</details>
+{{- end}}
+</div>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+// htmlData is the data rendered by htmlPage.
+type htmlData struct {
+	Title       string
+	ReferenceID string
+	Frames      []htmlFrame
+}
+
+// htmlFrame is a single stack frame, as rendered by htmlPage.
+type htmlFrame struct {
+	Label    string
+	Kind     string
+	Message  string
+	Caller   string
+	Location string
+	URL      string
+	Snippet  []sourceLine
+	Fields   []htmlField
+}
+
+// htmlField is a single field, as rendered by htmlPage.
+type htmlField struct {
+	Key     string
+	Preview string
+	Value   string
+}
+
+// FprintHTML writes an HTML page to w that shows the given error and all of its causes, including
+// each error's kind, message, caller, file and line, a snippet of source code, and its fields. The
+// page is designed to be shown in a browser whilst developing a web application locally. Source code
+// is always shown, regardless of whether development mode is enabled, so it should never be shown
+// to users in production.
+func FprintHTML(w io.Writer, err error) error {
+	stack := Stack(err)
+
+	return fprintHTML(w, stack, stackSources(err, len(stack)))
+}
+
+// FprintStackHTML works the same way as FprintHTML, but renders the given stack frames. This is
+// useful if you have a stack that has already been produced by Stack, e.g. one that has been decoded
+// from JSON.
+func FprintStackHTML(w io.Writer, stack []StackFrame) error {
+	return fprintHTML(w, stack, frameSources(stack))
+}
+
+// fprintHTML renders the given stack frames, using the given source file paths to find snippets.
+func fprintHTML(w io.Writer, stack []StackFrame, sources []string) error {
+	data := htmlData{
+		Title:  "Error",
+		Frames: make([]htmlFrame, len(stack)),
+	}
+
+	if len(stack) > 0 {
+		data.ReferenceID = stack[0].ReferenceID
+
+		if stack[0].Message != "" {
+			data.Title = stack[0].Message
+		} else if stack[0].Kind != "" {
+			data.Title = stack[0].Kind
+		}
+	}
+
+	for i, frame := range stack {
+		hf := htmlFrame{
+			Label:   "Error",
+			Kind:    frame.Kind,
+			Message: frame.Message,
+			Caller:  frame.Caller,
+			URL:     frame.URL,
+			Fields:  htmlFields(frame.Fields),
+		}
+
+		if i > 0 {
+			hf.Label = "Caused by"
+		}
+
+		if frame.File != "" {
+			hf.Location = frame.File + ":" + strconv.Itoa(frame.Line)
+			hf.Snippet = sourceSnippet(sources[i], frame.Line)
+		}
+
+		data.Frames[i] = hf
+	}
+
+	return htmlPage.Execute(w, data)
+}
+
+// htmlFields returns the given fields, sorted by key, with their values formatted for display.
+func htmlFields(fields map[string]interface{}) []htmlField {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	hfs := make([]htmlField, len(keys))
+	for i, k := range keys {
+		value := prettyValue(fields[k])
+
+		preview := value
+		if idx := strings.IndexByte(preview, '\n'); idx >= 0 {
+			preview = preview[:idx] + " …"
+		}
+
+		if runes := []rune(preview); len(runes) > htmlPreviewLength {
+			preview = string(runes[:htmlPreviewLength]) + "…"
+		}
+
+		hfs[i] = htmlField{
+			Key:     k,
+			Preview: preview,
+			Value:   value,
+		}
+	}
+
+	return hfs
+}
@@ -0,0 +1,99 @@
+package errors
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFprintHTML(t *testing.T) {
+	t.Run("should render every frame", func(t *testing.T) {
+		err := Wrap(New("database went down", Kind("html test: db")), "<script>alert(1)</script>")
+		ref := ReferenceID(err)
+
+		buf := bytes.Buffer{}
+		require.NoError(t, FprintHTML(&buf, err))
+
+		out := buf.String()
+		assert.True(t, strings.HasPrefix(out, "<!DOCTYPE html>"))
+		assert.Equal(t, 2, strings.Count(out, `<section class="frame">`))
+		assert.Contains(t, out, `<span class="label">Caused by</span>`)
+		assert.Contains(t, out, `<code class="kind">html test: db</code>`)
+		assert.Contains(t, out, "TestFprintHTML.func1]")
+		assert.Contains(t, out, "html_test.go:")
+		assert.Contains(t, out, ref)
+	})
+
+	t.Run("should escape values", func(t *testing.T) {
+		err := New("<script>alert(1)</script>").WithField("<b>key</b>", "<i>value</i>")
+
+		buf := bytes.Buffer{}
+		require.NoError(t, FprintHTML(&buf, err))
+
+		out := buf.String()
+		assert.NotContains(t, out, "<script>alert(1)</script>")
+		assert.NotContains(t, out, "<b>key</b>")
+		assert.NotContains(t, out, "<i>value</i>")
+		assert.Contains(t, out, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	})
+
+	t.Run("should include a source snippet", func(t *testing.T) {
+		err := New("oops") // This line should be in the snippet.
+
+		buf := bytes.Buffer{}
+		require.NoError(t, FprintHTML(&buf, err))
+
+		assert.Contains(t, buf.String(), `class="current"`)
+		assert.Contains(t, buf.String(), "This line should be in the snippet.")
+	})
+
+	t.Run("should render fields as collapsible values", func(t *testing.T) {
+		err := New("oops").WithFields(
+			"user", map[string]interface{}{"name": "Elliot"},
+			"long", strings.Repeat("x", 100),
+		)
+
+		buf := bytes.Buffer{}
+		require.NoError(t, FprintHTML(&buf, err))
+
+		out := buf.String()
+		assert.Equal(t, 2, strings.Count(out, "<details>"))
+		assert.Contains(t, out, `<span class="preview">{ …</span>`)
+		assert.Contains(t, out, `<span class="preview">`+strings.Repeat("x", htmlPreviewLength)+`…</span>`)
+		assert.Contains(t, out, "&#34;name&#34;: &#34;Elliot&#34;")
+	})
+}
+
+func TestFprintStackHTML(t *testing.T) {
+	t.Run("should not link to unsafe URLs", func(t *testing.T) {
+		stack := []StackFrame{{
+			Message: "oops",
+			File:    "main.go",
+			Line:    1,
+			URL:     "javascript:alert(1)",
+		}}
+
+		buf := bytes.Buffer{}
+		require.NoError(t, FprintStackHTML(&buf, stack))
+
+		assert.NotContains(t, buf.String(), "javascript:")
+		assert.Contains(t, buf.String(), "main.go:1")
+	})
+
+	t.Run("should link to source URLs", func(t *testing.T) {
+		stack := []StackFrame{{
+			Message: "oops",
+			File:    "main.go",
+			Line:    1,
+			URL:     "https://example.com/main.go#L1",
+		}}
+
+		buf := bytes.Buffer{}
+		require.NoError(t, FprintStackHTML(&buf, stack))
+
+		assert.Contains(t, buf.String(), `<a href="https://example.com/main.go#L1">main.go:1</a>`)
+	})
+}
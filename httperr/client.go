@@ -0,0 +1,267 @@
+// Package httperr converts between errors and HTTP responses. On the client side, error responses
+// are turned into errors whose kind comes from the response, so that errors.Is works across service
+// boundaries.
+//
+// Example usage:
+//
+//    client := &http.Client{
+//        Transport: &httperr.Transport{},
+//    }
+//
+//    resp, err := client.Get("https://users.example.com/users/42")
+//    if errors.Is(err, kinds.NotFound) {
+//        // ...
+//    }
+//
+package httperr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+)
+
+// DefaultMaxBodySize is the default number of bytes of an error response's body that are added to
+// the error as the "body" field.
+const DefaultMaxBodySize = 1024
+
+// maxProblemSize is the maximum number of bytes of an error response's body that will be read when
+// looking for a problem details document.
+const maxProblemSize = 64 << 10
+
+// maxDrainSize is the maximum number of bytes of an error response's body that will be discarded
+// before it's closed, so that the connection can be reused. Connections with longer bodies are
+// just closed, as reading them would take longer than opening a new one.
+const maxDrainSize = 256 << 10
+
+// statusKinds maps HTTP status codes to the standard kind for errors with that status.
+var statusKinds = map[int]errors.Kind{
+	http.StatusBadRequest:                   kinds.InvalidArgument,
+	http.StatusUnauthorized:                 kinds.Unauthenticated,
+	http.StatusForbidden:                    kinds.PermissionDenied,
+	http.StatusNotFound:                     kinds.NotFound,
+	http.StatusMethodNotAllowed:             kinds.Unimplemented,
+	http.StatusRequestTimeout:               kinds.DeadlineExceeded,
+	http.StatusConflict:                     kinds.Conflict,
+	http.StatusGone:                         kinds.NotFound,
+	http.StatusPreconditionFailed:           kinds.FailedPrecondition,
+	http.StatusRequestEntityTooLarge:        kinds.OutOfRange,
+	http.StatusRequestedRangeNotSatisfiable: kinds.OutOfRange,
+	http.StatusUnprocessableEntity:          kinds.InvalidArgument,
+	http.StatusTooManyRequests:              kinds.ResourceExhausted,
+	499:                                     kinds.Canceled,
+	http.StatusInternalServerError:          kinds.Internal,
+	http.StatusNotImplemented:               kinds.Unimplemented,
+	http.StatusBadGateway:                   kinds.Unavailable,
+	http.StatusServiceUnavailable:           kinds.Unavailable,
+	http.StatusGatewayTimeout:               kinds.DeadlineExceeded,
+}
+
+// KindForStatus returns the standard kind for the given HTTP status code. Statuses without a more
+// specific kind are InvalidArgument if they're a client error, or Unknown otherwise.
+func KindForStatus(status int) errors.Kind {
+	if kind, ok := statusKinds[status]; ok {
+		return kind
+	}
+
+	if status >= 400 && status < 500 {
+		return kinds.InvalidArgument
+	}
+
+	return kinds.Unknown
+}
+
+// Transport is an http.RoundTripper that returns an error for error responses (i.e. responses with
+// a status code of 400 or above), built using FromResponse, rather than returning the response.
+// Errors from the underlying transport are wrapped, and classified (see errors.WrapClassified), so
+// that e.g. timeouts can be handled the same way whether they happened locally or remotely. If the
+// request's context has a trace context, and the request has no traceparent header, one is added.
+type Transport struct {
+	// Base is the transport used to make requests. If it's nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// MaxBodySize is the number of bytes of an error response's body that are added to the error as
+	// the "body" field. If it's zero, DefaultMaxBodySize is used. If it's negative, the body isn't
+	// added at all.
+	MaxBodySize int
+}
+
+// RoundTrip makes the given request, returning an error for error responses.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	req = withTraceparent(req)
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, errors.WrapClassified(err, "httperr: request failed").WithFields(
+			"method", req.Method,
+			"url", redactURL(req.URL),
+		)
+	}
+
+	maxBodySize := t.MaxBodySize
+	if maxBodySize == 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+
+	if rerr := fromResponse(resp, maxBodySize); rerr != nil {
+		// The body is read so that the connection can be reused.
+		io.CopyN(ioutil.Discard, resp.Body, maxDrainSize)
+		resp.Body.Close()
+		return nil, rerr
+	}
+
+	return resp, nil
+}
+
+// FromResponse returns an error for the given response, or nil if the response isn't an error
+// response (i.e. if its status code is below 400). The error's kind is taken from the response's
+// problem details document (see RFC 7807), if it has one whose type was built from a registered
+// kind (see errors.RegisterKind, and Responder.ProblemTypeBase), otherwise it's the standard kind
+// for the response's status code (see KindForStatus). The error's message is the problem's detail
+// or title, and the "method", "url", "status", and "body" fields are set. The body is truncated to
+// DefaultMaxBodySize bytes.
+//
+// The response's body is read, but can still be read again afterwards by the caller. The caller is
+// still responsible for closing it.
+func FromResponse(resp *http.Response) *errors.Error {
+	return fromResponse(resp, DefaultMaxBodySize)
+}
+
+// fromResponse returns an error for the given response, with the "body" field truncated to the
+// given number of bytes.
+func fromResponse(resp *http.Response, maxBodySize int) *errors.Error {
+	if resp == nil || resp.StatusCode < 400 {
+		return nil
+	}
+
+	body := readBody(resp)
+
+	kind := KindForStatus(resp.StatusCode)
+
+	var prob problem
+	if isProblem(resp.Header.Get("Content-Type")) && json.Unmarshal(body, &prob) == nil {
+		if k, ok := problemKind(prob.Type); ok {
+			kind = k
+		}
+	}
+
+	err := errors.New(kind, prob.message()).WithField("status", resp.StatusCode)
+
+	if req := resp.Request; req != nil {
+		err.WithFields(
+			"method", req.Method,
+			"url", redactURL(req.URL),
+		)
+	}
+
+	if maxBodySize > 0 && len(body) > 0 {
+		if len(body) > maxBodySize {
+			body = body[:maxBodySize]
+		}
+
+		err.WithField("body", string(body))
+	}
+
+	if prob.ReferenceID != "" {
+		err.WithReferenceID(prob.ReferenceID)
+	}
+
+	return err
+}
+
+// problemKind returns the registered kind that the given problem type was built from (see
+// Responder.ProblemTypeBase), which is the last segment of its path, unescaped.
+func problemKind(typ string) (errors.Kind, bool) {
+	if typ == "" || typ == "about:blank" {
+		return "", false
+	}
+
+	segment, err := url.PathUnescape(typ[strings.LastIndex(typ, "/")+1:])
+	if err != nil {
+		return "", false
+	}
+
+	kind := errors.Kind(segment)
+	if _, ok := errors.LookupKind(kind); !ok {
+		return "", false
+	}
+
+	return kind, true
+}
+
+// readBody reads the start of the given response's body, replacing the body so that what has been
+// read can be read again.
+func readBody(resp *http.Response) []byte {
+	if resp.Body == nil {
+		return nil
+	}
+
+	// An error reading the body just means there's less to show, so it's ignored.
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxProblemSize))
+
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: io.MultiReader(bytes.NewReader(body), resp.Body),
+		Closer: resp.Body,
+	}
+
+	return body
+}
+
+// isProblem reports whether the given content type is for a problem details document.
+func isProblem(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == ProblemContentType
+}
+
+// withTraceparent returns the given request with a traceparent header for the trace context in its
+// context, if it has one, and doesn't already have the header. The request is copied rather than
+// changed, as round trippers must not modify requests.
+func withTraceparent(req *http.Request) *http.Request {
+	if req.Header.Get(TraceparentHeader) != "" {
+		return req
+	}
+
+	tc, ok := errors.TraceFromContext(req.Context())
+	if !ok {
+		return req
+	}
+
+	clone := *req
+	clone.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		clone.Header[k] = v
+	}
+
+	InjectTrace(clone.Header, tc)
+
+	return &clone
+}
+
+// redactURL returns the given URL as a string, without any user information, as it may contain a
+// password.
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	redacted := *u
+	redacted.User = nil
+
+	return redacted.String()
+}
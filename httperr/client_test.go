@@ -0,0 +1,254 @@
+package httperr
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKindForStatus(t *testing.T) {
+	assert.Equal(t, kinds.NotFound, KindForStatus(http.StatusNotFound))
+	assert.Equal(t, kinds.Unavailable, KindForStatus(http.StatusServiceUnavailable))
+	assert.Equal(t, kinds.InvalidArgument, KindForStatus(http.StatusTeapot))
+	assert.Equal(t, kinds.Unknown, KindForStatus(599))
+}
+
+func TestFromResponse(t *testing.T) {
+	t.Run("should return nil for successful responses", func(t *testing.T) {
+		srv := httptest.NewServer(respond(http.StatusOK, "text/plain", "ok"))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Nil(t, FromResponse(resp))
+		assert.Nil(t, FromResponse(nil))
+	})
+
+	t.Run("should use the kind for the status code", func(t *testing.T) {
+		srv := httptest.NewServer(respond(http.StatusNotFound, "text/plain", "no such user"))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL + "/users/42")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		rerr := FromResponse(resp)
+		require.NotNil(t, rerr)
+
+		assert.True(t, errors.Is(rerr, kinds.NotFound))
+		assert.Equal(t, map[string]interface{}{
+			"status": http.StatusNotFound,
+			"method": http.MethodGet,
+			"url":    srv.URL + "/users/42",
+			"body":   "no such user",
+		}, rerr.Fields)
+	})
+
+	t.Run("should leave the body readable", func(t *testing.T) {
+		srv := httptest.NewServer(respond(http.StatusBadRequest, "text/plain", "bad input"))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.NotNil(t, FromResponse(resp))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "bad input", string(body))
+	})
+
+	t.Run("should truncate the body", func(t *testing.T) {
+		srv := httptest.NewServer(respond(http.StatusInternalServerError, "text/plain", strings.Repeat("x", 2000)))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		rerr := FromResponse(resp)
+		require.NotNil(t, rerr)
+		assert.Len(t, rerr.Fields["body"], DefaultMaxBodySize)
+	})
+
+	t.Run("should use the problem's kind, message, and reference ID", func(t *testing.T) {
+		kind := kinds.Derive(kinds.NotFound, "httperr test: user not found")
+
+		srv := httptest.NewServer(respond(http.StatusNotFound, ProblemContentType+"; charset=utf-8", `{
+			"type": "httperr test: user not found",
+			"title": "Not Found",
+			"detail": "We couldn't find that user.",
+			"reference_id": "0123456789ABCD"
+		}`))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		rerr := FromResponse(resp)
+		require.NotNil(t, rerr)
+
+		assert.True(t, errors.Is(rerr, kind))
+		assert.True(t, errors.Is(rerr, kinds.NotFound))
+		assert.Equal(t, "We couldn't find that user.", errors.Message(rerr))
+		assert.Equal(t, "0123456789ABCD", errors.ReferenceID(rerr))
+	})
+
+	t.Run("should find the kind in URI problem types", func(t *testing.T) {
+		srv := httptest.NewServer(respond(http.StatusNotFound, ProblemContentType, `{
+			"type": "https://errors.example.com/not%20found",
+			"title": "Not Found"
+		}`))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		rerr := FromResponse(resp)
+		require.NotNil(t, rerr)
+
+		assert.True(t, errors.Is(rerr, kinds.NotFound))
+	})
+
+	t.Run("should ignore problem types that aren't registered kinds", func(t *testing.T) {
+		srv := httptest.NewServer(respond(http.StatusConflict, ProblemContentType, `{
+			"type": "https://example.com/problems/out-of-credit",
+			"title": "You do not have enough credit."
+		}`))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		rerr := FromResponse(resp)
+		require.NotNil(t, rerr)
+
+		assert.True(t, errors.Is(rerr, kinds.Conflict))
+		assert.Equal(t, "You do not have enough credit.", errors.Message(rerr))
+	})
+}
+
+func TestTransport(t *testing.T) {
+	t.Run("should return successful responses", func(t *testing.T) {
+		srv := httptest.NewServer(respond(http.StatusOK, "text/plain", "ok"))
+		defer srv.Close()
+
+		client := &http.Client{Transport: &Transport{}}
+
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", string(body))
+	})
+
+	t.Run("should return an error for error responses", func(t *testing.T) {
+		srv := httptest.NewServer(respond(http.StatusServiceUnavailable, "text/plain", "down"))
+		defer srv.Close()
+
+		client := &http.Client{Transport: &Transport{MaxBodySize: -1}}
+
+		resp, err := client.Get(srv.URL)
+		require.Error(t, err)
+		assert.Nil(t, resp)
+
+		assert.True(t, errors.Is(err, kinds.Unavailable))
+		assert.NotContains(t, errors.Fields(unwrap(err)), "body")
+	})
+
+	t.Run("should drain and close the body of error responses", func(t *testing.T) {
+		body := &trackingBody{Reader: strings.NewReader(strings.Repeat("x", maxProblemSize*2))}
+
+		client := &http.Client{Transport: &Transport{
+			Base: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusInternalServerError,
+					Header:     http.Header{"Content-Type": {"text/plain"}},
+					Body:       body,
+					Request:    r,
+				}, nil
+			}),
+		}}
+
+		_, err := client.Get("http://example.com")
+		require.Error(t, err)
+
+		assert.True(t, body.closed)
+		assert.Equal(t, 0, body.Len())
+	})
+
+	t.Run("should classify transport errors", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+
+		client := &http.Client{Transport: &Transport{}}
+
+		_, err = client.Do(req.WithContext(ctx))
+		require.Error(t, err)
+
+		assert.True(t, errors.Is(err, kinds.DeadlineExceeded))
+		assert.Equal(t, http.MethodGet, errors.Fields(unwrap(err))["method"])
+	})
+}
+
+// roundTripperFunc is an http.RoundTripper that calls itself.
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// trackingBody is a response body that records whether it has been closed.
+type trackingBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+// respond returns a handler that responds with the given status, content type, and body.
+func respond(status int, contentType, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+}
+
+// unwrap returns the error wrapped by an error returned from http.Client.
+func unwrap(err error) error {
+	if uerr, ok := err.(*url.Error); ok {
+		return uerr.Err
+	}
+
+	return err
+}
@@ -0,0 +1,300 @@
+package httperr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+)
+
+// DefaultRequestIDHeader is the header that request IDs are read from by default.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// Kinds of errors for panics recovered by a Responder. Both are derived from kinds.Internal. These
+// errors have no message of their own, so users see the message for kinds.Internal.
+var (
+	ErrPanic = kinds.Derive(kinds.Internal, "httperr: recovered from panic")
+	ErrFatal = kinds.Derive(kinds.Internal, "httperr: recovered from fatal error")
+)
+
+// fatalPrefix is the prefix of the value that errors.Fatal panics with.
+const fatalPrefix = "fatal error: "
+
+// HandlerFunc is an HTTP handler that returns an error, rather than writing an error response
+// itself. Errors are written using DefaultResponder.
+//
+// Example usage:
+//
+//    http.Handle("/users", httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+//        user, err := findUser(r.Context(), r.URL.Query().Get("id"))
+//        if err != nil {
+//            return errors.Wrap(err, "users: failed to find user")
+//        }
+//
+//        return json.NewEncoder(w).Encode(user)
+//    }))
+//
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP calls fn, writing an error response using DefaultResponder if it returns an error, or
+// panics.
+func (fn HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	DefaultResponder.Handle(fn).ServeHTTP(w, r)
+}
+
+// Recover returns middleware that recovers from panics in the given handler, and writes an error
+// response using DefaultResponder.
+func Recover(next http.Handler) http.Handler {
+	return DefaultResponder.Middleware(next)
+}
+
+// Respond writes an error response for the given error using DefaultResponder.
+func Respond(w http.ResponseWriter, r *http.Request, err error) {
+	DefaultResponder.Respond(w, r, err)
+}
+
+// DefaultResponder is the Responder used by HandlerFunc, Recover, and Respond.
+var DefaultResponder = &Responder{}
+
+// Responder writes error responses. The response's status code is picked using the error's kind
+// (see Status), and its body is a problem details document, plain text, or HTML, depending on the
+// request's Accept header. In development mode (see errors.SetDevelopmentMode), HTML responses show
+// the whole error stack instead, including source code and fields (see errors.FprintHTML). The zero
+// value is ready to use.
+type Responder struct {
+	// Log is called with every error that a response is written for, along with the response's
+	// status code. The error has the request's method, path, and ID (if it has one) as fields, as
+	// well as any fields stored in the request's context (see errors.WithContextFields). If
+	// Log is nil, errors with a status code of 500 or above are logged, with their whole stack,
+	// using the standard logger.
+	Log func(r *http.Request, status int, err error)
+
+	// RequestIDHeader is the header that request IDs are read from. If it's empty,
+	// DefaultRequestIDHeader is used.
+	RequestIDHeader string
+
+	// Language returns the language that messages should be written in for the given request (see
+	// errors.MessageFor). If it's nil, the language is picked using the request's Accept-Language
+	// header, from the languages in the catalog (see errors.NegotiateLanguage).
+	Language func(r *http.Request) string
+
+	// ProblemTypeBase is the base URI that the types of problem details documents are built from,
+	// e.g. "https://errors.example.com/", which gives types like
+	// "https://errors.example.com/not%20found" for errors of a standard kind (see kinds.All).
+	// Clients using FromResponse, or Transport, turn responses back into errors of the same kind.
+	// If it's empty, every problem's type is "about:blank", and clients use the kind for the
+	// response's status code instead.
+	ProblemTypeBase string
+}
+
+// Handle returns an http.Handler that calls fn, writing an error response if it returns an error,
+// or panics.
+func (rs *Responder) Handle(fn HandlerFunc) http.Handler {
+	return rs.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(w, r); err != nil {
+			rs.Respond(w, r, err)
+		}
+	}))
+}
+
+// Middleware returns middleware that recovers from panics in the given handler, and writes an error
+// response for them. Panics with http.ErrAbortHandler are not recovered, as they're used to abort a
+// response on purpose. A child of the trace context from the request's traceparent header is stored
+// in the request's context (see errors.ContextWithTrace), so errors created with it have its IDs.
+func (rs *Responder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackingWriter{ResponseWriter: w}
+		r = withTrace(r)
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			rs.Respond(tw, r, recovered(v))
+		}()
+
+		next.ServeHTTP(tw, r)
+	})
+}
+
+// Respond writes an error response for the given error. If a response has already been started by
+// a handler wrapped by Handle or Middleware, the error is only logged.
+func (rs *Responder) Respond(w http.ResponseWriter, r *http.Request, err error) {
+	if err == nil {
+		return
+	}
+
+	e := errors.WrapCtx(r.Context(), err).WithFields(
+		"method", r.Method,
+		"path", r.URL.Path,
+	)
+
+	if id := r.Header.Get(rs.requestIDHeader()); id != "" {
+		e.WithField("request_id", id)
+	}
+
+	status := Status(e)
+
+	rs.log(r, status, e)
+
+	if tw, ok := w.(*trackingWriter); ok && tw.written {
+		return
+	}
+
+	// A negotiated language depends on the request's Accept-Language header, so caches need to
+	// know that the response does too.
+	if rs.Language == nil {
+		w.Header().Add("Vary", "Accept-Language")
+	}
+
+	render(w, r, status, e, rs.language(r), rs.ProblemTypeBase)
+}
+
+// log calls the Log function, or logs the given error using the standard logger.
+func (rs *Responder) log(r *http.Request, status int, err error) {
+	if rs.Log != nil {
+		rs.Log(r, status, err)
+		return
+	}
+
+	if status >= http.StatusInternalServerError {
+		log.Printf(
+			"httperr: %d %s %s (reference: %s): %+v",
+			status, r.Method, r.URL.Path, errors.ReferenceID(err), err,
+		)
+	}
+}
+
+// language returns the language that messages should be written in for the given request.
+func (rs *Responder) language(r *http.Request) string {
+	if rs.Language != nil {
+		return rs.Language(r)
+	}
+
+	return errors.NegotiateLanguage(r.Header.Get("Accept-Language"))
+}
+
+// requestIDHeader returns the header that request IDs are read from.
+func (rs *Responder) requestIDHeader() string {
+	if rs.RequestIDHeader == "" {
+		return DefaultRequestIDHeader
+	}
+
+	return rs.RequestIDHeader
+}
+
+// Status returns the HTTP status code for the given error. It's the status registered for the first
+// kind in the error's stack that has one (see errors.KindInfo), or for the kind that the error is
+// classified as (see errors.Classify). If there isn't one, 500 is returned.
+func Status(err error) int {
+	for e, ok := err.(*errors.Error); ok && e != nil; e, ok = e.Cause.(*errors.Error) {
+		if info, ok := errors.LookupKind(e.Kind); ok && info.Status != 0 {
+			return info.Status
+		}
+	}
+
+	if info, ok := errors.LookupKind(errors.Classify(err)); ok && info.Status != 0 {
+		return info.Status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// recovered returns an error for the given value recovered from a panic. The panic's goroutine
+// stack trace is added as the "goroutine" field.
+func recovered(v interface{}) *errors.Error {
+	var err *errors.Error
+
+	switch val := v.(type) {
+	case error:
+		err = errors.Wrap(val, ErrPanic)
+	case string:
+		if strings.HasPrefix(val, fatalPrefix) {
+			// errors.Fatal panics with the error rendered as a string, so the original error isn't
+			// available, but the rendered error has everything needed to debug it.
+			err = errors.New(ErrFatal).WithField("fatal", val)
+			break
+		}
+
+		err = errors.New(ErrPanic).WithField("panic", val)
+	default:
+		err = errors.New(ErrPanic).WithField("panic", fmt.Sprint(val))
+	}
+
+	return err.WithField("goroutine", string(debug.Stack()))
+}
+
+// trackingWriter is an http.ResponseWriter that tracks whether a response has been started, so that
+// an error response isn't written on top of it.
+type trackingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+// WriteHeader writes the response's header.
+func (w *trackingWriter) WriteHeader(status int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes to the response's body.
+func (w *trackingWriter) Write(bs []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(bs)
+}
+
+// Flush sends any buffered data to the client, if the underlying http.ResponseWriter supports it.
+func (w *trackingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.written = true
+		f.Flush()
+	}
+}
+
+// Push initiates an HTTP/2 server push, if the underlying http.ResponseWriter supports it. Pushing
+// doesn't start the response, so an error response can still be written afterwards.
+func (w *trackingWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+// ReadFrom writes the contents of the given reader to the response's body, using the underlying
+// http.ResponseWriter's ReadFrom if it has one (e.g. so that files can be sent using sendfile).
+func (w *trackingWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.written = true
+
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+
+	return io.Copy(w.ResponseWriter, r)
+}
+
+// Hijack lets the caller take over the connection, if the underlying http.ResponseWriter supports
+// it.
+func (w *trackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New(kinds.Unimplemented, "httperr: response writer does not support hijacking")
+	}
+
+	w.written = true
+
+	return h.Hijack()
+}
@@ -0,0 +1,286 @@
+package httperr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/i18n"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus(t *testing.T) {
+	t.Run("should use the status for the first kind that has one", func(t *testing.T) {
+		err := errors.Wrap(errors.New(kinds.NotFound), errors.Kind("httperr test: no status"))
+		assert.Equal(t, http.StatusNotFound, Status(err))
+	})
+
+	t.Run("should use the status for derived kinds", func(t *testing.T) {
+		assert.Equal(t, http.StatusInternalServerError, Status(errors.New(ErrPanic)))
+	})
+
+	t.Run("should classify errors without a kind", func(t *testing.T) {
+		assert.Equal(t, http.StatusGatewayTimeout, Status(errors.Wrap(errors.Wrap(contextDeadline{}))))
+	})
+
+	t.Run("should default to 500", func(t *testing.T) {
+		assert.Equal(t, http.StatusInternalServerError, Status(errors.New("oops")))
+	})
+}
+
+func TestResponder(t *testing.T) {
+	t.Run("should write a response for returned errors", func(t *testing.T) {
+		var logged error
+		var loggedStatus int
+
+		rs := &Responder{Log: func(r *http.Request, status int, err error) {
+			logged, loggedStatus = err, status
+		}}
+
+		h := rs.Handle(func(w http.ResponseWriter, r *http.Request) error {
+			return errors.New(kinds.NotFound, "We couldn't find that user.")
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+		req.Header.Set(DefaultRequestIDHeader, "abc123")
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+
+		var prob problem
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &prob))
+
+		assert.Equal(t, problem{
+			Type:        "about:blank",
+			Title:       "Not Found",
+			Status:      http.StatusNotFound,
+			Detail:      "We couldn't find that user.",
+			Instance:    "/users/42",
+			ReferenceID: errors.ReferenceID(logged),
+		}, prob)
+
+		assert.Equal(t, http.StatusNotFound, loggedStatus)
+		assert.Equal(t, map[string]interface{}{
+			"method":     http.MethodGet,
+			"path":       "/users/42",
+			"request_id": "abc123",
+		}, errors.Fields(logged))
+	})
+
+	t.Run("should add fields from the request's context", func(t *testing.T) {
+		var logged error
+
+		rs := &Responder{Log: func(r *http.Request, status int, err error) {
+			logged = err
+		}}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(errors.WithContextFields(req.Context(), "user_id", 42))
+
+		rs.Respond(httptest.NewRecorder(), req, errors.New("oops"))
+
+		assert.Equal(t, 42, errors.Fields(logged)["user_id"])
+	})
+
+	t.Run("should not write a response for nil errors", func(t *testing.T) {
+		h := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		})
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("should not write on top of a started response", func(t *testing.T) {
+		var logged error
+
+		rs := &Responder{Log: func(r *http.Request, status int, err error) {
+			logged = err
+		}}
+
+		h := rs.Handle(func(w http.ResponseWriter, r *http.Request) error {
+			w.Write([]byte("partial"))
+			return errors.New("oops")
+		})
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "partial", rec.Body.String())
+		assert.Error(t, logged)
+	})
+
+	t.Run("should use the language for the request", func(t *testing.T) {
+		rs := &Responder{
+			Log:      func(r *http.Request, status int, err error) {},
+			Language: func(r *http.Request) string { return "fr" },
+		}
+
+		rec := httptest.NewRecorder()
+		rs.Respond(rec, httptest.NewRequest(http.MethodGet, "/", nil), errors.New(kinds.NotFound))
+
+		assert.Equal(t, "fr", rec.Header().Get("Content-Language"))
+		assert.Empty(t, rec.Header().Get("Vary"))
+	})
+
+	t.Run("should negotiate the language using the catalog by default", func(t *testing.T) {
+		catalog := i18n.NewCatalog("en")
+		catalog.Add("en", "user.not_found", "User not found.")
+		catalog.Add("fr", "user.not_found", "Utilisateur introuvable.")
+
+		errors.SetCatalog(catalog)
+		defer errors.SetCatalog(nil)
+
+		rs := &Responder{Log: func(r *http.Request, status int, err error) {}}
+		err := errors.New(kinds.NotFound, errors.Msg("user.not_found"))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "text/plain")
+		req.Header.Set("Accept-Language", "fr-CH, fr;q=0.9, en;q=0.8")
+
+		rec := httptest.NewRecorder()
+		rs.Respond(rec, req, err)
+
+		assert.Equal(t, "fr", rec.Header().Get("Content-Language"))
+		assert.Equal(t, "Accept-Language", rec.Header().Get("Vary"))
+		assert.Equal(t, "Utilisateur introuvable.\n", rec.Body.String())
+
+		req.Header.Set("Accept-Language", "de")
+
+		rec = httptest.NewRecorder()
+		rs.Respond(rec, req, err)
+
+		assert.Empty(t, rec.Header().Get("Content-Language"))
+		assert.Equal(t, "User not found.\n", rec.Body.String())
+	})
+}
+
+func TestResponder_Middleware(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		kind  errors.Kind
+		field string
+	}{
+		{"should recover panics with errors", fmt.Errorf("oops"), ErrPanic, "goroutine"},
+		{"should recover panics with strings", "oops", ErrPanic, "panic"},
+		{"should recover panics with other values", 42, ErrPanic, "panic"},
+		{"should recover panics from Fatal", fatalValue(), ErrFatal, "fatal"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var logged error
+
+			rs := &Responder{
+				Log: func(r *http.Request, status int, err error) {
+					logged = err
+				},
+				ProblemTypeBase: "https://errors.example.com/",
+			}
+
+			h := rs.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic(test.value)
+			}))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			assert.True(t, errors.Is(logged, test.kind))
+			assert.True(t, errors.Is(logged, kinds.Internal))
+			assert.Contains(t, errors.Fields(logged), test.field)
+
+			var prob problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &prob))
+			assert.Equal(t, "An internal error has occurred.", prob.Detail)
+			assert.Equal(t, "https://errors.example.com/"+string(kinds.Internal), prob.Type)
+		})
+	}
+
+	t.Run("should not recover from http.ErrAbortHandler", func(t *testing.T) {
+		h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	})
+
+	t.Run("should support flushing", func(t *testing.T) {
+		h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f, ok := w.(http.Flusher)
+			require.True(t, ok)
+			f.Flush()
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.True(t, rec.Flushed)
+	})
+
+	t.Run("should support pushing", func(t *testing.T) {
+		h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := w.(http.Pusher)
+			require.True(t, ok)
+			assert.Equal(t, http.ErrNotSupported, p.Push("/app.js", nil))
+		}))
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+
+	t.Run("should support reading from readers", func(t *testing.T) {
+		rs := &Responder{}
+
+		h := rs.Handle(func(w http.ResponseWriter, r *http.Request) error {
+			rf, ok := w.(io.ReaderFrom)
+			require.True(t, ok)
+
+			_, err := rf.ReadFrom(strings.NewReader("ok"))
+			require.NoError(t, err)
+
+			return errors.New("oops")
+		})
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		// The response had already been started, so the error response shouldn't be written.
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "ok", rec.Body.String())
+	})
+}
+
+// contextDeadline is an error that reports itself as a timeout, like context.DeadlineExceeded.
+type contextDeadline struct{}
+
+func (contextDeadline) Error() string   { return "deadline exceeded" }
+func (contextDeadline) Timeout() bool   { return true }
+func (contextDeadline) Temporary() bool { return true }
+
+// fatalValue returns the value that errors.Fatal panics with.
+func fatalValue() (v interface{}) {
+	defer func() {
+		v = recover()
+	}()
+
+	errors.Fatal(errors.New("oops"))
+
+	return nil
+}
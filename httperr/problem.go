@@ -0,0 +1,27 @@
+package httperr
+
+// ProblemContentType is the media type of a problem details document, as defined by RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// problem is a problem details document (see RFC 7807). Its type is a URI built from the error's
+// kind, if that's a standard kind (see Responder.ProblemTypeBase), which lets a client turn the
+// response back into an error of the same kind. The reference and trace IDs are extension members,
+// so that the same IDs can be used by the client.
+type problem struct {
+	Type        string `json:"type,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Status      int    `json:"status,omitempty"`
+	Detail      string `json:"detail,omitempty"`
+	Instance    string `json:"instance,omitempty"`
+	ReferenceID string `json:"reference_id,omitempty"`
+	TraceID     string `json:"trace_id,omitempty"`
+}
+
+// message returns the most specific message in the problem.
+func (p problem) message() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+
+	return p.Title
+}
@@ -0,0 +1,189 @@
+package httperr
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+)
+
+// maxKindDepth is the maximum number of parents that will be checked when looking for the standard
+// kind that an error's kind was derived from, in case a kind has been registered as its own parent.
+const maxKindDepth = 16
+
+// Formats that error responses can be written in.
+const (
+	formatProblem = iota
+	formatPlain
+	formatHTML
+)
+
+// formats maps the media types that error responses can be written as to their format. The order
+// is used to break ties when a request accepts several of them equally.
+var formats = []struct {
+	mediaType string
+	format    int
+}{
+	{ProblemContentType, formatProblem},
+	{"application/json", formatProblem},
+	{"text/html", formatHTML},
+	{"text/plain", formatPlain},
+}
+
+// htmlTemplate is the page written for error responses when HTML is asked for.
+var htmlTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Status}} {{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Detail}}</p>
+{{- if .ReferenceID}}
+<p><small>Reference: <code>{{.ReferenceID}}</code></small></p>
+{{- end}}
+</body>
+</html>
+`))
+
+// htmlPage is the data for htmlTemplate.
+type htmlPage struct {
+	problem
+
+	// Lang is the language that the page is written in.
+	Lang string
+}
+
+// render writes an error response for the given error, in the format that best matches the
+// request's Accept header. Messages are written in the given language, and problem types are built
+// from the given base URI (see Responder.ProblemTypeBase).
+func render(w http.ResponseWriter, r *http.Request, status int, err error, lang, typeBase string) {
+	prob := problem{
+		Type:        "about:blank",
+		Title:       http.StatusText(status),
+		Status:      status,
+		Detail:      errors.MessageFor(err, lang),
+		Instance:    r.URL.Path,
+		ReferenceID: errors.ReferenceID(err),
+	}
+
+	if kind := errors.Classify(err); kind != "" {
+		prob.Type = problemType(kind, typeBase)
+	}
+
+	if tc, ok := errors.TraceOf(err); ok {
+		prob.TraceID = tc.TraceIDHex
+	}
+
+	h := w.Header()
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	if lang != "" {
+		h.Set("Content-Language", lang)
+	}
+
+	switch negotiate(r.Header.Get("Accept")) {
+	case formatHTML:
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+
+		// The development page shows source code and fields, so it's only ever used when development
+		// mode has been enabled explicitly.
+		if errors.DevelopmentMode() {
+			errors.FprintHTML(w, err)
+			return
+		}
+
+		page := htmlPage{problem: prob, Lang: lang}
+		if page.Lang == "" {
+			page.Lang = "en"
+		}
+
+		htmlTemplate.Execute(w, page)
+	case formatPlain:
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		io.WriteString(w, prob.Detail+"\n")
+	default:
+		h.Set("Content-Type", ProblemContentType)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(prob)
+	}
+}
+
+// negotiate returns the format that best matches the given Accept header value. If nothing in the
+// header matches, or it's empty, a problem details document is used.
+func negotiate(accept string) int {
+	best, bestQ := formatProblem, 0.0
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		for _, f := range formats {
+			if q > bestQ && mediaMatches(mediaType, f.mediaType) {
+				best, bestQ = f.format, q
+			}
+		}
+	}
+
+	return best
+}
+
+// mediaMatches reports whether the given media range (e.g. "text/*") matches the given media type.
+func mediaMatches(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+	}
+
+	return false
+}
+
+// problemType returns the type of a problem details document for an error of the given kind, which
+// is a URI built from the given base URI, and the kind, escaped. Other kinds may reveal how a service
+// is built (e.g. "httperr: recovered from panic"), so only standard kinds (see kinds.All) are used.
+// An error of any other kind uses the standard kind that its kind was derived from, if any. If
+// there's no standard kind, or no base URI, "about:blank" is returned, as RFC 7807 suggests.
+func problemType(kind errors.Kind, base string) string {
+	if base == "" {
+		return "about:blank"
+	}
+
+	for i := 0; i < maxKindDepth && kind != ""; i++ {
+		for _, standard := range kinds.All() {
+			if kind == standard {
+				return base + url.PathEscape(string(kind))
+			}
+		}
+
+		info, ok := errors.LookupKind(kind)
+		if !ok {
+			break
+		}
+
+		kind = info.Parent
+	}
+
+	return "about:blank"
+}
@@ -0,0 +1,139 @@
+package httperr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected int
+	}{
+		{"", formatProblem},
+		{"*/*", formatProblem},
+		{"application/json", formatProblem},
+		{"application/problem+json", formatProblem},
+		{"text/plain", formatPlain},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", formatHTML},
+		{"text/plain;q=0.5, application/json;q=0.4", formatPlain},
+		{"image/png", formatProblem},
+		{"text/html;q=invalid, text/plain", formatPlain},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, negotiate(test.accept), "accept: %q", test.accept)
+	}
+}
+
+func TestRender(t *testing.T) {
+	err := errors.New(kinds.InvalidArgument, "Name must be <b>set</b>.")
+
+	t.Run("should write plain text", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "text/plain")
+
+		rec := httptest.NewRecorder()
+		render(rec, req, http.StatusBadRequest, err, "", "")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "Name must be <b>set</b>.\n", rec.Body.String())
+	})
+
+	t.Run("should write escaped HTML", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "text/html")
+
+		rec := httptest.NewRecorder()
+		render(rec, req, http.StatusBadRequest, err, "", "")
+
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Body.String(), "<h1>Bad Request</h1>")
+		assert.Contains(t, rec.Body.String(), "Name must be &lt;b&gt;set&lt;/b&gt;.")
+		assert.Contains(t, rec.Body.String(), errors.ReferenceID(err))
+		assert.NotContains(t, rec.Body.String(), "render_test.go")
+	})
+
+	t.Run("should write the development page in development mode", func(t *testing.T) {
+		errors.SetDevelopmentMode(true)
+		defer errors.SetDevelopmentMode(false)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "text/html")
+
+		rec := httptest.NewRecorder()
+		render(rec, req, http.StatusBadRequest, err, "", "")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `<section class="frame">`)
+		assert.Contains(t, rec.Body.String(), "render_test.go:")
+	})
+
+	t.Run("should not write the development page for other formats", func(t *testing.T) {
+		errors.SetDevelopmentMode(true)
+		defer errors.SetDevelopmentMode(false)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "text/plain")
+
+		rec := httptest.NewRecorder()
+		render(rec, req, http.StatusBadRequest, err, "", "")
+
+		assert.NotContains(t, rec.Body.String(), "render_test.go")
+	})
+
+	t.Run("should use about:blank as the type of errors without a kind", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		render(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusInternalServerError, errors.New("oops"), "", "")
+
+		assert.Contains(t, rec.Body.String(), `"type":"about:blank"`)
+	})
+
+	t.Run("should set the language of HTML pages", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "text/html")
+
+		rec := httptest.NewRecorder()
+		render(rec, req, http.StatusBadRequest, err, "fr", "")
+		assert.Contains(t, rec.Body.String(), `<html lang="fr">`)
+
+		rec = httptest.NewRecorder()
+		render(rec, req, http.StatusBadRequest, err, "", "")
+		assert.Contains(t, rec.Body.String(), `<html lang="en">`)
+	})
+
+	t.Run("should use about:blank as the type of errors with non-standard kinds", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		render(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusInternalServerError, errors.New(errors.Kind("render test: secret")), "", "")
+
+		assert.Contains(t, rec.Body.String(), `"type":"about:blank"`)
+		assert.NotContains(t, rec.Body.String(), "render test: secret")
+	})
+}
+
+func TestProblemType(t *testing.T) {
+	derived := kinds.Derive(kinds.NotFound, "render test: user not found")
+	base := "https://errors.example.com/"
+
+	tests := []struct {
+		kind     errors.Kind
+		expected string
+	}{
+		{kinds.NotFound, base + "not%20found"},
+		{kinds.Conflict, base + string(kinds.Conflict)},
+		{derived, base + "not%20found"},
+		{ErrPanic, base + string(kinds.Internal)},
+		{errors.Kind("render test: unregistered"), "about:blank"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, problemType(test.kind, base), "kind: %q", test.kind)
+		assert.Equal(t, "about:blank", problemType(test.kind, ""), "kind: %q", test.kind)
+	}
+}
@@ -0,0 +1,55 @@
+package httperr
+
+import (
+	"net/http"
+
+	"github.com/icelolly/go-errors"
+)
+
+// TraceparentHeader is the W3C Trace Context header that carries the trace and span IDs.
+const TraceparentHeader = "Traceparent"
+
+// InjectTrace sets the traceparent header in the given headers to the given trace context. Nothing
+// is set if the trace context isn't valid.
+func InjectTrace(h http.Header, tc errors.TraceContext) {
+	if !tc.Valid() {
+		return
+	}
+
+	h.Set(TraceparentHeader, tc.String())
+}
+
+// ExtractTrace returns the trace context in the traceparent header of the given headers, and
+// reports whether there is a valid one.
+func ExtractTrace(h http.Header) (errors.TraceContext, bool) {
+	v := h.Get(TraceparentHeader)
+	if v == "" {
+		return errors.TraceContext{}, false
+	}
+
+	tc, err := errors.ParseTraceparent(v)
+	if err != nil {
+		return errors.TraceContext{}, false
+	}
+
+	return tc, true
+}
+
+// withTrace returns the given request with a trace context for handling it stored in its context,
+// so that errors created with the request's context have its IDs. The trace context is a child of
+// the one in the request's traceparent header (see errors.TraceContext.Child), so it has the same
+// trace ID, but its own span ID, which is also what's passed on to outgoing requests made with the
+// request's context. If the request's context already has a trace context (e.g. from a tracing
+// library), the request is returned as-is.
+func withTrace(r *http.Request) *http.Request {
+	if _, ok := errors.TraceFromContext(r.Context()); ok {
+		return r
+	}
+
+	tc, ok := ExtractTrace(r.Header)
+	if !ok {
+		return r
+	}
+
+	return r.WithContext(errors.ContextWithTrace(r.Context(), tc.Child()))
+}
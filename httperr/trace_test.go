@@ -0,0 +1,105 @@
+package httperr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/icelolly/go-errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestInjectTrace(t *testing.T) {
+	tc, err := errors.ParseTraceparent(testTraceparent)
+	require.NoError(t, err)
+
+	h := http.Header{}
+	InjectTrace(h, tc)
+	assert.Equal(t, testTraceparent, h.Get("traceparent"))
+
+	h = http.Header{}
+	InjectTrace(h, errors.TraceContext{})
+	assert.Empty(t, h)
+}
+
+func TestExtractTrace(t *testing.T) {
+	h := http.Header{}
+	h.Set("traceparent", testTraceparent)
+
+	tc, ok := ExtractTrace(h)
+	assert.True(t, ok)
+	assert.Equal(t, testTraceparent, tc.String())
+
+	h.Set("traceparent", "invalid")
+	_, ok = ExtractTrace(h)
+	assert.False(t, ok)
+
+	_, ok = ExtractTrace(http.Header{})
+	assert.False(t, ok)
+}
+
+func TestResponder_Trace(t *testing.T) {
+	var logged error
+
+	rs := &Responder{Log: func(r *http.Request, status int, err error) {
+		logged = err
+	}}
+
+	h := rs.Handle(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.NewCtx(r.Context(), "oops")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, testTraceparent)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	t.Run("should add the trace context to errors", func(t *testing.T) {
+		stack := errors.Stack(logged)
+		require.Len(t, stack, 2)
+
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", stack[1].TraceID)
+
+		// The caller's span ID is the parent of the span handling the request, not its own.
+		assert.Len(t, stack[1].SpanID, 16)
+		assert.NotEqual(t, "00f067aa0ba902b7", stack[1].SpanID)
+	})
+
+	t.Run("should include the trace ID in problem details", func(t *testing.T) {
+		var prob problem
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &prob))
+
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", prob.TraceID)
+	})
+}
+
+func TestTransport_Trace(t *testing.T) {
+	var received string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(TraceparentHeader)
+	}))
+	defer srv.Close()
+
+	tc, err := errors.ParseTraceparent(testTraceparent)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	req = req.WithContext(errors.ContextWithTrace(req.Context(), tc))
+
+	client := &http.Client{Transport: &Transport{}}
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, testTraceparent, received)
+	assert.Empty(t, req.Header.Get(TraceparentHeader), "the original request should not be changed")
+}
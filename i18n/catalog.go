@@ -0,0 +1,321 @@
+// Package i18n provides a message catalog that can be used to show error messages to users in their
+// own language. Messages are loaded from JSON or YAML files, may contain parameters, may have
+// different forms depending on a count (i.e. plurals), and fall back to other languages when a
+// translation is missing.
+//
+// Example usage:
+//
+//    catalog := i18n.NewCatalog("en")
+//    if err := catalog.LoadFile("locales/fr.yaml"); err != nil {
+//        // ...
+//    }
+//
+//    errors.SetCatalog(catalog)
+//
+//    err := errors.New(ErrNotFound, errors.Msg("user.not_found", "name", name))
+//    msg := errors.MessageFor(err, catalog.Negotiate(r.Header.Get("Accept-Language")))
+//
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// CountParam is the name of the parameter used to pick the plural form of a message.
+const CountParam = "count"
+
+// message is a single translated message. Messages without plural forms only have the Other form.
+type message map[string]string
+
+// Catalog holds translated messages for any number of languages. It satisfies the errors.Catalog
+// interface, so it can be passed to errors.SetCatalog. A Catalog is safe for concurrent use.
+//
+// Messages are files containing an object that maps keys to messages. Objects may be nested, in
+// which case the keys are joined with dots. Messages may contain parameters in curly braces, and
+// may have plural forms, keyed by their CLDR plural category (i.e. zero, one, two, few, many, and
+// other). The form is picked using the "count" parameter:
+//
+//    {
+//        "user": {
+//            "not_found": "We couldn't find a user called {name}."
+//        },
+//        "cart.items": {
+//            "one": "You have {count} item in your basket.",
+//            "other": "You have {count} items in your basket."
+//        }
+//    }
+//
+type Catalog struct {
+	mu        sync.RWMutex
+	lang      string
+	messages  map[string]map[string]message
+	fallbacks map[string][]string
+}
+
+// NewCatalog returns a new, empty Catalog. The given language is used when no language is asked
+// for, and as the final fallback when a message isn't available in the language that was asked for.
+func NewCatalog(defaultLang string) *Catalog {
+	return &Catalog{
+		lang:      normalizeTag(defaultLang),
+		messages:  make(map[string]map[string]message),
+		fallbacks: make(map[string][]string),
+	}
+}
+
+// Add adds a message to the catalog, replacing any existing message with the same key.
+func (c *Catalog) Add(lang, key, msg string) {
+	c.add(normalizeTag(lang), key, message{Other: msg})
+}
+
+// AddPlural adds a message with plural forms to the catalog, replacing any existing message with
+// the same key. The forms are keyed by plural category (e.g. One, and Other).
+func (c *Catalog) AddPlural(lang, key string, forms map[string]string) {
+	msg := make(message, len(forms))
+	for category, form := range forms {
+		msg[category] = form
+	}
+
+	c.add(normalizeTag(lang), key, msg)
+}
+
+// SetFallbacks sets the languages to try, in order, when a message isn't available in the given
+// language. Parent languages (e.g. "pt" for "pt-BR") are always tried after these, followed by the
+// catalog's default language.
+func (c *Catalog) SetFallbacks(lang string, fallbacks ...string) {
+	tags := make([]string, len(fallbacks))
+	for i, fb := range fallbacks {
+		tags[i] = normalizeTag(fb)
+	}
+
+	c.mu.Lock()
+	c.fallbacks[normalizeTag(lang)] = tags
+	c.mu.Unlock()
+}
+
+// Languages returns the languages that the catalog has messages for, sorted alphabetically.
+func (c *Catalog) Languages() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	langs := make([]string, 0, len(c.messages))
+	for lang := range c.messages {
+		langs = append(langs, lang)
+	}
+
+	sort.Strings(langs)
+
+	return langs
+}
+
+// Negotiate picks the best language in the catalog for the given Accept-Language header value. If
+// none of the languages in the header are available, or any language will do (i.e. "*"), an empty
+// string is returned, which Localize treats as the default language.
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	return Negotiate(acceptLanguage, c.Languages())
+}
+
+// Localize returns the message for the given key in the given language, with the given params
+// substituted into it, and reports whether a message was found.
+func (c *Catalog) Localize(lang, key string, params map[string]interface{}) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, tag := range c.candidates(normalizeTag(lang)) {
+		msg, ok := c.messages[tag][key]
+		if !ok {
+			continue
+		}
+
+		form := msg[Other]
+		if count, ok := params[CountParam]; ok {
+			if pf, ok := msg[pluralCategory(tag, count)]; ok {
+				form = pf
+			}
+		}
+
+		if form == "" {
+			continue
+		}
+
+		return substitute(form, params), true
+	}
+
+	return "", false
+}
+
+// LoadFile loads messages from the given file. The language is taken from the file's name, e.g.
+// "en-GB.json", and the format is taken from its extension, which must be ".json", ".yaml", or
+// ".yml".
+func (c *Catalog) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	ext := filepath.Ext(path)
+	lang := strings.TrimSuffix(filepath.Base(path), ext)
+
+	switch strings.ToLower(ext) {
+	case ".json":
+		return c.LoadJSON(lang, f)
+	case ".yaml", ".yml":
+		return c.LoadYAML(lang, f)
+	}
+
+	return fmt.Errorf("i18n: unsupported catalog file extension %q", ext)
+}
+
+// LoadJSON loads messages in the given language from the given JSON document.
+func (c *Catalog) LoadJSON(lang string, r io.Reader) error {
+	var doc map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return fmt.Errorf("i18n: failed to decode JSON catalog for %q: %v", lang, err)
+	}
+
+	return c.load(normalizeTag(lang), doc)
+}
+
+// LoadYAML loads messages in the given language from the given YAML document.
+func (c *Catalog) LoadYAML(lang string, r io.Reader) error {
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(bs, &doc); err != nil {
+		return fmt.Errorf("i18n: failed to decode YAML catalog for %q: %v", lang, err)
+	}
+
+	return c.load(normalizeTag(lang), doc)
+}
+
+// load adds all of the messages in the given decoded document to the catalog.
+func (c *Catalog) load(lang string, doc map[string]interface{}) error {
+	msgs := make(map[string]message)
+	if err := flatten(msgs, "", doc); err != nil {
+		return fmt.Errorf("i18n: invalid catalog for %q: %v", lang, err)
+	}
+
+	for key, msg := range msgs {
+		c.add(lang, key, msg)
+	}
+
+	return nil
+}
+
+// add adds a single message to the catalog.
+func (c *Catalog) add(lang, key string, msg message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.messages[lang] == nil {
+		c.messages[lang] = make(map[string]message)
+	}
+
+	c.messages[lang][key] = msg
+}
+
+// candidates returns the languages to look for a message in, in order, for the given language.
+func (c *Catalog) candidates(lang string) []string {
+	if lang == "" {
+		return []string{c.lang}
+	}
+
+	tags := []string{lang}
+	tags = append(tags, c.fallbacks[lang]...)
+
+	for tag := parentTag(lang); tag != ""; tag = parentTag(tag) {
+		tags = append(tags, tag)
+	}
+
+	return append(tags, c.lang)
+}
+
+// flatten walks the given decoded document, adding each message it finds to msgs. Keys of nested
+// objects are joined with dots. Objects made up only of plural categories are plural messages.
+func flatten(msgs map[string]message, prefix string, doc map[string]interface{}) error {
+	for k, v := range doc {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		switch val := v.(type) {
+		case string:
+			msgs[key] = message{Other: val}
+		case map[string]interface{}:
+			if msg, ok := pluralMessage(val); ok {
+				msgs[key] = msg
+				continue
+			}
+
+			if err := flatten(msgs, key, val); err != nil {
+				return err
+			}
+		case map[interface{}]interface{}:
+			// YAML objects are decoded with interface{} keys.
+			obj := make(map[string]interface{}, len(val))
+			for ik, iv := range val {
+				obj[fmt.Sprint(ik)] = iv
+			}
+
+			if err := flatten(msgs, prefix, map[string]interface{}{k: obj}); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected value of type %T for key %q", v, key)
+		}
+	}
+
+	return nil
+}
+
+// pluralMessage converts the given object into a message with plural forms, if every key in the
+// object is a plural category, and every value is a string.
+func pluralMessage(obj map[string]interface{}) (message, bool) {
+	msg := make(message, len(obj))
+
+	for k, v := range obj {
+		switch k {
+		case Zero, One, Two, Few, Many, Other:
+		default:
+			return nil, false
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+
+		msg[k] = s
+	}
+
+	return msg, len(msg) > 0
+}
+
+// substitute replaces each parameter in the given message with its value.
+func substitute(msg string, params map[string]interface{}) string {
+	if len(params) == 0 || !strings.Contains(msg, "{") {
+		return msg
+	}
+
+	pairs := make([]string, 0, len(params)*2)
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", fmt.Sprint(v))
+	}
+
+	return strings.NewReplacer(pairs...).Replace(msg)
+}
@@ -0,0 +1,114 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/icelolly/go-errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCatalog(t *testing.T) *Catalog {
+	c := NewCatalog("en")
+	require.NoError(t, c.LoadFile("testdata/en.json"))
+	require.NoError(t, c.LoadFile("testdata/fr.yaml"))
+
+	return c
+}
+
+func TestCatalog_LoadFile(t *testing.T) {
+	t.Run("should load JSON and YAML files", func(t *testing.T) {
+		assert.Equal(t, []string{"en", "fr"}, newTestCatalog(t).Languages())
+	})
+
+	t.Run("should return an error for unsupported files", func(t *testing.T) {
+		assert.Error(t, NewCatalog("en").LoadFile("catalog.go"))
+	})
+
+	t.Run("should return an error for missing files", func(t *testing.T) {
+		assert.Error(t, NewCatalog("en").LoadFile("testdata/missing.json"))
+	})
+}
+
+func TestCatalog_LoadJSON(t *testing.T) {
+	t.Run("should return an error for invalid messages", func(t *testing.T) {
+		err := NewCatalog("en").LoadJSON("en", strings.NewReader(`{"key": 123}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestCatalog_Localize(t *testing.T) {
+	c := newTestCatalog(t)
+
+	t.Run("should substitute params", func(t *testing.T) {
+		msg, ok := c.Localize("en", "user.not_found", map[string]interface{}{"name": "Laureen"})
+		assert.True(t, ok)
+		assert.Equal(t, "We couldn't find a user called Laureen.", msg)
+	})
+
+	t.Run("should pick plural forms", func(t *testing.T) {
+		msg, _ := c.Localize("en", "cart.items", map[string]interface{}{"count": 1})
+		assert.Equal(t, "You have 1 item in your basket.", msg)
+
+		msg, _ = c.Localize("en", "cart.items", map[string]interface{}{"count": 0})
+		assert.Equal(t, "You have 0 items in your basket.", msg)
+
+		msg, _ = c.Localize("fr", "cart.items", map[string]interface{}{"count": 0})
+		assert.Equal(t, "Vous avez 0 article dans votre panier.", msg)
+	})
+
+	t.Run("should use the default language if none is given", func(t *testing.T) {
+		msg, _ := c.Localize("", "cart.items", map[string]interface{}{"count": 2})
+		assert.Equal(t, "You have 2 items in your basket.", msg)
+	})
+
+	t.Run("should fall back to parent languages", func(t *testing.T) {
+		msg, _ := c.Localize("fr-CA", "cart.items", map[string]interface{}{"count": 2})
+		assert.Equal(t, "Vous avez 2 articles dans votre panier.", msg)
+	})
+
+	t.Run("should fall back to configured languages", func(t *testing.T) {
+		c.SetFallbacks("br", "fr")
+
+		msg, _ := c.Localize("br", "cart.items", map[string]interface{}{"count": 2})
+		assert.Equal(t, "Vous avez 2 articles dans votre panier.", msg)
+	})
+
+	t.Run("should fall back to the default language", func(t *testing.T) {
+		c.Add("de", "greeting", "Hallo")
+
+		msg, _ := c.Localize("de", "cart.items", map[string]interface{}{"count": 2})
+		assert.Equal(t, "You have 2 items in your basket.", msg)
+	})
+
+	t.Run("should report missing messages", func(t *testing.T) {
+		_, ok := c.Localize("en", "missing", nil)
+		assert.False(t, ok)
+	})
+}
+
+func TestCatalog_Negotiate(t *testing.T) {
+	c := newTestCatalog(t)
+
+	assert.Equal(t, "fr", c.Negotiate("fr-CH, fr;q=0.9, en;q=0.8"))
+	assert.Equal(t, "", c.Negotiate("de"))
+}
+
+func TestCatalog_WithErrors(t *testing.T) {
+	c := newTestCatalog(t)
+
+	errors.SetCatalog(c)
+	defer errors.SetCatalog(nil)
+
+	err := errors.New(errors.Msg("user.not_found", "name", "Laureen"))
+
+	t.Run("should be used by Message", func(t *testing.T) {
+		assert.Equal(t, "We couldn't find a user called Laureen.", errors.Message(err))
+	})
+
+	t.Run("should be used by MessageFor", func(t *testing.T) {
+		expected := "Nous n'avons pas trouvé d'utilisateur nommé Laureen."
+		assert.Equal(t, expected, errors.MessageFor(err, "fr"))
+	})
+}
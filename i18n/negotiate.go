@@ -0,0 +1,117 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Negotiate picks the best language from the given available languages for the given
+// Accept-Language header value, e.g. "fr-CH, fr;q=0.9, en;q=0.8". Languages are tried in order of
+// preference; first looking for an exact match, then for a match on a parent language (e.g. "fr"
+// for "fr-CH"), and then for any available language with the same base language. If nothing
+// matches, or the most preferred match is "*" (i.e. any language), an empty string is returned, so
+// that the default language is used.
+func Negotiate(acceptLanguage string, available []string) string {
+	if len(available) == 0 {
+		return ""
+	}
+
+	normalized := make(map[string]string, len(available))
+	for _, lang := range available {
+		normalized[normalizeTag(lang)] = lang
+	}
+
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if tag == "*" {
+			return ""
+		}
+
+		for t := tag; t != ""; t = parentTag(t) {
+			if lang, ok := normalized[t]; ok {
+				return lang
+			}
+		}
+
+		base := baseLanguage(tag)
+		for _, lang := range available {
+			if baseLanguage(normalizeTag(lang)) == base {
+				return lang
+			}
+		}
+	}
+
+	return ""
+}
+
+// parseAcceptLanguage returns the language tags in the given Accept-Language header value, sorted
+// by preference. Tags with a quality of 0 are not acceptable, so they're left out.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+
+		tag := normalizeTag(fields[0])
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+
+	return result
+}
+
+// normalizeTag returns the given language tag in a consistent form, so that tags can be compared,
+// e.g. "en_GB" and "en-GB" both become "en-gb".
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(tag), "_", "-", -1))
+}
+
+// parentTag returns the given language tag with its last subtag removed, e.g. "zh-hant" for
+// "zh-hant-tw", or an empty string if it has no parent.
+func parentTag(tag string) string {
+	i := strings.LastIndex(tag, "-")
+	if i < 0 {
+		return ""
+	}
+
+	return tag[:i]
+}
+
+// baseLanguage returns the primary language subtag of the given language tag, e.g. "en" for
+// "en-gb".
+func baseLanguage(tag string) string {
+	if i := strings.Index(tag, "-"); i >= 0 {
+		return tag[:i]
+	}
+
+	return tag
+}
@@ -0,0 +1,34 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	available := []string{"en", "en-GB", "fr", "pt-BR"}
+
+	tt := []struct {
+		header   string
+		expected string
+	}{
+		{header: "", expected: ""},
+		{header: "en-GB", expected: "en-GB"},
+		{header: "en-US", expected: "en"},
+		{header: "fr-CH, fr;q=0.9, en;q=0.8", expected: "fr"},
+		{header: "de, en;q=0.5", expected: "en"},
+		{header: "en;q=0.5, fr;q=0.8", expected: "fr"},
+		{header: "pt", expected: "pt-BR"},
+		{header: "fr;q=0, en", expected: "en"},
+		{header: "de, *;q=0.1", expected: ""},
+		{header: "*", expected: ""},
+		{header: "de, *;q=0.5, fr;q=0.1", expected: ""},
+		{header: "fr, *;q=0.5", expected: "fr"},
+		{header: "de", expected: ""},
+	}
+
+	for _, tc := range tt {
+		assert.Equal(t, tc.expected, Negotiate(tc.header, available), "header: %q", tc.header)
+	}
+}
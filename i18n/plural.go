@@ -0,0 +1,190 @@
+package i18n
+
+import (
+	"math"
+	"reflect"
+	"sync"
+)
+
+// Plural categories, as defined by the Unicode CLDR plural rules.
+const (
+	Zero  = "zero"
+	One   = "one"
+	Two   = "two"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+// PluralRule returns the plural category a number belongs to in a particular language. Rules only
+// need to deal with whole numbers; fractions always use the Other category.
+type PluralRule func(n int64) string
+
+// pluralRules holds the plural rule for each base language. Languages without a rule of their own
+// use the same rule as English.
+var pluralRules = struct {
+	sync.RWMutex
+	rules map[string]PluralRule
+}{
+	rules: make(map[string]PluralRule),
+}
+
+func init() {
+	for _, lang := range []string{"ja", "ko", "zh", "th", "vi", "id", "ms"} {
+		pluralRules.rules[lang] = pluralOther
+	}
+
+	for _, lang := range []string{"fr", "pt"} {
+		pluralRules.rules[lang] = pluralZeroOne
+	}
+
+	for _, lang := range []string{"ru", "uk", "be"} {
+		pluralRules.rules[lang] = pluralEastSlavic
+	}
+
+	for _, lang := range []string{"cs", "sk"} {
+		pluralRules.rules[lang] = pluralCzech
+	}
+
+	pluralRules.rules["pl"] = pluralPolish
+	pluralRules.rules["ar"] = pluralArabic
+}
+
+// RegisterPluralRule sets the plural rule for the given base language, e.g. "cy". This can be used
+// to add rules for languages that aren't supported out of the box, or to replace existing rules.
+func RegisterPluralRule(lang string, rule PluralRule) {
+	pluralRules.Lock()
+	pluralRules.rules[baseLanguage(normalizeTag(lang))] = rule
+	pluralRules.Unlock()
+}
+
+// pluralCategory returns the plural category that the given count belongs to in the given language.
+func pluralCategory(lang string, count interface{}) string {
+	n, ok := wholeNumber(count)
+	if !ok {
+		return Other
+	}
+
+	pluralRules.RLock()
+	rule, ok := pluralRules.rules[baseLanguage(lang)]
+	pluralRules.RUnlock()
+
+	if !ok {
+		rule = pluralOneOther
+	}
+
+	return rule(n)
+}
+
+// wholeNumber converts the given value into a whole number, if it is one.
+func wholeNumber(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) {
+			return 0, false
+		}
+
+		return int64(f), true
+	}
+
+	return 0, false
+}
+
+// pluralOneOther is the rule for English, German, Spanish, Italian, and many others.
+func pluralOneOther(n int64) string {
+	if n == 1 {
+		return One
+	}
+
+	return Other
+}
+
+// pluralOther is the rule for languages without plural forms, e.g. Japanese, and Chinese.
+func pluralOther(n int64) string {
+	return Other
+}
+
+// pluralZeroOne is the rule for French, and Portuguese, where 0 is singular too.
+func pluralZeroOne(n int64) string {
+	if n == 0 || n == 1 {
+		return One
+	}
+
+	return Other
+}
+
+// pluralEastSlavic is the rule for Russian, Ukrainian, and Belarusian.
+func pluralEastSlavic(n int64) string {
+	n10, n100 := abs(n)%10, abs(n)%100
+
+	switch {
+	case n10 == 1 && n100 != 11:
+		return One
+	case n10 >= 2 && n10 <= 4 && (n100 < 12 || n100 > 14):
+		return Few
+	}
+
+	return Many
+}
+
+// pluralPolish is the rule for Polish.
+func pluralPolish(n int64) string {
+	n10, n100 := abs(n)%10, abs(n)%100
+
+	switch {
+	case n == 1:
+		return One
+	case n10 >= 2 && n10 <= 4 && (n100 < 12 || n100 > 14):
+		return Few
+	}
+
+	return Many
+}
+
+// pluralCzech is the rule for Czech, and Slovak.
+func pluralCzech(n int64) string {
+	switch {
+	case n == 1:
+		return One
+	case n >= 2 && n <= 4:
+		return Few
+	}
+
+	return Other
+}
+
+// pluralArabic is the rule for Arabic.
+func pluralArabic(n int64) string {
+	n100 := abs(n) % 100
+
+	switch {
+	case n == 0:
+		return Zero
+	case n == 1:
+		return One
+	case n == 2:
+		return Two
+	case n100 >= 3 && n100 <= 10:
+		return Few
+	case n100 >= 11:
+		return Many
+	}
+
+	return Other
+}
+
+// abs returns the absolute value of n.
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
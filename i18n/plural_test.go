@@ -0,0 +1,48 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluralCategory(t *testing.T) {
+	tt := []struct {
+		lang     string
+		count    interface{}
+		expected string
+	}{
+		{lang: "en", count: 1, expected: One},
+		{lang: "en", count: 0, expected: Other},
+		{lang: "en", count: 1.5, expected: Other},
+		{lang: "en", count: uint8(1), expected: One},
+		{lang: "en", count: "1", expected: Other},
+		{lang: "fr", count: 0, expected: One},
+		{lang: "ja", count: 1, expected: Other},
+		{lang: "ru", count: 21, expected: One},
+		{lang: "ru", count: 22, expected: Few},
+		{lang: "ru", count: 12, expected: Many},
+		{lang: "pl", count: 1, expected: One},
+		{lang: "pl", count: 24, expected: Few},
+		{lang: "pl", count: 25, expected: Many},
+		{lang: "cs", count: 3, expected: Few},
+		{lang: "ar", count: 0, expected: Zero},
+		{lang: "ar", count: 2, expected: Two},
+		{lang: "ar", count: 105, expected: Few},
+		{lang: "ar", count: 111, expected: Many},
+		{lang: "ar", count: 100, expected: Other},
+		{lang: "ru-ua", count: 3, expected: Few},
+	}
+
+	for _, tc := range tt {
+		assert.Equal(t, tc.expected, pluralCategory(tc.lang, tc.count), "%s: %v", tc.lang, tc.count)
+	}
+}
+
+func TestRegisterPluralRule(t *testing.T) {
+	RegisterPluralRule("x-test", func(n int64) string {
+		return Many
+	})
+
+	assert.Equal(t, Many, pluralCategory("x", 1))
+}
@@ -0,0 +1,130 @@
+package errors
+
+import "sync"
+
+// KindInfo is metadata about a Kind. Registering metadata for a Kind lets other parts of this
+// package (and other packages) handle errors of that kind in a consistent way, without every call
+// site needing to provide the same information.
+type KindInfo struct {
+	// Message is the default user-facing message for errors of this kind. It's used by Message when
+	// no error in the stack has a message of its own.
+	Message string
+
+	// Retryable reports whether an operation that failed with an error of this kind may succeed if
+	// it's tried again, e.g. because the error was caused by a timeout (see the retry package).
+	Retryable bool
+
+	// Status is the HTTP status code that should be used when responding with an error of this kind.
+	Status int
+
+	// Severity indicates how serious an error of this kind is, e.g. for deciding how to log it.
+	Severity Severity
+
+	// Parent is the kind that this kind was derived from, if any. Is treats an error as being of its
+	// parent kinds as well as its own kind, e.g. a "users: not found" kind might have a generic "not
+	// found" kind as its parent.
+	Parent Kind
+}
+
+// Severity indicates how serious an error is.
+type Severity string
+
+// Severities, from least to most serious.
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+// maxKindDepth limits how far isKindOf will look through a kind's parents, in case a cycle has been
+// registered.
+const maxKindDepth = 32
+
+// kindRegistry holds the metadata registered for each Kind. Kinds are usually registered during
+// initialisation, but they're looked up whilst errors are being handled, so it's guarded by a lock.
+var kindRegistry = struct {
+	sync.RWMutex
+	kinds map[Kind]KindInfo
+}{
+	kinds: make(map[Kind]KindInfo),
+}
+
+// defaultMessage is the message returned by Message when there's nothing more specific to use.
+var defaultMessage = struct {
+	sync.RWMutex
+	message string
+}{
+	message: "An internal error has occurred. Please contact technical support.",
+}
+
+// RegisterKind registers metadata for the given Kind. Registering metadata for a Kind that has
+// already been registered replaces the existing metadata.
+func RegisterKind(kind Kind, info KindInfo) {
+	kindRegistry.Lock()
+	kindRegistry.kinds[kind] = info
+	kindRegistry.Unlock()
+}
+
+// LookupKind returns the metadata registered for the given Kind, and reports whether any metadata
+// has been registered for it.
+func LookupKind(kind Kind) (KindInfo, bool) {
+	kindRegistry.RLock()
+	info, ok := kindRegistry.kinds[kind]
+	kindRegistry.RUnlock()
+
+	return info, ok
+}
+
+// isKindOf reports whether the given kind is the given ancestor kind, or was derived from it.
+func isKindOf(kind, ancestor Kind) bool {
+	for i := 0; i < maxKindDepth && kind != ""; i++ {
+		if kind == ancestor {
+			return true
+		}
+
+		info, ok := LookupKind(kind)
+		if !ok {
+			return false
+		}
+
+		kind = info.Parent
+	}
+
+	return false
+}
+
+// KindSeverity returns the severity registered for the given kind, or for the nearest of its
+// parents that has one. An empty Severity is returned if none of them have one.
+func KindSeverity(kind Kind) Severity {
+	for i := 0; i < maxKindDepth && kind != ""; i++ {
+		info, ok := LookupKind(kind)
+		if !ok {
+			break
+		}
+
+		if info.Severity != "" {
+			return info.Severity
+		}
+
+		kind = info.Parent
+	}
+
+	return ""
+}
+
+// SetDefaultMessage sets the message returned by Message when no error in a stack has a message,
+// and none of the kinds in the stack have a default message registered.
+func SetDefaultMessage(message string) {
+	defaultMessage.Lock()
+	defaultMessage.message = message
+	defaultMessage.Unlock()
+}
+
+// fallbackMessage returns the message set using SetDefaultMessage.
+func fallbackMessage() string {
+	defaultMessage.RLock()
+	defer defaultMessage.RUnlock()
+
+	return defaultMessage.message
+}
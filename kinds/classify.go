@@ -0,0 +1,53 @@
+package kinds
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"reflect"
+	"syscall"
+
+	"github.com/icelolly/go-errors"
+)
+
+// init registers the rules used by errors.Classify for errors from the standard library. They're
+// registered as default rules, so services can still override them (see errors.RegisterClassifier).
+func init() {
+	registerSentinel(context.Canceled, Canceled)
+	registerSentinel(context.DeadlineExceeded, DeadlineExceeded)
+	registerSentinel(sql.ErrNoRows, NotFound)
+	registerSentinel(syscall.ECONNREFUSED, Unavailable)
+	registerSentinel(syscall.ECONNRESET, Unavailable)
+	registerPredicate(os.IsNotExist, NotFound)
+	registerPredicate(os.IsExist, AlreadyExists)
+	registerPredicate(os.IsPermission, PermissionDenied)
+	registerPredicate(isTimeout, DeadlineExceeded)
+}
+
+// registerSentinel adds a default rule that classifies errors equal to the given sentinel error as
+// the given kind. Errors whose type isn't comparable (e.g. a struct with a slice field, used as a
+// value) can't be equal to the sentinel, and comparing them would panic, so they're never matched.
+func registerSentinel(sentinel error, kind errors.Kind) {
+	errors.RegisterDefaultClassifier(func(err error) (errors.Kind, bool) {
+		if err == nil || !reflect.TypeOf(err).Comparable() {
+			return kind, false
+		}
+
+		return kind, err == sentinel
+	})
+}
+
+// registerPredicate adds a default rule that classifies errors that the given predicate returns
+// true for as the given kind.
+func registerPredicate(predicate func(err error) bool, kind errors.Kind) {
+	errors.RegisterDefaultClassifier(func(err error) (errors.Kind, bool) {
+		return kind, predicate(err)
+	})
+}
+
+// isTimeout reports whether the given error is a timeout, e.g. a net.Error whose Timeout method
+// returns true.
+func isTimeout(err error) bool {
+	t, ok := err.(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
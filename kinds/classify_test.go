@@ -0,0 +1,66 @@
+package kinds
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/icelolly/go-errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	_, statErr := os.Stat("/does/not/exist")
+
+	tt := []struct {
+		name     string
+		err      error
+		expected errors.Kind
+	}{
+		{name: "unknown standard error", err: stderrors.New("oops"), expected: ""},
+		{name: "context canceled", err: context.Canceled, expected: Canceled},
+		{name: "context deadline exceeded", err: context.DeadlineExceeded, expected: DeadlineExceeded},
+		{name: "sql no rows", err: sql.ErrNoRows, expected: NotFound},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, expected: ""},
+		{name: "file not found", err: statErr, expected: NotFound},
+		{name: "file exists", err: os.ErrExist, expected: AlreadyExists},
+		{name: "permission denied", err: os.ErrPermission, expected: PermissionDenied},
+		{
+			name: "connection refused",
+			err: &net.OpError{
+				Op:  "dial",
+				Net: "tcp",
+				Err: os.NewSyscallError("connect", syscall.ECONNREFUSED),
+			},
+			expected: Unavailable,
+		},
+		{
+			name:     "network timeout",
+			err:      &net.DNSError{Err: "timeout", IsTimeout: true},
+			expected: DeadlineExceeded,
+		},
+		{name: "wrapped standard error", err: errors.Wrap(sql.ErrNoRows, "oops"), expected: NotFound},
+		{name: "error with a kind", err: errors.Wrap(sql.ErrNoRows, Internal), expected: Internal},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, errors.Classify(tc.err))
+		})
+	}
+
+	t.Run("should let custom rules override the standard ones", func(t *testing.T) {
+		// Custom rules can't be removed, so this uses an error that no other test classifies.
+		sentinel := &os.PathError{Op: "kinds test", Err: os.ErrNotExist}
+		errors.RegisterClassifier(func(err error) (errors.Kind, bool) {
+			return Unavailable, err == sentinel
+		})
+
+		assert.Equal(t, Unavailable, errors.Classify(sentinel))
+	})
+}
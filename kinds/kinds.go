@@ -0,0 +1,191 @@
+// Package kinds provides a standard set of error kinds, so that errors can be handled the same way
+// across services. The kinds are modelled on gRPC's canonical status codes, and each one has
+// metadata registered for it (i.e. an HTTP status code, whether it's retryable, a severity, and a
+// default user-facing message), so importing this package is enough to make use of them. Importing
+// it also registers the rules that errors.Classify uses to classify errors from the standard library.
+//
+// Services can derive their own, more specific kinds from these, which will still be treated as
+// the standard kind by errors.Is:
+//
+//    var ErrUserNotFound = kinds.Derive(kinds.NotFound, "users: user not found")
+//
+//    // ...
+//
+//    err := errors.New(ErrUserNotFound)
+//    errors.Is(err, kinds.NotFound) // true
+//
+package kinds
+
+import (
+	"net/http"
+
+	"github.com/icelolly/go-errors"
+)
+
+// Standard kinds, modelled on gRPC's canonical status codes. Some of these are also used to
+// classify errors from the standard library (see errors.Classify), e.g. sql.ErrNoRows is NotFound.
+const (
+	Canceled           = errors.Kind("canceled")
+	Unknown            = errors.Kind("unknown")
+	InvalidArgument    = errors.Kind("invalid argument")
+	DeadlineExceeded   = errors.Kind("deadline exceeded")
+	NotFound           = errors.Kind("not found")
+	AlreadyExists      = errors.Kind("already exists")
+	PermissionDenied   = errors.Kind("permission denied")
+	ResourceExhausted  = errors.Kind("resource exhausted")
+	FailedPrecondition = errors.Kind("failed precondition")
+	Aborted            = errors.Kind("aborted")
+	OutOfRange         = errors.Kind("out of range")
+	Unimplemented      = errors.Kind("unimplemented")
+	Internal           = errors.Kind("internal")
+	Unavailable        = errors.Kind("unavailable")
+	DataLoss           = errors.Kind("data loss")
+	Unauthenticated    = errors.Kind("unauthenticated")
+)
+
+// Kinds named after their HTTP equivalents. These are the same as the standard kinds above, except
+// for Conflict, which is derived from Aborted.
+const (
+	BadRequest      = InvalidArgument
+	Unauthorized    = Unauthenticated
+	Forbidden       = PermissionDenied
+	Timeout         = DeadlineExceeded
+	TooManyRequests = ResourceExhausted
+	Conflict        = errors.Kind("conflict")
+)
+
+// statusClientClosedRequest is the non-standard status code used by nginx when a client closes the
+// connection before a response is sent. It's the usual HTTP equivalent of Canceled.
+const statusClientClosedRequest = 499
+
+// standard holds the metadata for each of the standard kinds.
+var standard = map[errors.Kind]errors.KindInfo{
+	Canceled: {
+		Status:   statusClientClosedRequest,
+		Severity: errors.SeverityInfo,
+		Message:  "The request was cancelled.",
+	},
+	Unknown: {
+		Status:   http.StatusInternalServerError,
+		Severity: errors.SeverityError,
+		Message:  "An unknown error has occurred.",
+	},
+	InvalidArgument: {
+		Status:   http.StatusBadRequest,
+		Severity: errors.SeverityInfo,
+		Message:  "The request is invalid. Please check your input.",
+	},
+	DeadlineExceeded: {
+		Status:    http.StatusGatewayTimeout,
+		Severity:  errors.SeverityWarning,
+		Retryable: true,
+		Message:   "The request took too long to complete. Please try again.",
+	},
+	NotFound: {
+		Status:   http.StatusNotFound,
+		Severity: errors.SeverityInfo,
+		Message:  "The requested resource could not be found.",
+	},
+	AlreadyExists: {
+		Status:   http.StatusConflict,
+		Severity: errors.SeverityInfo,
+		Message:  "The resource already exists.",
+	},
+	PermissionDenied: {
+		Status:   http.StatusForbidden,
+		Severity: errors.SeverityWarning,
+		Message:  "You do not have permission to do that.",
+	},
+	ResourceExhausted: {
+		Status:    http.StatusTooManyRequests,
+		Severity:  errors.SeverityWarning,
+		Retryable: true,
+		Message:   "Too many requests. Please try again later.",
+	},
+	FailedPrecondition: {
+		Status:   http.StatusBadRequest,
+		Severity: errors.SeverityInfo,
+		Message:  "The request can't be completed in the current state.",
+	},
+	Aborted: {
+		Status:    http.StatusConflict,
+		Severity:  errors.SeverityWarning,
+		Retryable: true,
+		Message:   "The request was aborted. Please try again.",
+	},
+	OutOfRange: {
+		Status:   http.StatusBadRequest,
+		Severity: errors.SeverityInfo,
+		Message:  "The request is out of range. Please check your input.",
+	},
+	Unimplemented: {
+		Status:   http.StatusNotImplemented,
+		Severity: errors.SeverityError,
+		Message:  "That isn't supported.",
+	},
+	Internal: {
+		Status:   http.StatusInternalServerError,
+		Severity: errors.SeverityError,
+		Message:  "An internal error has occurred.",
+	},
+	Unavailable: {
+		Status:    http.StatusServiceUnavailable,
+		Severity:  errors.SeverityWarning,
+		Retryable: true,
+		Message:   "The service is currently unavailable. Please try again later.",
+	},
+	DataLoss: {
+		Status:   http.StatusInternalServerError,
+		Severity: errors.SeverityCritical,
+		Message:  "An internal error has occurred.",
+	},
+	Unauthenticated: {
+		Status:   http.StatusUnauthorized,
+		Severity: errors.SeverityInfo,
+		Message:  "You need to sign in to do that.",
+	},
+	Conflict: {
+		Status:   http.StatusConflict,
+		Severity: errors.SeverityInfo,
+		Parent:   Aborted,
+		Message:  "The request conflicts with the current state of the resource.",
+	},
+}
+
+func init() {
+	for kind, info := range standard {
+		errors.RegisterKind(kind, info)
+	}
+}
+
+// All returns every standard kind, not including the kinds named after their HTTP equivalents
+// that are the same as a standard kind.
+func All() []errors.Kind {
+	return []errors.Kind{
+		Canceled, Unknown, InvalidArgument, DeadlineExceeded, NotFound, AlreadyExists,
+		PermissionDenied, ResourceExhausted, FailedPrecondition, Aborted, OutOfRange,
+		Unimplemented, Internal, Unavailable, DataLoss, Unauthenticated, Conflict,
+	}
+}
+
+// Derive registers a new kind derived from the given parent kind, and returns it. The new kind has
+// the same metadata as its parent, and errors.Is treats errors of the new kind as being of the
+// parent kind too. The metadata can be changed afterwards using DeriveWith, or errors.RegisterKind.
+func Derive(parent, kind errors.Kind) errors.Kind {
+	return DeriveWith(parent, kind, nil)
+}
+
+// DeriveWith works the same way as Derive, but calls the given function with the new kind's
+// metadata before it's registered, so that it can be changed, e.g. to set a different message.
+func DeriveWith(parent, kind errors.Kind, fn func(info *errors.KindInfo)) errors.Kind {
+	info, _ := errors.LookupKind(parent)
+	info.Parent = parent
+
+	if fn != nil {
+		fn(&info)
+	}
+
+	errors.RegisterKind(kind, info)
+
+	return kind
+}
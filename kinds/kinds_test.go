@@ -0,0 +1,76 @@
+package kinds
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/icelolly/go-errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandardKinds(t *testing.T) {
+	t.Run("should register metadata for every kind", func(t *testing.T) {
+		for _, kind := range All() {
+			info, ok := errors.LookupKind(kind)
+			require.True(t, ok, "kind: %s", kind)
+
+			assert.NotZero(t, info.Status, "kind: %s", kind)
+			assert.NotEmpty(t, info.Severity, "kind: %s", kind)
+			assert.NotEmpty(t, info.Message, "kind: %s", kind)
+		}
+	})
+
+	t.Run("should provide default messages", func(t *testing.T) {
+		assert.Equal(t, "The requested resource could not be found.", errors.Message(errors.New(NotFound)))
+	})
+
+	t.Run("should be used for classified errors", func(t *testing.T) {
+		err := errors.WrapClassified(http.ErrNoLocation)
+		assert.False(t, errors.Is(err, NotFound))
+
+		err = errors.WrapClassified(os.ErrNotExist)
+		assert.True(t, errors.Is(err, NotFound))
+	})
+
+	t.Run("should treat conflicts as aborted", func(t *testing.T) {
+		assert.True(t, errors.Is(errors.New(Conflict), Aborted))
+		assert.False(t, errors.Is(errors.New(Aborted), Conflict))
+	})
+}
+
+func TestDerive(t *testing.T) {
+	errUserNotFound := Derive(NotFound, "kinds test: user not found")
+
+	t.Run("should be treated as the parent kind", func(t *testing.T) {
+		err := errors.Wrap(errors.New(errUserNotFound), "wrapped")
+
+		assert.True(t, errors.Is(err, errUserNotFound))
+		assert.True(t, errors.Is(err, NotFound))
+		assert.False(t, errors.Is(err, Internal))
+	})
+
+	t.Run("should inherit the parent's metadata", func(t *testing.T) {
+		info, ok := errors.LookupKind(errUserNotFound)
+		require.True(t, ok)
+
+		assert.Equal(t, http.StatusNotFound, info.Status)
+		assert.Equal(t, NotFound, info.Parent)
+	})
+
+	t.Run("should allow metadata to be changed", func(t *testing.T) {
+		kind := DeriveWith(InvalidArgument, "kinds test: invalid email", func(info *errors.KindInfo) {
+			info.Message = "Please enter a valid email address."
+		})
+
+		assert.Equal(t, "Please enter a valid email address.", errors.Message(errors.New(kind)))
+		assert.True(t, errors.Is(errors.New(kind), BadRequest))
+	})
+
+	t.Run("should support deriving from derived kinds", func(t *testing.T) {
+		kind := Derive(errUserNotFound, "kinds test: admin not found")
+
+		assert.True(t, errors.Is(errors.New(kind), NotFound))
+	})
+}
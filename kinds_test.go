@@ -0,0 +1,115 @@
+package errors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterKind(t *testing.T) {
+	kind := Kind("kinds test: register")
+
+	t.Run("should not find unregistered kinds", func(t *testing.T) {
+		_, ok := LookupKind(kind)
+		assert.False(t, ok)
+	})
+
+	t.Run("should find registered kinds", func(t *testing.T) {
+		RegisterKind(kind, KindInfo{Message: "Please check your input."})
+
+		info, ok := LookupKind(kind)
+		assert.True(t, ok)
+		assert.Equal(t, "Please check your input.", info.Message)
+	})
+}
+
+func TestMessage_KindDefaults(t *testing.T) {
+	inner := Kind("kinds test: inner")
+	outer := Kind("kinds test: outer")
+	other := Kind("kinds test: unregistered")
+
+	RegisterKind(inner, KindInfo{Message: "inner default"})
+	RegisterKind(outer, KindInfo{Message: "outer default"})
+
+	t.Run("should use the default message for the error's kind", func(t *testing.T) {
+		assert.Equal(t, "inner default", Message(New(inner)))
+	})
+
+	t.Run("should use the default message for the first registered kind in the stack", func(t *testing.T) {
+		assert.Equal(t, "outer default", Message(Wrap(New(inner), outer)))
+		assert.Equal(t, "inner default", Message(Wrap(New(inner), other)))
+	})
+
+	t.Run("should prefer explicit messages anywhere in the stack", func(t *testing.T) {
+		assert.Equal(t, "explicit", Message(Wrap(New(inner, "explicit"), outer)))
+	})
+
+	t.Run("should use the fallback message for unregistered kinds", func(t *testing.T) {
+		assert.Equal(t, fallbackMessage(), Message(New(other)))
+	})
+}
+
+func TestSetDefaultMessage(t *testing.T) {
+	original := fallbackMessage()
+	defer SetDefaultMessage(original)
+
+	SetDefaultMessage("Something went wrong.")
+
+	t.Run("should be used for errors without messages", func(t *testing.T) {
+		assert.Equal(t, "Something went wrong.", Message(New(Kind("kinds test: no message"))))
+	})
+
+	t.Run("should be used for standard errors", func(t *testing.T) {
+		assert.Equal(t, "Something went wrong.", Message(errors.New("oops")))
+	})
+}
+
+func TestIs_KindHierarchy(t *testing.T) {
+	parent := Kind("kinds test: parent")
+	child := Kind("kinds test: child")
+	grandchild := Kind("kinds test: grandchild")
+	cyclic := Kind("kinds test: cyclic")
+
+	RegisterKind(child, KindInfo{Parent: parent})
+	RegisterKind(grandchild, KindInfo{Parent: child})
+	RegisterKind(cyclic, KindInfo{Parent: cyclic})
+
+	t.Run("should match ancestors of the error's kind", func(t *testing.T) {
+		assert.True(t, Is(New(child), parent))
+		assert.True(t, Is(Wrap(New(grandchild)), parent))
+		assert.True(t, Is(New(grandchild), child))
+	})
+
+	t.Run("should not match descendants of the error's kind", func(t *testing.T) {
+		assert.False(t, Is(New(parent), child))
+	})
+
+	t.Run("should match kinds given as strings", func(t *testing.T) {
+		assert.True(t, Is(New(child), "kinds test: child"))
+		assert.True(t, Is(New(child), "kinds test: parent"))
+	})
+
+	t.Run("should not loop forever on cyclic kinds", func(t *testing.T) {
+		assert.False(t, Is(New(cyclic), parent))
+	})
+}
+
+func TestKindSeverity(t *testing.T) {
+	parent := Kind("kinds test: severe parent")
+	child := Kind("kinds test: severe child")
+	override := Kind("kinds test: severe override")
+	cyclic := Kind("kinds test: severe cyclic")
+
+	RegisterKind(parent, KindInfo{Severity: SeverityWarning})
+	RegisterKind(child, KindInfo{Parent: parent})
+	RegisterKind(override, KindInfo{Parent: parent, Severity: SeverityCritical})
+	RegisterKind(cyclic, KindInfo{Parent: cyclic})
+
+	assert.Equal(t, SeverityWarning, KindSeverity(parent))
+	assert.Equal(t, SeverityWarning, KindSeverity(child))
+	assert.Equal(t, SeverityCritical, KindSeverity(override))
+	assert.Equal(t, Severity(""), KindSeverity(cyclic))
+	assert.Equal(t, Severity(""), KindSeverity("kinds test: unregistered severity"))
+	assert.Equal(t, Severity(""), KindSeverity(""))
+}
@@ -0,0 +1,86 @@
+package errors
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sourceLinks holds the settings used to build links to source code. It's accessed whilst stacks
+// are being produced, so it's guarded by a lock.
+var sourceLinks struct {
+	sync.RWMutex
+	template string
+	revision string
+}
+
+// SetSourceURLTemplate sets the template used to build a link to the source code for each frame in
+// a stack, e.g. so that someone reading a log entry can jump straight to the line that produced an
+// error. Links are disabled if the template is empty, which is the default. The template may
+// contain the following placeholders:
+//
+// {module}:   The path of the main module, e.g. "github.com/icelolly/go-errors".
+// {revision}: The version control revision the binary was built from (see SetSourceRevision).
+// {path}:     The path of the file, relative to the root of the main module.
+// {line}:     The line number.
+//
+// Placeholder values are escaped for use in a URL path, though the slashes between their segments
+// are kept, so that a file in a directory links to that directory in the repository.
+//
+// For example: "https://git.example/{module}/blob/{revision}/{path}#L{line}". Links are only built
+// for frames in the main module, because we don't know which revision other modules are at.
+func SetSourceURLTemplate(template string) {
+	sourceLinks.Lock()
+	sourceLinks.template = template
+	sourceLinks.Unlock()
+}
+
+// SetSourceRevision overrides the revision used in source links. By default, the "vcs.revision"
+// from the binary's build information is used, but that's only available from Go 1.18, and only
+// when the binary was built from within a repository (i.e. not in most Docker builds).
+func SetSourceRevision(revision string) {
+	sourceLinks.Lock()
+	sourceLinks.revision = revision
+	sourceLinks.Unlock()
+}
+
+// sourceURL returns a link to the given line in the given file, or an empty string if a link can't
+// be built for it.
+func sourceURL(function, file string, line int) string {
+	sourceLinks.RLock()
+	template := sourceLinks.template
+	revision := sourceLinks.revision
+	sourceLinks.RUnlock()
+
+	if template == "" || file == "" {
+		return ""
+	}
+
+	info := buildInfo()
+	if revision == "" {
+		revision = info.revision
+	}
+
+	ip := importPath(function, file)
+	if revision == "" || info.module == "" || !strings.HasPrefix(ip, info.module+"/") {
+		return ""
+	}
+
+	return strings.NewReplacer(
+		"{module}", escapePath(info.module),
+		"{revision}", escapePath(revision),
+		"{path}", escapePath(ip[len(info.module)+1:]),
+		"{line}", strconv.Itoa(line),
+	).Replace(template)
+}
+
+// escapePath escapes each segment of the given slash-separated path for use in a URL path.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
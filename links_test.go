@@ -0,0 +1,83 @@
+package errors
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetSourceURLTemplate(t *testing.T) {
+	defer SetSourceURLTemplate("")
+	defer SetSourceRevision("")
+
+	t.Run("should not produce links by default", func(t *testing.T) {
+		assert.Empty(t, Stack(New("oops"))[0].URL)
+	})
+
+	t.Run("should produce links for frames in the main module", func(t *testing.T) {
+		SetSourceURLTemplate("https://git.example/{module}/blob/{revision}/{path}#L{line}")
+		SetSourceRevision("abc123")
+		defer SetSourceURLTemplate("")
+		defer SetSourceRevision("")
+
+		err := New("oops")
+		expected := fmt.Sprintf("https://git.example/github.com/icelolly/go-errors/blob/abc123/links_test.go#L%d", err.line)
+
+		assert.Equal(t, expected, Stack(err)[0].URL)
+	})
+
+	t.Run("should not produce links without a revision", func(t *testing.T) {
+		SetSourceURLTemplate("https://git.example/{module}/blob/{revision}/{path}#L{line}")
+		defer SetSourceURLTemplate("")
+
+		assert.Empty(t, Stack(New("oops"))[0].URL)
+	})
+
+	t.Run("should include links in printer output", func(t *testing.T) {
+		SetSourceURLTemplate("https://git.example/{path}#L{line}")
+		SetSourceRevision("abc123")
+		defer SetSourceURLTemplate("")
+		defer SetSourceRevision("")
+
+		buf := bytes.Buffer{}
+		require.NoError(t, NewPrinter(&buf).Print(New("oops")))
+
+		assert.Contains(t, buf.String(), "https://git.example/links_test.go#L")
+	})
+}
+
+func TestSourceURL(t *testing.T) {
+	SetSourceURLTemplate("https://git.example/{path}#L{line}")
+	SetSourceRevision("abc123")
+	defer SetSourceURLTemplate("")
+	defer SetSourceRevision("")
+
+	t.Run("should not produce links for frames outside of the main module", func(t *testing.T) {
+		assert.Empty(t, sourceURL("net/http.(*Server).Serve", "/usr/local/go/src/net/http/server.go", 10))
+	})
+
+	t.Run("should not produce links for frames without file information", func(t *testing.T) {
+		assert.Empty(t, sourceURL("", "", 0))
+	})
+
+	t.Run("should escape placeholder values", func(t *testing.T) {
+		SetSourceURLTemplate("https://git.example/blob/{revision}/{path}#L{line}")
+		SetSourceRevision("release/1.0 rc#1")
+		defer SetSourceURLTemplate("https://git.example/{path}#L{line}")
+		defer SetSourceRevision("abc123")
+
+		err := New("oops")
+		expected := fmt.Sprintf("https://git.example/blob/release/1.0%%20rc%%231/links_test.go#L%d", err.line)
+
+		assert.Equal(t, expected, sourceURL(err.caller, err.file, err.line))
+	})
+}
+
+func TestEscapePath(t *testing.T) {
+	assert.Equal(t, "", escapePath(""))
+	assert.Equal(t, "internal/links_test.go", escapePath("internal/links_test.go"))
+	assert.Equal(t, "my%20dir/100%25%3F.go", escapePath("my dir/100%?.go"))
+}
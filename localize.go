@@ -0,0 +1,123 @@
+package errors
+
+import (
+	"fmt"
+	"sync"
+)
+
+// LocalMessage is a user-facing message that is looked up in a Catalog, so that it can be shown in
+// the user's own language. Create one using Msg, and pass it to New or Wrap.
+type LocalMessage struct {
+	// Key identifies the message in a Catalog, e.g. "user.not_found".
+	Key string
+
+	// Params are values that may be substituted into the message, e.g. a user's name.
+	Params map[string]interface{}
+}
+
+// Msg returns a new LocalMessage for the given key, with the given key/value pairs as parameters.
+// Just like WithFields, keys must be strings, and an even number of arguments must be given.
+//
+// Example usage:
+//
+//    err := errors.New(ErrNotFound, errors.Msg("user.not_found", "name", name))
+//
+func Msg(key string, kvs ...interface{}) *LocalMessage {
+	kvc := len(kvs)
+
+	if kvc%2 != 0 {
+		Fatal(New(fmt.Sprintf(
+			"errors: invalid argument count for Msg, expected even number of params, got %d",
+			kvc,
+		)))
+	}
+
+	msg := &LocalMessage{
+		Key:    key,
+		Params: make(map[string]interface{}, kvc/2),
+	}
+
+	for i := 0; i < kvc; i = i + 2 {
+		k, ok := kvs[i].(string)
+		if !ok {
+			Fatal(New(fmt.Sprintf("errors: invalid type for key passed to Msg at index %d", i)))
+		}
+
+		msg.Params[k] = kvs[i+1]
+	}
+
+	return msg
+}
+
+// Catalog provides translations of messages. The i18n package provides a Catalog that loads
+// messages from JSON and YAML files, but any implementation may be used.
+type Catalog interface {
+	// Localize returns the message for the given key in the given language, with the given params
+	// substituted into it, and reports whether a message was found. An empty language should be
+	// treated as the catalog's default language.
+	Localize(lang, key string, params map[string]interface{}) (string, bool)
+}
+
+// Negotiator is implemented by catalogs that can pick the best language they have messages for, for
+// a given Accept-Language header value, e.g. "fr-CH, fr;q=0.9, en;q=0.8". The i18n package's Catalog
+// implements it.
+type Negotiator interface {
+	// Negotiate returns the best language for the given Accept-Language header value, or an empty
+	// string (i.e. the default language) if none of the languages in the header are available.
+	Negotiate(acceptLanguage string) string
+}
+
+// catalog is the Catalog used to look up LocalMessages.
+var catalog = struct {
+	sync.RWMutex
+	c Catalog
+}{}
+
+// SetCatalog sets the Catalog used to look up LocalMessages by Message and MessageFor.
+func SetCatalog(c Catalog) {
+	catalog.Lock()
+	catalog.c = c
+	catalog.Unlock()
+}
+
+// MessageFor returns a human-readable error message in the given language. It works the same way
+// as Message, but if an error in the stack has a LocalMessage, it's looked up in the Catalog (see
+// SetCatalog) using the given language. Errors whose LocalMessage isn't in the catalog are skipped,
+// and the search for a message carries on down the stack.
+func MessageFor(err error, lang string) string {
+	if err == nil {
+		return ""
+	}
+
+	return withReference(err, message(err, lang))
+}
+
+// NegotiateLanguage returns the language that messages should be looked up in for the given
+// Accept-Language header value, if the Catalog (see SetCatalog) is a Negotiator. Otherwise, or if
+// none of the languages in the header are available, an empty string is returned, which means the
+// catalog's default language. The httperr package uses this to pick the language of responses.
+func NegotiateLanguage(acceptLanguage string) string {
+	catalog.RLock()
+	c := catalog.c
+	catalog.RUnlock()
+
+	n, ok := c.(Negotiator)
+	if !ok || acceptLanguage == "" {
+		return ""
+	}
+
+	return n.Negotiate(acceptLanguage)
+}
+
+// localize looks up the given LocalMessage in the Catalog.
+func localize(msg *LocalMessage, lang string) (string, bool) {
+	catalog.RLock()
+	c := catalog.c
+	catalog.RUnlock()
+
+	if c == nil {
+		return "", false
+	}
+
+	return c.Localize(lang, msg.Key, msg.Params)
+}
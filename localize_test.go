@@ -0,0 +1,123 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCatalog is a Catalog that has a single message, in English and French.
+type testCatalog struct{}
+
+func (testCatalog) Localize(lang, key string, params map[string]interface{}) (string, bool) {
+	if key != "user.not_found" {
+		return "", false
+	}
+
+	if lang == "fr" {
+		return fmt.Sprintf("Utilisateur %v introuvable", params["name"]), true
+	}
+
+	return fmt.Sprintf("User %v not found", params["name"]), true
+}
+
+// negotiatingCatalog is a testCatalog that picks French if it's acceptable at all.
+type negotiatingCatalog struct {
+	testCatalog
+}
+
+func (negotiatingCatalog) Negotiate(acceptLanguage string) string {
+	if strings.Contains(acceptLanguage, "fr") {
+		return "fr"
+	}
+
+	return ""
+}
+
+func TestMsg(t *testing.T) {
+	t.Run("should set the key and params", func(t *testing.T) {
+		msg := Msg("user.not_found", "name", "Laureen")
+
+		assert.Equal(t, "user.not_found", msg.Key)
+		assert.Equal(t, map[string]interface{}{"name": "Laureen"}, msg.Params)
+	})
+
+	t.Run("should panic if an odd number of arguments is given", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_ = Msg("user.not_found", "name")
+		})
+	})
+
+	t.Run("should panic if a non-string value is given as a key", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_ = Msg("user.not_found", 1234, 1234)
+		})
+	})
+
+	t.Run("should show the key in logs", func(t *testing.T) {
+		err := New(Msg("user.not_found", "name", "Laureen"))
+
+		assert.Contains(t, err.Error(), ": user.not_found")
+		assert.Equal(t, "user.not_found", Stack(err)[0].Message)
+	})
+}
+
+func TestMessageFor(t *testing.T) {
+	SetCatalog(testCatalog{})
+	defer SetCatalog(nil)
+
+	t.Run("should return an empty message on nil error", func(t *testing.T) {
+		assert.Equal(t, "", MessageFor(nil, "fr"))
+	})
+
+	t.Run("should look up messages in the given language", func(t *testing.T) {
+		err := New(Msg("user.not_found", "name", "Laureen"))
+
+		assert.Equal(t, "Utilisateur Laureen introuvable", MessageFor(err, "fr"))
+		assert.Equal(t, "User Laureen not found", MessageFor(err, "en"))
+		assert.Equal(t, "User Laureen not found", Message(err))
+	})
+
+	t.Run("should look up messages further down the stack", func(t *testing.T) {
+		err := Wrap(New(Msg("user.not_found", "name", "Laureen")))
+
+		assert.Equal(t, "Utilisateur Laureen introuvable", MessageFor(err, "fr"))
+	})
+
+	t.Run("should skip messages that aren't in the catalog", func(t *testing.T) {
+		err := Wrap(New("oops"), Msg("missing"))
+
+		assert.Equal(t, "oops", MessageFor(err, "fr"))
+	})
+
+	t.Run("should skip messages if there's no catalog", func(t *testing.T) {
+		SetCatalog(nil)
+		defer SetCatalog(testCatalog{})
+
+		err := New(Msg("user.not_found", "name", "Laureen"))
+
+		assert.Equal(t, fallbackMessage(), MessageFor(err, "fr"))
+	})
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	defer SetCatalog(nil)
+
+	t.Run("should use the catalog to pick a language", func(t *testing.T) {
+		SetCatalog(negotiatingCatalog{})
+
+		assert.Equal(t, "fr", NegotiateLanguage("fr-CH, fr;q=0.9, en;q=0.8"))
+		assert.Equal(t, "", NegotiateLanguage("de"))
+		assert.Equal(t, "", NegotiateLanguage(""))
+	})
+
+	t.Run("should use the default language if the catalog can't negotiate", func(t *testing.T) {
+		SetCatalog(testCatalog{})
+		assert.Equal(t, "", NegotiateLanguage("fr"))
+
+		SetCatalog(nil)
+		assert.Equal(t, "", NegotiateLanguage("fr"))
+	})
+}
@@ -0,0 +1,455 @@
+// Package metrics counts errors by their kind, severity, caller, or fields, and exposes the counts
+// using expvar, or in the Prometheus text exposition format, without depending on a metrics client
+// library. Counting is lock-free once a combination of labels has been seen, so it's cheap enough
+// to do for every error that's created.
+//
+// The number of label combinations (series) is bounded in two ways. Only the labels in a counter's
+// allow-list are recorded, and once a counter has MaxSeries series, further combinations are all
+// counted in a single overflow series, whose label values are all "other".
+//
+// Errors can be counted as they're created (see Enable), or when they're handled, e.g. when they're
+// logged (see Counter.Observe). Errors counted as they're created only have the fields they were
+// created with, so labels taken from fields should be counted when errors are handled instead.
+//
+// Example usage:
+//
+//    counter := metrics.NewCounter(metrics.Options{})
+//
+//    metrics.Enable(counter)
+//    counter.Publish("errors")
+//
+//    http.Handle("/metrics", counter)
+//
+//    // Errors can be counted by their fields once they've been set, e.g. when they're logged.
+//    handled := metrics.NewCounter(metrics.Options{
+//        Name:   "http_errors_total",
+//        Labels: []string{metrics.LabelKind, "status"},
+//    })
+//
+//    handled.Observe(err)
+//
+package metrics
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/icelolly/go-errors"
+)
+
+// Labels that are built in. Any other label in a counter's allow-list is taken from the field with
+// the same name (see errors.Fields).
+const (
+	// LabelKind is the error's kind (see errors.Classify).
+	LabelKind = "kind"
+
+	// LabelSeverity is the severity registered for the error's kind, or its nearest parent kind
+	// that has one (see errors.RegisterKind).
+	LabelSeverity = "severity"
+
+	// LabelCaller is the function that the error originated in, i.e. the caller of the deepest
+	// *errors.Error in its stack. Callers make for a lot of series, so it's not a default label.
+	LabelCaller = "caller"
+)
+
+// Default values used for any Options fields that are not set.
+const (
+	DefaultName      = "errors_total"
+	DefaultHelp      = "Number of errors that have occurred."
+	DefaultMaxSeries = 1000
+)
+
+// DefaultLabels is the allow-list of labels used when Options.Labels is nil.
+var DefaultLabels = []string{LabelKind, LabelSeverity}
+
+// OverflowValue is the value of every label of the series that's counted once a counter has
+// reached its maximum number of series.
+const OverflowValue = "other"
+
+// ContentType is the media type of the Prometheus text exposition format written by Counter.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Options configures a Counter.
+type Options struct {
+	// Name is the name of the metric, e.g. "myapp_errors_total". If it's empty, DefaultName is used.
+	Name string
+
+	// Help is the description of the metric. If it's empty, DefaultHelp is used.
+	Help string
+
+	// Labels is the allow-list of labels that are recorded. If it's nil, DefaultLabels is used. If
+	// it's empty, but not nil, errors are only counted in total.
+	Labels []string
+
+	// MaxSeries is the maximum number of series (i.e. combinations of label values) that are counted
+	// separately. If it's zero, DefaultMaxSeries is used.
+	MaxSeries int
+}
+
+// Counter counts errors by the labels in its allow-list. It's safe for concurrent use. Counter is
+// an http.Handler that writes its counts in the Prometheus text exposition format, and an expvar.Var
+// that writes them as a JSON object.
+type Counter struct {
+	name      string
+	help      string
+	labels    []string
+	maxSeries int64
+
+	// series holds a *series for each combination of label values seen, keyed by those values.
+	series   sync.Map
+	size     int64
+	overflow *series
+}
+
+// series is the count for a single combination of label values.
+type series struct {
+	// count is first so that it's 64-bit aligned on 32-bit platforms, as it's accessed atomically.
+	count  uint64
+	values []string
+}
+
+// Sample is the count for a single combination of label values.
+type Sample struct {
+	Labels map[string]string
+	Count  uint64
+}
+
+// NewCounter returns a new Counter, configured using the given options. It panics if the name or
+// any of the labels are not valid Prometheus names.
+func NewCounter(opts Options) *Counter {
+	if opts.Name == "" {
+		opts.Name = DefaultName
+	}
+
+	if opts.Help == "" {
+		opts.Help = DefaultHelp
+	}
+
+	if opts.Labels == nil {
+		opts.Labels = DefaultLabels
+	}
+
+	if opts.MaxSeries <= 0 {
+		opts.MaxSeries = DefaultMaxSeries
+	}
+
+	if !validName(opts.Name, true) {
+		errors.Fatal(errors.New(fmt.Sprintf("metrics: invalid metric name %q", opts.Name)))
+	}
+
+	seen := make(map[string]bool, len(opts.Labels))
+	for _, label := range opts.Labels {
+		if !validName(label, false) || strings.HasPrefix(label, "__") {
+			errors.Fatal(errors.New(fmt.Sprintf("metrics: invalid label name %q", label)))
+		}
+
+		if seen[label] {
+			errors.Fatal(errors.New(fmt.Sprintf("metrics: duplicate label name %q", label)))
+		}
+
+		seen[label] = true
+	}
+
+	labels := make([]string, len(opts.Labels))
+	copy(labels, opts.Labels)
+
+	overflow := &series{values: make([]string, len(labels))}
+	for i := range overflow.values {
+		overflow.values[i] = OverflowValue
+	}
+
+	return &Counter{
+		name:      opts.Name,
+		help:      opts.Help,
+		labels:    labels,
+		maxSeries: int64(opts.MaxSeries),
+		overflow:  overflow,
+	}
+}
+
+// Enable counts every error that originates in this program using the given counter, by setting
+// the hook called when errors are created (see errors.SetCreateHook). Errors that wrap another
+// *errors.Error aren't counted, so that each error is only counted once, where it first occurred.
+// Passing nil stops errors being counted.
+//
+// Errors are counted as soon as they're created, before any fields are added using WithField, so
+// the only fields that can be used as labels are those passed to errors.New or errors.Wrap as a map,
+// or stored in the context the error was created with (see errors.WithContextFields). The built-in
+// labels always work. To count errors by other fields, use Observe where errors are handled.
+func Enable(c *Counter) {
+	if c == nil {
+		errors.SetCreateHook(nil)
+		return
+	}
+
+	errors.SetCreateHook(c.created)
+}
+
+// created is the hook set by Enable.
+func (c *Counter) created(err *errors.Error) {
+	if _, ok := err.Cause.(*errors.Error); ok {
+		return
+	}
+
+	c.Observe(err)
+}
+
+// Observe counts the given error. This is useful for counting errors when they're handled, e.g.
+// when they're logged, rather than when they're created. Nil errors are ignored.
+func (c *Counter) Observe(err error) {
+	if err == nil {
+		return
+	}
+
+	values := c.values(err)
+	key := strings.Join(values, "\xff")
+
+	if s, ok := c.series.Load(key); ok {
+		atomic.AddUint64(&s.(*series).count, 1)
+		return
+	}
+
+	atomic.AddUint64(&c.add(key, values).count, 1)
+}
+
+// add returns the series for the given label values, creating it if there's room for it, or the
+// overflow series if not.
+func (c *Counter) add(key string, values []string) *series {
+	// Room is reserved for the series first, so that concurrent calls can't go over the limit.
+	if atomic.AddInt64(&c.size, 1) > c.maxSeries {
+		atomic.AddInt64(&c.size, -1)
+		return c.overflow
+	}
+
+	s, loaded := c.series.LoadOrStore(key, &series{values: values})
+	if loaded {
+		// Another goroutine added the same series first, so the reserved room isn't needed.
+		atomic.AddInt64(&c.size, -1)
+	}
+
+	return s.(*series)
+}
+
+// values returns the value of each of the counter's labels for the given error.
+func (c *Counter) values(err error) []string {
+	values := make([]string, len(c.labels))
+
+	var (
+		kind   errors.Kind
+		fields map[string]interface{}
+	)
+
+	for i, label := range c.labels {
+		switch label {
+		case LabelKind, LabelSeverity:
+			if kind == "" {
+				kind = errors.Classify(err)
+			}
+
+			if label == LabelKind {
+				values[i] = string(kind)
+			} else {
+				values[i] = string(errors.KindSeverity(kind))
+			}
+		case LabelCaller:
+			values[i] = origin(err)
+		default:
+			if fields == nil {
+				fields = errors.Fields(err)
+			}
+
+			if v, ok := fields[label]; ok {
+				values[i] = fmt.Sprint(v)
+			}
+		}
+	}
+
+	return values
+}
+
+// Samples returns the count for each combination of label values seen so far, sorted by their label
+// values.
+func (c *Counter) Samples() []Sample {
+	all := c.snapshot()
+
+	samples := make([]Sample, len(all))
+	for i, s := range all {
+		labels := make(map[string]string, len(c.labels))
+		for j, label := range c.labels {
+			labels[label] = s.values[j]
+		}
+
+		samples[i] = Sample{
+			Labels: labels,
+			Count:  s.count,
+		}
+	}
+
+	return samples
+}
+
+// snapshot returns a copy of every series with a count, sorted by their label values.
+func (c *Counter) snapshot() []series {
+	var all []series
+
+	c.series.Range(func(_, v interface{}) bool {
+		s := v.(*series)
+		all = append(all, series{count: atomic.LoadUint64(&s.count), values: s.values})
+		return true
+	})
+
+	sort.Slice(all, func(i, j int) bool {
+		for k := range all[i].values {
+			if all[i].values[k] != all[j].values[k] {
+				return all[i].values[k] < all[j].values[k]
+			}
+		}
+
+		return false
+	})
+
+	if count := atomic.LoadUint64(&c.overflow.count); count > 0 {
+		all = append(all, series{count: count, values: c.overflow.values})
+	}
+
+	return all
+}
+
+// WriteTo writes the counter's counts to w in the Prometheus text exposition format.
+func (c *Counter) WriteTo(w io.Writer) (int64, error) {
+	buf := bytes.Buffer{}
+
+	buf.WriteString("# HELP ")
+	buf.WriteString(c.name)
+	buf.WriteString(" ")
+	buf.WriteString(escapeHelp(c.help))
+	buf.WriteString("\n# TYPE ")
+	buf.WriteString(c.name)
+	buf.WriteString(" counter\n")
+
+	for _, s := range c.snapshot() {
+		buf.WriteString(c.name)
+
+		if len(c.labels) > 0 {
+			buf.WriteString("{")
+
+			for i, label := range c.labels {
+				if i > 0 {
+					buf.WriteString(",")
+				}
+
+				buf.WriteString(label)
+				buf.WriteString(`="`)
+				buf.WriteString(escapeLabelValue(s.values[i]))
+				buf.WriteString(`"`)
+			}
+
+			buf.WriteString("}")
+		}
+
+		buf.WriteString(" ")
+		buf.WriteString(strconv.FormatUint(s.count, 10))
+		buf.WriteString("\n")
+	}
+
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP writes the counter's counts in the Prometheus text exposition format.
+func (c *Counter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	c.WriteTo(w)
+}
+
+// String returns the counter's counts as a JSON object, so that Counter satisfies expvar.Var. Each
+// key is made from the label values, e.g. "kind=not_found,severity=warning".
+func (c *Counter) String() string {
+	buf := bytes.Buffer{}
+	buf.WriteString("{")
+
+	for i, s := range c.snapshot() {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		pairs := make([]string, len(c.labels))
+		for j, label := range c.labels {
+			pairs[j] = label + "=" + s.values[j]
+		}
+
+		buf.WriteString(strconv.Quote(strings.Join(pairs, ",")))
+		buf.WriteString(":")
+		buf.WriteString(strconv.FormatUint(s.count, 10))
+	}
+
+	buf.WriteString("}")
+
+	return buf.String()
+}
+
+// Publish publishes the counter using expvar, under the given name. Like expvar.Publish, it panics
+// if the name is already in use.
+func (c *Counter) Publish(name string) {
+	expvar.Publish(name, c)
+}
+
+// origin returns the caller of the deepest *errors.Error in the given error's stack.
+func origin(err error) string {
+	e, ok := err.(*errors.Error)
+	if !ok {
+		return ""
+	}
+
+	for {
+		cause, ok := e.Cause.(*errors.Error)
+		if !ok {
+			return e.Caller()
+		}
+
+		e = cause
+	}
+}
+
+// validName reports whether the given string is a valid Prometheus metric name, or label name if
+// isMetric is false. Only metric names may contain colons.
+func validName(name string, isMetric bool) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		case r == ':' && isMetric:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// labelValueReplacer escapes label values, as required by the Prometheus text exposition format.
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// helpReplacer escapes help text, as required by the Prometheus text exposition format.
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// escapeLabelValue escapes the given label value.
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+// escapeHelp escapes the given help text.
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
@@ -0,0 +1,247 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/icelolly/go-errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKind       = errors.Kind("metrics test: not found")
+	testChildKind  = errors.Kind("metrics test: user not found")
+	testPlainKind  = errors.Kind("metrics test: plain")
+	testSeverity   = errors.SeverityWarning
+	testMetricName = "test_errors_total"
+)
+
+func init() {
+	errors.RegisterKind(testKind, errors.KindInfo{Severity: testSeverity})
+	errors.RegisterKind(testChildKind, errors.KindInfo{Parent: testKind})
+}
+
+func TestNewCounter(t *testing.T) {
+	t.Run("should use defaults", func(t *testing.T) {
+		c := NewCounter(Options{})
+
+		assert.Equal(t, DefaultName, c.name)
+		assert.Equal(t, DefaultHelp, c.help)
+		assert.Equal(t, DefaultLabels, c.labels)
+		assert.Equal(t, int64(DefaultMaxSeries), c.maxSeries)
+	})
+
+	t.Run("should panic given invalid names", func(t *testing.T) {
+		invalid := []Options{
+			{Name: "1errors"},
+			{Name: "errors-total"},
+			{Labels: []string{"kind:name"}},
+			{Labels: []string{"__kind"}},
+			{Labels: []string{""}},
+			{Labels: []string{LabelKind, LabelKind}},
+		}
+
+		for _, opts := range invalid {
+			assert.Panics(t, func() { NewCounter(opts) }, "options: %+v", opts)
+		}
+	})
+
+	t.Run("should accept valid names", func(t *testing.T) {
+		assert.NotPanics(t, func() {
+			NewCounter(Options{Name: "app:errors_total", Labels: []string{"_status", "Code2"}})
+		})
+	})
+}
+
+func TestCounter_Observe(t *testing.T) {
+	t.Run("should count errors by label", func(t *testing.T) {
+		c := NewCounter(Options{Labels: []string{LabelKind, LabelSeverity, LabelCaller, "status"}})
+
+		root := errors.New(testChildKind, "not found").WithField("status", 404)
+
+		c.Observe(root)
+		c.Observe(errors.Wrap(root, "wrapped"))
+		c.Observe(errors.New(testPlainKind))
+		c.Observe(nil)
+
+		assert.Equal(t, []Sample{
+			{
+				Labels: map[string]string{
+					LabelKind:     string(testPlainKind),
+					LabelSeverity: "",
+					LabelCaller:   "metrics.TestCounter_Observe.func1",
+					"status":      "",
+				},
+				Count: 1,
+			},
+			{
+				Labels: map[string]string{
+					LabelKind:     string(testChildKind),
+					LabelSeverity: string(testSeverity),
+					LabelCaller:   "metrics.TestCounter_Observe.func1",
+					"status":      "404",
+				},
+				Count: 2,
+			},
+		}, c.Samples())
+	})
+
+	t.Run("should count errors in total without labels", func(t *testing.T) {
+		c := NewCounter(Options{Labels: []string{}})
+
+		c.Observe(errors.New(testKind))
+		c.Observe(errors.New(testPlainKind))
+
+		assert.Equal(t, []Sample{{Labels: map[string]string{}, Count: 2}}, c.Samples())
+	})
+
+	t.Run("should count errors over the maximum number of series as overflow", func(t *testing.T) {
+		c := NewCounter(Options{Labels: []string{"id"}, MaxSeries: 2})
+
+		for _, id := range []int{1, 2, 3, 4, 1} {
+			c.Observe(errors.New("oops").WithField("id", id))
+		}
+
+		assert.Equal(t, []Sample{
+			{Labels: map[string]string{"id": "1"}, Count: 2},
+			{Labels: map[string]string{"id": "2"}, Count: 1},
+			{Labels: map[string]string{"id": OverflowValue}, Count: 2},
+		}, c.Samples())
+	})
+
+	t.Run("should be safe for concurrent use", func(t *testing.T) {
+		c := NewCounter(Options{Labels: []string{"id"}, MaxSeries: 5})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				for j := 0; j < 100; j++ {
+					c.Observe(errors.New("oops").WithField("id", j%10))
+				}
+			}(i)
+		}
+
+		wg.Wait()
+
+		samples := c.Samples()
+		assert.Len(t, samples, 6)
+
+		var total uint64
+		for _, s := range samples {
+			total += s.Count
+		}
+
+		assert.Equal(t, uint64(1000), total)
+	})
+}
+
+func TestEnable(t *testing.T) {
+	defer Enable(nil)
+
+	c := NewCounter(Options{Labels: []string{LabelKind}})
+	Enable(c)
+
+	err := errors.New(testKind)
+	errors.Wrap(err, "wrapped")
+	errors.Wrap(errors.Wrap(err))
+	errors.Wrap(http.ErrHandlerTimeout, testPlainKind)
+
+	Enable(nil)
+	errors.New(testKind)
+
+	assert.Equal(t, []Sample{
+		{Labels: map[string]string{LabelKind: string(testKind)}, Count: 1},
+		{Labels: map[string]string{LabelKind: string(testPlainKind)}, Count: 1},
+	}, c.Samples())
+
+	t.Run("should only have the fields errors were created with", func(t *testing.T) {
+		defer Enable(nil)
+
+		c := NewCounter(Options{Labels: []string{LabelKind, "status"}})
+		Enable(c)
+
+		ctx := errors.WithContextFields(context.Background(), "status", 502)
+
+		errors.New(testKind, map[string]interface{}{"status": 404})
+		errors.New(ctx, testKind)
+		errors.New(testKind).WithField("status", 500)
+
+		assert.Equal(t, []Sample{
+			{Labels: map[string]string{LabelKind: string(testKind), "status": ""}, Count: 1},
+			{Labels: map[string]string{LabelKind: string(testKind), "status": "404"}, Count: 1},
+			{Labels: map[string]string{LabelKind: string(testKind), "status": "502"}, Count: 1},
+		}, c.Samples())
+	})
+}
+
+func TestCounter_WriteTo(t *testing.T) {
+	c := NewCounter(Options{
+		Name:   testMetricName,
+		Help:   "Errors.\nAll of them, \\o/",
+		Labels: []string{LabelKind, "detail"},
+	})
+
+	c.Observe(errors.New(testKind).WithField("detail", "a \"quoted\"\nvalue\\"))
+	c.Observe(errors.New(testKind).WithField("detail", "a \"quoted\"\nvalue\\"))
+	c.Observe(errors.New("oops"))
+
+	buf := strings.Builder{}
+	_, err := c.WriteTo(&buf)
+	require.NoError(t, err)
+
+	expected := `# HELP test_errors_total Errors.\nAll of them, \\o/
+# TYPE test_errors_total counter
+test_errors_total{kind="",detail=""} 1
+test_errors_total{kind="metrics test: not found",detail="a \"quoted\"\nvalue\\"} 2
+`
+
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestCounter_ServeHTTP(t *testing.T) {
+	c := NewCounter(Options{Name: testMetricName})
+	c.Observe(errors.New(testKind))
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `test_errors_total{kind="metrics test: not found",severity="warning"} 1`)
+}
+
+func TestCounter_String(t *testing.T) {
+	c := NewCounter(Options{})
+
+	assert.Equal(t, "{}", c.String())
+
+	c.Observe(errors.New(testKind))
+	c.Observe(errors.New(testKind))
+	c.Observe(errors.New("oops"))
+
+	var counts map[string]uint64
+	require.NoError(t, json.Unmarshal([]byte(c.String()), &counts))
+
+	assert.Equal(t, map[string]uint64{
+		"kind=,severity=":                               1,
+		"kind=metrics test: not found,severity=warning": 2,
+	}, counts)
+}
+
+func TestCounter_Publish(t *testing.T) {
+	c := NewCounter(Options{})
+	c.Publish("metrics_test_errors")
+
+	assert.Equal(t, c, expvar.Get("metrics_test_errors"))
+}
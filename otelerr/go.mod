@@ -0,0 +1,19 @@
+module github.com/icelolly/go-errors/otelerr
+
+go 1.20
+
+require (
+	github.com/icelolly/go-errors v1.1.0
+	github.com/stretchr/testify v1.12.1
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require go.yaml.in/yaml/v3 v3.0.5 // indirect
+
+// The replace directive only applies when working in this repository, so that changes to the root
+// module can be tested here before they're released. It's ignored by modules that depend on this
+// one, which use the version required above. That version must be a tagged release of the root
+// module, so when releasing, tag the root module first (e.g. v1.1.0), update the version above to
+// that tag, and only then tag this module (e.g. otelerr/v1.1.0).
+replace github.com/icelolly/go-errors => ../
@@ -0,0 +1,231 @@
+// Package otelerr records errors on OpenTelemetry spans, following the OpenTelemetry semantic
+// conventions for exceptions. It's a separate module, so that the core errors package doesn't
+// depend on OpenTelemetry.
+//
+// Each error is recorded as an "exception" event, with the following attributes:
+//
+//	exception.type        the error's kind (see errors.Classify), or its Go type if it has none
+//	exception.message     the error's message (i.e. err.Error())
+//	exception.stacktrace  the error's verbose (%+v) format
+//
+// The error's fields (see errors.Fields) are added as attributes too.
+//
+// Example usage:
+//
+//	ctx, span := tracer.Start(ctx, "users.Find")
+//	defer span.End()
+//
+//	user, err := find(ctx, id)
+//	if err != nil {
+//	    otelerr.Record(span, err)
+//	    return nil, err
+//	}
+package otelerr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/icelolly/go-errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Names of the event and attributes used to record exceptions, as defined by the OpenTelemetry
+// semantic conventions.
+const (
+	EventName     = "exception"
+	TypeKey       = attribute.Key("exception.type")
+	MessageKey    = attribute.Key("exception.message")
+	StacktraceKey = attribute.Key("exception.stacktrace")
+)
+
+// Span is the part of an OpenTelemetry span that's used to record errors. It's satisfied by
+// trace.Span, but is kept small so that errors can be recorded on anything that looks like a span,
+// e.g. an in-memory recorder in tests.
+type Span interface {
+	IsRecording() bool
+	AddEvent(name string, options ...trace.EventOption)
+	SetStatus(code codes.Code, description string)
+}
+
+// Option configures how an error is recorded.
+type Option func(o *options)
+
+// options holds the configuration set by Options.
+type options struct {
+	fieldPrefix string
+	noStatus    bool
+	eventOpts   []trace.EventOption
+}
+
+// WithFieldPrefix adds the given prefix to the keys of the attributes made from the error's fields,
+// e.g. "app.", so that they can't clash with attributes defined by the semantic conventions.
+func WithFieldPrefix(prefix string) Option {
+	return func(o *options) {
+		o.fieldPrefix = prefix
+	}
+}
+
+// WithoutStatus stops the span's status being set to Error when an error is recorded, e.g. for
+// errors that are expected, and handled.
+func WithoutStatus() Option {
+	return func(o *options) {
+		o.noStatus = true
+	}
+}
+
+// WithEventOptions adds the given options to the recorded event, e.g. trace.WithTimestamp.
+func WithEventOptions(opts ...trace.EventOption) Option {
+	return func(o *options) {
+		o.eventOpts = append(o.eventOpts, opts...)
+	}
+}
+
+// Record records the given error on the given span as an exception event, and sets the span's
+// status to Error, using the error's user-facing message (see errors.Message) as its description.
+// Nothing is recorded if the error is nil, or the span isn't recording.
+func Record(span Span, err error, opts ...Option) {
+	if err == nil || span == nil || !span.IsRecording() {
+		return
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	eventOpts := make([]trace.EventOption, 0, len(o.eventOpts)+1)
+	eventOpts = append(eventOpts, trace.WithAttributes(attributes(err, o.fieldPrefix)...))
+	eventOpts = append(eventOpts, o.eventOpts...)
+
+	span.AddEvent(EventName, eventOpts...)
+
+	if !o.noStatus {
+		span.SetStatus(codes.Error, errors.Message(err))
+	}
+}
+
+// Attributes returns the attributes that Record adds to the exception event for the given error.
+// This is useful for recording errors in other places, e.g. on log records.
+func Attributes(err error) []attribute.KeyValue {
+	if err == nil {
+		return nil
+	}
+
+	return attributes(err, "")
+}
+
+// attributes returns the attributes for the given error, with the given prefix added to the keys
+// of attributes made from the error's fields.
+func attributes(err error, fieldPrefix string) []attribute.KeyValue {
+	fields := errors.Fields(err)
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	attrs := make([]attribute.KeyValue, 0, len(keys)+3)
+	attrs = append(attrs,
+		TypeKey.String(exceptionType(err)),
+		MessageKey.String(err.Error()),
+		StacktraceKey.String(fmt.Sprintf("%+v", err)),
+	)
+
+	for _, k := range keys {
+		attrs = append(attrs, Attribute(fieldPrefix+k, fields[k]))
+	}
+
+	return attrs
+}
+
+// exceptionType returns the type of the given error; its kind if it has one, or the Go type of the
+// error at the bottom of its stack otherwise.
+func exceptionType(err error) string {
+	if kind := errors.Classify(err); kind != "" {
+		return string(kind)
+	}
+
+	for {
+		e, ok := err.(*errors.Error)
+		if !ok || e.Cause == nil {
+			return fmt.Sprintf("%T", err)
+		}
+
+		err = e.Cause
+	}
+}
+
+// Attribute converts a field to an attribute. Values that attributes can hold (booleans, integers,
+// floats, strings, and slices of them) keep their type. Other values are converted to strings;
+// errors and fmt.Stringers using their own methods, and anything else as JSON if possible.
+func Attribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case nil:
+		return attribute.String(key, "<nil>")
+	case bool:
+		return attribute.Bool(key, v)
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int8:
+		return attribute.Int64(key, int64(v))
+	case int16:
+		return attribute.Int64(key, int64(v))
+	case int32:
+		return attribute.Int64(key, int64(v))
+	case int64:
+		return attribute.Int64(key, v)
+	case uint8:
+		return attribute.Int64(key, int64(v))
+	case uint16:
+		return attribute.Int64(key, int64(v))
+	case uint32:
+		return attribute.Int64(key, int64(v))
+	case uint:
+		return uintAttribute(key, uint64(v))
+	case uint64:
+		return uintAttribute(key, v)
+	case float32:
+		return attribute.Float64(key, float64(v))
+	case float64:
+		return attribute.Float64(key, v)
+	case []bool:
+		return attribute.BoolSlice(key, v)
+	case []string:
+		return attribute.StringSlice(key, v)
+	case []int:
+		return attribute.IntSlice(key, v)
+	case []int64:
+		return attribute.Int64Slice(key, v)
+	case []float64:
+		return attribute.Float64Slice(key, v)
+	case error:
+		return attribute.String(key, v.Error())
+	case fmt.Stringer:
+		return attribute.String(key, v.String())
+	}
+
+	if bs, err := json.Marshal(value); err == nil {
+		return attribute.String(key, string(bs))
+	}
+
+	return attribute.String(key, fmt.Sprintf("%+v", value))
+}
+
+// uintAttribute converts an unsigned integer to an attribute. Attributes can only hold signed
+// integers, so values that are too large are converted to strings rather than overflowing.
+func uintAttribute(key string, v uint64) attribute.KeyValue {
+	if v > math.MaxInt64 {
+		return attribute.String(key, fmt.Sprint(v))
+	}
+
+	return attribute.Int64(key, int64(v))
+}
@@ -0,0 +1,195 @@
+package otelerr
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span must be satisfied by real OpenTelemetry spans.
+var _ Span = trace.Span(nil)
+
+func TestRecord(t *testing.T) {
+	kind := errors.Kind("otelerr test: not found")
+
+	t.Run("should record an exception event", func(t *testing.T) {
+		span := &recorder{recording: true}
+		err := errors.Wrap(errors.New(kind, "user not found").WithField("user_id", 42), "lookup failed")
+
+		Record(span, err)
+
+		require.Len(t, span.events, 1)
+		assert.Equal(t, EventName, span.events[0].name)
+
+		attrs := span.events[0].attrs
+		assert.Equal(t, string(kind), attrs[TypeKey].AsString())
+		assert.Equal(t, err.Error(), attrs[MessageKey].AsString())
+		assert.True(t, strings.Contains(attrs[StacktraceKey].AsString(), "otelerr_test.go"))
+		assert.Equal(t, int64(42), attrs["user_id"].AsInt64())
+
+		assert.Equal(t, codes.Error, span.status)
+		assert.Equal(t, "lookup failed", span.description)
+	})
+
+	t.Run("should use the Go type of errors without a kind", func(t *testing.T) {
+		span := &recorder{recording: true}
+
+		Record(span, errors.Wrap(context.Canceled))
+		assert.Equal(t, string(kinds.Canceled), span.events[0].attrs[TypeKey].AsString())
+
+		Record(span, errors.Wrap(testError{}))
+		assert.Equal(t, "otelerr.testError", span.events[1].attrs[TypeKey].AsString())
+	})
+
+	t.Run("should add the field prefix", func(t *testing.T) {
+		span := &recorder{recording: true}
+
+		Record(span, errors.New("oops").WithField("user_id", 42), WithFieldPrefix("app."))
+
+		assert.Contains(t, span.events[0].attrs, attribute.Key("app.user_id"))
+		assert.NotContains(t, span.events[0].attrs, attribute.Key("user_id"))
+	})
+
+	t.Run("should not set the status if asked not to", func(t *testing.T) {
+		span := &recorder{recording: true}
+
+		Record(span, errors.New("oops"), WithoutStatus())
+
+		assert.Len(t, span.events, 1)
+		assert.Equal(t, codes.Unset, span.status)
+	})
+
+	t.Run("should pass on event options", func(t *testing.T) {
+		span := &recorder{recording: true}
+		ts := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+		Record(span, errors.New("oops"), WithEventOptions(trace.WithTimestamp(ts)))
+
+		assert.Equal(t, ts, span.events[0].timestamp)
+	})
+
+	t.Run("should do nothing for nil errors, or spans that aren't recording", func(t *testing.T) {
+		span := &recorder{recording: true}
+		Record(span, nil)
+		assert.Empty(t, span.events)
+
+		span = &recorder{}
+		Record(span, errors.New("oops"))
+		assert.Empty(t, span.events)
+		assert.Equal(t, codes.Unset, span.status)
+	})
+}
+
+func TestAttributes(t *testing.T) {
+	assert.Nil(t, Attributes(nil))
+
+	attrs := Attributes(errors.New("oops").WithFields("b", 2, "a", 1))
+	require.Len(t, attrs, 5)
+
+	assert.Equal(t, attribute.Key("a"), attrs[3].Key)
+	assert.Equal(t, attribute.Key("b"), attrs[4].Key)
+}
+
+func TestAttribute(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		value    interface{}
+		expected attribute.Value
+	}{
+		{nil, attribute.StringValue("<nil>")},
+		{true, attribute.BoolValue(true)},
+		{"value", attribute.StringValue("value")},
+		{42, attribute.IntValue(42)},
+		{int8(42), attribute.Int64Value(42)},
+		{int16(42), attribute.Int64Value(42)},
+		{int32(42), attribute.Int64Value(42)},
+		{int64(42), attribute.Int64Value(42)},
+		{uint8(42), attribute.Int64Value(42)},
+		{uint16(42), attribute.Int64Value(42)},
+		{uint32(42), attribute.Int64Value(42)},
+		{uint(42), attribute.Int64Value(42)},
+		{uint64(math.MaxUint64), attribute.StringValue("18446744073709551615")},
+		{float32(0.5), attribute.Float64Value(0.5)},
+		{1.5, attribute.Float64Value(1.5)},
+		{[]bool{true}, attribute.BoolSliceValue([]bool{true})},
+		{[]string{"a"}, attribute.StringSliceValue([]string{"a"})},
+		{[]int{1}, attribute.IntSliceValue([]int{1})},
+		{[]int64{1}, attribute.Int64SliceValue([]int64{1})},
+		{[]float64{1.5}, attribute.Float64SliceValue([]float64{1.5})},
+		{testError{}, attribute.StringValue("test error")},
+		{time.Second, attribute.StringValue("1s")},
+		{user{Name: "Elliot"}, attribute.StringValue(`{"name":"Elliot"}`)},
+		{func() {}, attribute.StringValue("")},
+	}
+
+	for _, test := range tests {
+		kv := Attribute("key", test.value)
+
+		assert.Equal(t, attribute.Key("key"), kv.Key)
+
+		if fn, ok := test.value.(func()); ok && fn != nil {
+			// Functions can't be encoded as JSON, so they fall back to being formatted with fmt.
+			assert.Equal(t, attribute.STRING, kv.Value.Type())
+			continue
+		}
+
+		assert.Equal(t, test.expected, kv.Value, "value: %#v", test.value)
+	}
+}
+
+// testError is an error without a kind.
+type testError struct{}
+
+func (testError) Error() string { return "test error" }
+
+// recorder is an in-memory Span.
+type recorder struct {
+	recording   bool
+	events      []event
+	status      codes.Code
+	description string
+}
+
+// event is an event added to a recorder.
+type event struct {
+	name      string
+	attrs     map[attribute.Key]attribute.Value
+	timestamp time.Time
+}
+
+func (r *recorder) IsRecording() bool {
+	return r.recording
+}
+
+func (r *recorder) AddEvent(name string, options ...trace.EventOption) {
+	cfg := trace.NewEventConfig(options...)
+
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range cfg.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+
+	r.events = append(r.events, event{
+		name:      name,
+		attrs:     attrs,
+		timestamp: cfg.Timestamp(),
+	})
+}
+
+func (r *recorder) SetStatus(code codes.Code, description string) {
+	r.status = code
+	r.description = description
+}
@@ -0,0 +1,212 @@
+package errors
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// PathStyle controls how the file paths recorded on errors are shown in output, e.g. in the verbose
+// (%+v) format, and in Stack.
+type PathStyle int32
+
+const (
+	// PathAbsolute shows file paths as they are reported by the runtime. Unless the binary was
+	// built using -trimpath, this will be the absolute path on the machine that built it, e.g.
+	// "/home/ci/go/src/github.com/icelolly/go-errors/errors.go". This is the default.
+	PathAbsolute PathStyle = iota
+
+	// PathImport shows file paths prefixed by the import path of the package they belong to, e.g.
+	// "github.com/icelolly/go-errors/errors.go". Standard library files are shown relative to
+	// GOROOT, e.g. "net/http/server.go". These paths are the same no matter which machine built
+	// the binary, or where the source code was on that machine.
+	PathImport
+
+	// PathModule shows file paths relative to the root of the main module, e.g. "example/main.go".
+	// Files outside of the main module are shown the same way as they are with PathImport.
+	PathModule
+)
+
+// Both of these settings are accessed atomically, as they may be changed whilst errors are being
+// created or formatted.
+var (
+	pathStyle       int32
+	fullCallerNames int32
+)
+
+// SetPathStyle sets the style used to show file paths.
+func SetPathStyle(style PathStyle) {
+	atomic.StoreInt32(&pathStyle, int32(style))
+}
+
+// SetFullCallerNames controls whether callers are shown with the full import path of the package
+// they belong to, e.g. "github.com/icelolly/go-errors.New" rather than "go-errors.New". Full names
+// are unambiguous, so they're useful when grouping errors from many services.
+func SetFullCallerNames(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+
+	atomic.StoreInt32(&fullCallerNames, v)
+}
+
+// callerName returns the name of the given function, as it should be shown in output.
+func callerName(function string) string {
+	return formatCaller(currentFullCallerNames(), function)
+}
+
+// currentFullCallerNames reports whether full caller names have been enabled.
+func currentFullCallerNames() bool {
+	return atomic.LoadInt32(&fullCallerNames) == 1
+}
+
+// formatCaller returns the name of the given function, either in full, or trimmed to just the last
+// element of the package path.
+func formatCaller(full bool, function string) string {
+	if full {
+		return function
+	}
+
+	return function[strings.LastIndex(function, "/")+1:]
+}
+
+// displayPath returns the given file path, normalised according to the current PathStyle.
+func displayPath(function, file string) string {
+	return normalisePath(currentPathStyle(), function, file)
+}
+
+// currentPathStyle returns the PathStyle that has been set using SetPathStyle.
+func currentPathStyle() PathStyle {
+	return PathStyle(atomic.LoadInt32(&pathStyle))
+}
+
+// normalisePath returns the given file path, normalised according to the given PathStyle.
+func normalisePath(style PathStyle, function, file string) string {
+	if file == "" {
+		return ""
+	}
+
+	switch style {
+	case PathImport:
+		return importPath(function, file)
+	case PathModule:
+		return modulePath(function, file)
+	}
+
+	return file
+}
+
+// modulePath returns the given file path relative to the root of the main module if it's part of
+// the main module, otherwise it returns the import path of the file.
+func modulePath(function, file string) string {
+	ip := importPath(function, file)
+
+	mod := buildInfo().module
+	if mod != "" && strings.HasPrefix(ip, mod+"/") {
+		return ip[len(mod)+1:]
+	}
+
+	return ip
+}
+
+// importPath returns the given file path, prefixed by the import path of the package it belongs
+// to. The package is identified using the name of the function the file path was recorded in.
+func importPath(function, file string) string {
+	// Binaries built with -trimpath will already have paths in this form.
+	if !filepath.IsAbs(file) {
+		return file
+	}
+
+	pkg := packagePath(function)
+	if pkg == "main" {
+		pkg = buildInfo().mainPackage
+	}
+
+	if pkg == "" {
+		return trimRoots(file)
+	}
+
+	return pkg + "/" + path.Base(file)
+}
+
+// packagePath returns the import path of the package that the given function belongs to, given a
+// fully qualified function name, e.g. "github.com/icelolly/go-errors.(*Error).Error".
+func packagePath(function string) string {
+	// Type parameters are shown in square brackets, and may contain slashes and dots.
+	if i := strings.Index(function, "["); i >= 0 {
+		function = function[:i]
+	}
+
+	ls := strings.LastIndex(function, "/") + 1
+
+	dot := strings.Index(function[ls:], ".")
+	if dot < 0 {
+		return ""
+	}
+
+	// Dots in the last element of the import path are escaped by the runtime, e.g. "yaml%2ev2".
+	pkg := strings.Replace(function[:ls+dot], "%2e", ".", -1)
+
+	return strings.TrimSuffix(pkg, "_test")
+}
+
+// trimRoots strips GOROOT and GOPATH from the start of the given file path. This is used as a
+// fallback when we can't work out which package a file belongs to.
+func trimRoots(file string) string {
+	roots := []string{filepath.Join(runtime.GOROOT(), "src")}
+
+	for _, p := range filepath.SplitList(os.Getenv("GOPATH")) {
+		roots = append(roots, filepath.Join(p, "src"), filepath.Join(p, "pkg", "mod"))
+	}
+
+	for _, root := range roots {
+		root = filepath.ToSlash(root) + "/"
+		if root != "/src/" && strings.HasPrefix(file, root) {
+			return file[len(root):]
+		}
+	}
+
+	return file
+}
+
+// buildDetails is the information we need from the binary's build information.
+type buildDetails struct {
+	// module is the path of the main module, e.g. "github.com/icelolly/go-errors".
+	module string
+
+	// mainPackage is the import path of the main package, e.g.
+	// "github.com/icelolly/go-errors/example". This is only available from Go 1.18.
+	mainPackage string
+
+	// revision is the version control revision the binary was built from. This is only available
+	// from Go 1.18, and only if the binary was built from within a repository.
+	revision string
+}
+
+var (
+	buildDetailsOnce sync.Once
+	buildDetailsVal  buildDetails
+)
+
+// buildInfo returns the details we need from the binary's build information. They're only read
+// once, as they can't change whilst the binary is running.
+func buildInfo() buildDetails {
+	buildDetailsOnce.Do(func() {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+
+		buildDetailsVal.module = info.Main.Path
+		buildDetailsVal.mainPackage = mainPackagePath(info)
+		buildDetailsVal.revision = vcsRevision(info)
+	})
+
+	return buildDetailsVal
+}
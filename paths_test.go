@@ -0,0 +1,94 @@
+package errors
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPathStyle(t *testing.T) {
+	defer SetPathStyle(PathAbsolute)
+
+	t.Run("should show absolute paths by default", func(t *testing.T) {
+		stack := Stack(New("oops"))
+		require.Len(t, stack, 1)
+
+		assert.True(t, filepath.IsAbs(stack[0].File))
+	})
+
+	t.Run("should show import paths", func(t *testing.T) {
+		SetPathStyle(PathImport)
+		defer SetPathStyle(PathAbsolute)
+
+		err := New("oops")
+
+		assert.Equal(t, "github.com/icelolly/go-errors/paths_test.go", Stack(err)[0].File)
+		assert.Contains(t, fmt.Sprintf("%+v", err), `File: "github.com/icelolly/go-errors/paths_test.go"`)
+	})
+
+	t.Run("should show module-relative paths", func(t *testing.T) {
+		SetPathStyle(PathModule)
+		defer SetPathStyle(PathAbsolute)
+
+		assert.Equal(t, "paths_test.go", Stack(New("oops"))[0].File)
+	})
+}
+
+func TestSetFullCallerNames(t *testing.T) {
+	defer SetFullCallerNames(false)
+
+	t.Run("should show trimmed caller names by default", func(t *testing.T) {
+		assert.Equal(t, "go-errors.TestSetFullCallerNames.func1", Stack(New("oops"))[0].Caller)
+	})
+
+	t.Run("should show full caller names if enabled", func(t *testing.T) {
+		SetFullCallerNames(true)
+		defer SetFullCallerNames(false)
+
+		err := New("oops")
+
+		assert.Equal(t, "github.com/icelolly/go-errors.TestSetFullCallerNames.func2", Stack(err)[0].Caller)
+		assert.Equal(t, "[github.com/icelolly/go-errors.TestSetFullCallerNames.func2]: oops", err.Error())
+	})
+}
+
+func TestImportPath(t *testing.T) {
+	t.Run("should use the package of the function", func(t *testing.T) {
+		path := importPath("github.com/icelolly/go-errors.(*Error).Error", "/home/ci/src/go-errors/errors.go")
+		assert.Equal(t, "github.com/icelolly/go-errors/errors.go", path)
+	})
+
+	t.Run("should handle standard library packages", func(t *testing.T) {
+		path := importPath("net/http.(*Server).Serve", "/usr/local/go/src/net/http/server.go")
+		assert.Equal(t, "net/http/server.go", path)
+	})
+
+	t.Run("should leave paths from -trimpath builds alone", func(t *testing.T) {
+		path := importPath("github.com/icelolly/go-errors.New", "github.com/icelolly/go-errors/errors.go")
+		assert.Equal(t, "github.com/icelolly/go-errors/errors.go", path)
+	})
+}
+
+func TestPackagePath(t *testing.T) {
+	tt := []struct {
+		function string
+		expected string
+	}{
+		{function: "github.com/icelolly/go-errors.New", expected: "github.com/icelolly/go-errors"},
+		{function: "github.com/icelolly/go-errors.(*Error).Error", expected: "github.com/icelolly/go-errors"},
+		{function: "github.com/icelolly/go-errors_test.TestNew.func1", expected: "github.com/icelolly/go-errors"},
+		{function: "gopkg.in/yaml%2ev2.Unmarshal", expected: "gopkg.in/yaml.v2"},
+		{function: "example.com/pkg.Map[...]", expected: "example.com/pkg"},
+		{function: "main.main", expected: "main"},
+		{function: "runtime", expected: ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.function, func(t *testing.T) {
+			assert.Equal(t, tc.expected, packagePath(tc.function))
+		})
+	}
+}
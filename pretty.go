@@ -0,0 +1,261 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ANSI escape sequences used by Printer when colour is enabled.
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiUnderline = "\x1b[4m"
+	ansiRed       = "\x1b[31m"
+	ansiYellow    = "\x1b[33m"
+	ansiBlue      = "\x1b[34m"
+	ansiCyan      = "\x1b[36m"
+)
+
+// Printer renders errors in a format designed to be read by a human in a terminal, i.e. whilst
+// debugging locally. The output contains the same information as the verbose (%+v) format, but
+// kinds are highlighted, file paths are dimmed, fields are laid out as an aligned table, and
+// values like structs and maps are pretty-printed as JSON. In development mode, a snippet of source
+// code is shown for each frame too.
+type Printer struct {
+	// Color enables ANSI colour codes in the output. NewPrinter will set this automatically based
+	// on the writer it's given, but it can be overridden afterwards.
+	Color bool
+
+	w io.Writer
+}
+
+// NewPrinter returns a new Printer that writes to w. Colour is enabled if w is a terminal, and the
+// NO_COLOR environment variable is not set (see https://no-color.org).
+func NewPrinter(w io.Writer) *Printer {
+	return &Printer{
+		Color: supportsColor(w),
+		w:     w,
+	}
+}
+
+// Fprint renders the given error to w using a Printer with automatically detected colour support.
+func Fprint(w io.Writer, err error) error {
+	return NewPrinter(w).Print(err)
+}
+
+// Print renders the given error, and all of its causes.
+func (p *Printer) Print(err error) error {
+	stack := Stack(err)
+
+	return p.printStack(stack, stackSources(err, len(stack)))
+}
+
+// PrintStack renders the given stack frames. This is useful if you have a stack that has already
+// been produced by Stack, e.g. one that has been decoded from JSON.
+func (p *Printer) PrintStack(stack []StackFrame) error {
+	return p.printStack(stack, frameSources(stack))
+}
+
+// frameSources returns the file path of each of the given stack frames. It's used to find snippets
+// for stacks that we don't have the original error for, so the paths may not have been reported by
+// the runtime, but it's the best we can do.
+func frameSources(stack []StackFrame) []string {
+	sources := make([]string, len(stack))
+	for i, frame := range stack {
+		sources[i] = frame.File
+	}
+
+	return sources
+}
+
+// stackSources returns the file path of each of the given number of errors in the given error's
+// stack. The file paths in a stack may have been normalised, so we need the paths as they were
+// reported by the runtime to be able to find source code for snippets.
+func stackSources(err error, size int) []string {
+	sources := make([]string, size)
+	for i := range sources {
+		e, ok := err.(*Error)
+		if !ok {
+			break
+		}
+
+		sources[i] = e.file
+		err = e.Cause
+	}
+
+	return sources
+}
+
+// printStack renders the given stack frames, using the given source file paths to find snippets.
+func (p *Printer) printStack(stack []StackFrame, sources []string) error {
+	buf := bytes.Buffer{}
+
+	for i, frame := range stack {
+		p.writeFrame(&buf, frame, sources[i], i > 0)
+	}
+
+	_, err := p.w.Write(buf.Bytes())
+	return err
+}
+
+// writeFrame renders a single stack frame to the given buffer.
+func (p *Printer) writeFrame(buf *bytes.Buffer, frame StackFrame, source string, isCause bool) {
+	if isCause {
+		buf.WriteString(p.paint(ansiDim, "Caused by"))
+	} else {
+		buf.WriteString(p.paint(ansiBold+ansiRed, "Error"))
+	}
+
+	if frame.Caller != "" {
+		buf.WriteString(": ")
+		buf.WriteString(p.paint(ansiCyan, "["+frame.Caller+"]"))
+	}
+
+	if frame.Message != "" {
+		buf.WriteString(": ")
+		buf.WriteString(p.paint(ansiBold, frame.Message))
+	}
+
+	if frame.Kind != "" {
+		buf.WriteString(" ")
+		buf.WriteString(p.paint(ansiBold+ansiYellow, "("+frame.Kind+")"))
+	}
+
+	buf.WriteString("\n")
+
+	if frame.File != "" {
+		buf.WriteString("    ")
+		buf.WriteString(p.paint(ansiDim, frame.File+":"+strconv.Itoa(frame.Line)))
+		buf.WriteString("\n")
+
+		if frame.URL != "" {
+			buf.WriteString("    ")
+			buf.WriteString(p.paint(ansiDim+ansiUnderline, frame.URL))
+			buf.WriteString("\n")
+		}
+
+		if DevelopmentMode() {
+			if snippet := sourceSnippet(source, frame.Line); snippet != nil {
+				writeSnippet(buf, snippet, "    ", p.paint)
+			}
+		}
+	}
+
+	if len(frame.Fields) > 0 {
+		p.writeFields(buf, frame.Fields)
+	}
+}
+
+// writeFields renders the given fields as a table, with keys sorted and padded so that all of the
+// values line up with each other.
+func (p *Printer) writeFields(buf *bytes.Buffer, fields map[string]interface{}) {
+	keys := make([]string, 0, len(fields))
+	width := 0
+
+	for k := range fields {
+		keys = append(keys, k)
+
+		if len(k) > width {
+			width = len(k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	// Continuation lines of multi-line values are indented so that they line up with the first.
+	indent := strings.Repeat(" ", 4+width+3)
+
+	for _, k := range keys {
+		buf.WriteString("    ")
+		buf.WriteString(p.paint(ansiBlue, k))
+		buf.WriteString(strings.Repeat(" ", width-len(k)))
+		buf.WriteString(" = ")
+		buf.WriteString(strings.Replace(prettyValue(fields[k]), "\n", "\n"+indent, -1))
+		buf.WriteString("\n")
+	}
+}
+
+// paint wraps the given string in the given ANSI codes, if colour is enabled.
+func (p *Printer) paint(codes, s string) string {
+	if !p.Color {
+		return s
+	}
+
+	return codes + s + ansiReset
+}
+
+// prettyValue formats a field value for display. Values that have their own string representation
+// are used as-is, and composite values (structs, maps, slices) are pretty-printed as JSON.
+func prettyValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "<nil>"
+	case string:
+		return val
+	case error:
+		return safeString(v, val.Error)
+	case fmt.Stringer:
+		return safeString(v, val.String)
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		bs, err := json.MarshalIndent(v, "", "  ")
+		if err == nil {
+			return string(bs)
+		}
+	}
+
+	return fmt.Sprintf("%+v", v)
+}
+
+// safeString calls the given method of the given value to get its string representation, the same
+// way fmt does, showing nil pointers as "<nil>" rather than panicking if the method doesn't expect a
+// nil receiver.
+func safeString(v interface{}, fn func() string) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+				s = "<nil>"
+				return
+			}
+
+			s = fmt.Sprintf("%%!v(PANIC=%v)", r)
+		}
+	}()
+
+	return fn()
+}
+
+// supportsColor reports whether colour output should be used when writing to w. Colour is only
+// used when w is a terminal, and the user hasn't opted out of it.
+func supportsColor(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
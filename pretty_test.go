@@ -0,0 +1,163 @@
+package errors
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrinter_Print(t *testing.T) {
+	t.Run("should render every frame in the stack", func(t *testing.T) {
+		buf := bytes.Buffer{}
+
+		err := Wrap(New(Kind("inner kind"), "inner"), Kind("outer kind"), "outer")
+		require.NoError(t, NewPrinter(&buf).Print(err))
+
+		out := buf.String()
+		assert.Contains(t, out, "Error: [go-errors.TestPrinter_Print.func1]: outer (outer kind)")
+		assert.Contains(t, out, "Caused by: [go-errors.TestPrinter_Print.func1]: inner (inner kind)")
+		assert.Contains(t, out, "pretty_test.go:")
+	})
+
+	t.Run("should render standard errors at the end of the stack", func(t *testing.T) {
+		buf := bytes.Buffer{}
+
+		err := Wrap(errors.New("standard error"), "oops")
+		require.NoError(t, NewPrinter(&buf).Print(err))
+
+		assert.Contains(t, buf.String(), "Caused by: standard error\n")
+	})
+
+	t.Run("should align field values", func(t *testing.T) {
+		buf := bytes.Buffer{}
+
+		err := New("oops").WithFields("a", 1, "longer", 2)
+		require.NoError(t, NewPrinter(&buf).Print(err))
+
+		assert.Contains(t, buf.String(), "    a      = 1\n")
+		assert.Contains(t, buf.String(), "    longer = 2\n")
+	})
+
+	t.Run("should pretty-print composite field values as JSON", func(t *testing.T) {
+		buf := bytes.Buffer{}
+
+		type user struct {
+			Name string `json:"name"`
+		}
+
+		err := New("oops").WithField("user", user{Name: "Laureen"})
+		require.NoError(t, NewPrinter(&buf).Print(err))
+
+		assert.Contains(t, buf.String(), "    user = {\n             \"name\": \"Laureen\"\n           }\n")
+	})
+
+	t.Run("should not use colour when writing to a buffer", func(t *testing.T) {
+		buf := bytes.Buffer{}
+
+		require.NoError(t, NewPrinter(&buf).Print(New(Kind("testing"), "oops")))
+		assert.NotContains(t, buf.String(), "\x1b[")
+	})
+
+	t.Run("should use colour if it is enabled", func(t *testing.T) {
+		buf := bytes.Buffer{}
+
+		p := NewPrinter(&buf)
+		p.Color = true
+
+		require.NoError(t, p.Print(New(Kind("testing"), "oops")))
+		assert.Contains(t, buf.String(), ansiYellow+"(testing)"+ansiReset)
+	})
+}
+
+func TestFprint(t *testing.T) {
+	t.Run("should write nothing for a nil error", func(t *testing.T) {
+		buf := bytes.Buffer{}
+
+		require.NoError(t, Fprint(&buf, nil))
+		assert.Equal(t, 0, buf.Len())
+	})
+}
+
+func TestSupportsColor(t *testing.T) {
+	t.Run("should not support colour for non-file writers", func(t *testing.T) {
+		assert.False(t, supportsColor(&bytes.Buffer{}))
+	})
+
+	t.Run("should not support colour for regular files", func(t *testing.T) {
+		f, err := os.Open("pretty.go")
+		require.NoError(t, err)
+		defer f.Close()
+
+		assert.False(t, supportsColor(f))
+	})
+
+	t.Run("should respect NO_COLOR", func(t *testing.T) {
+		old, ok := os.LookupEnv("NO_COLOR")
+		defer func() {
+			if ok {
+				os.Setenv("NO_COLOR", old)
+			} else {
+				os.Unsetenv("NO_COLOR")
+			}
+		}()
+
+		os.Setenv("NO_COLOR", "1")
+		assert.False(t, supportsColor(os.Stdout))
+	})
+}
+
+func TestPrettyValue(t *testing.T) {
+	t.Run("should leave strings as they are", func(t *testing.T) {
+		assert.Equal(t, "hello", prettyValue("hello"))
+	})
+
+	t.Run("should format scalars", func(t *testing.T) {
+		assert.Equal(t, "123", prettyValue(123))
+	})
+
+	t.Run("should use an error's message", func(t *testing.T) {
+		assert.Equal(t, "oops", prettyValue(errors.New("oops")))
+	})
+
+	t.Run("should format maps as JSON", func(t *testing.T) {
+		val := prettyValue(map[string]int{"a": 1})
+		assert.True(t, strings.HasPrefix(val, "{\n"))
+		assert.Contains(t, val, "\"a\": 1")
+	})
+
+	t.Run("should show nil pointers as nil", func(t *testing.T) {
+		assert.Equal(t, "<nil>", prettyValue((*prettyError)(nil)))
+		assert.Equal(t, "<nil>", prettyValue((*prettyStringer)(nil)))
+	})
+
+	t.Run("should not panic if a value can't be formatted", func(t *testing.T) {
+		assert.Equal(t, "%!v(PANIC=oops)", prettyValue(&prettyStringer{panics: true}))
+	})
+}
+
+// prettyError is an error that panics if it's nil.
+type prettyError struct {
+	message string
+}
+
+func (e *prettyError) Error() string {
+	return e.message
+}
+
+// prettyStringer is a fmt.Stringer that panics if it's nil, or if asked to.
+type prettyStringer struct {
+	panics bool
+}
+
+func (s *prettyStringer) String() string {
+	if s.panics {
+		panic("oops")
+	}
+
+	return "stringer"
+}
@@ -0,0 +1,147 @@
+package errors
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+)
+
+// referenceAlphabet is Crockford's base32 alphabet. It's case-insensitive, and avoids characters
+// that are easily confused with each other (e.g. I, L, O, and U), which matters when a reference ID
+// is being read out to someone over the phone.
+const referenceAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// referenceEpoch is the start of the time used in reference IDs. Using a recent epoch lets us fit
+// a millisecond timestamp into fewer characters.
+var referenceEpoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// referenceFormat is the format used to append reference IDs to user-facing messages.
+var referenceFormat = struct {
+	sync.RWMutex
+	format string
+}{}
+
+// referenceCell holds the reference ID generated for an error. A cell is allocated when the error is
+// created, and filled in the first time the ID is asked for, so that the ID can be generated lazily
+// (possibly from multiple goroutines) without changing the error itself.
+type referenceCell struct {
+	mu sync.Mutex
+	id string
+}
+
+// get returns the ID stored in the cell. If there isn't one yet, and generate is true, a new ID is
+// generated and stored first.
+func (c *referenceCell) get(generate bool) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.id == "" && generate {
+		c.id = newReferenceID(time.Now())
+	}
+
+	return c.id
+}
+
+// SetReferenceFormat sets the format used to append an error's reference ID to the message returned
+// by Message. The format may contain the placeholders "{message}" and "{reference}", e.g.
+// "{message} (reference: {reference})". If the format is empty, which is the default, reference IDs
+// are not added to messages.
+func SetReferenceFormat(format string) {
+	referenceFormat.Lock()
+	referenceFormat.format = format
+	referenceFormat.Unlock()
+}
+
+// ReferenceID returns a short, unique ID for the given error. The ID is generated the first time
+// it's asked for, and every subsequent call for the same error returns the same ID. If an error in
+// the stack was given an ID explicitly (see WithReferenceID), that ID is returned instead, so that
+// it's the same in every log entry for the same failure, even across services. Generated IDs are
+// not reused by errors that wrap the error they were generated for, as the wrapped error may be
+// shared (e.g. a package-level error that's returned in many places).
+//
+// Reference IDs are intended to be shown to users, so that support can link a report from a user
+// to a log entry. They're made up of a millisecond timestamp followed by random characters, so they
+// sort in the order that they were generated. If the given error is not an *Error created by this
+// package, or is nil, an empty string is returned, as there's nowhere to store the ID.
+func ReferenceID(err error) string {
+	return referenceID(err, true)
+}
+
+// referenceID returns the reference ID for the given error, the same as ReferenceID. If generate is
+// false, an ID is only returned if one has already been assigned, so the error isn't changed.
+func referenceID(err error, generate bool) string {
+	top, ok := err.(*Error)
+	if !ok || top == nil {
+		return ""
+	}
+
+	for e := top; e != nil; {
+		if e.reference != "" {
+			return e.reference
+		}
+
+		e, _ = e.Cause.(*Error)
+	}
+
+	if top.generated == nil {
+		return ""
+	}
+
+	return top.generated.get(generate)
+}
+
+// WithReferenceID sets the error's reference ID, replacing any ID that ReferenceID would otherwise
+// return for it. This is useful when an error is received from another service, so that the same ID
+// is used on both sides. Unlike a generated ID, an ID set this way is kept when the error is wrapped.
+func (e *Error) WithReferenceID(id string) *Error {
+	e.reference = id
+	return e
+}
+
+// withReference appends the reference ID of the given error to the given message, if a reference
+// format has been set.
+func withReference(err error, message string) string {
+	referenceFormat.RLock()
+	format := referenceFormat.format
+	referenceFormat.RUnlock()
+
+	if format == "" {
+		return message
+	}
+
+	ref := ReferenceID(err)
+	if ref == "" {
+		return message
+	}
+
+	return strings.NewReplacer("{message}", message, "{reference}", ref).Replace(format)
+}
+
+// newReferenceID generates a new reference ID for the given time. The ID is 14 characters long; 8
+// characters for a 40-bit millisecond timestamp (which will last until 2054), and 6 characters for
+// 30 random bits.
+func newReferenceID(now time.Time) string {
+	ms := uint64(now.Sub(referenceEpoch) / time.Millisecond)
+
+	var rb [4]byte
+	if _, err := rand.Read(rb[:]); err != nil {
+		// If we can't get random bytes, the best we can do is fall back to the clock.
+		binary.BigEndian.PutUint32(rb[:], uint32(now.UnixNano()))
+	}
+
+	id := make([]byte, 14)
+	encodeBase32(id[:8], ms)
+	encodeBase32(id[8:], uint64(binary.BigEndian.Uint32(rb[:])))
+
+	return string(id)
+}
+
+// encodeBase32 fills dst with the lowest 5*len(dst) bits of v, encoded using referenceAlphabet.
+func encodeBase32(dst []byte, v uint64) {
+	for i := len(dst) - 1; i >= 0; i-- {
+		dst[i] = referenceAlphabet[v&0x1f]
+		v >>= 5
+	}
+}
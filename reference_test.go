@@ -0,0 +1,141 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferenceID(t *testing.T) {
+	t.Run("should return an empty ID for a nil error", func(t *testing.T) {
+		assert.Equal(t, "", ReferenceID(nil))
+	})
+
+	t.Run("should return an empty ID for a standard error", func(t *testing.T) {
+		assert.Equal(t, "", ReferenceID(errors.New("oops")))
+	})
+
+	t.Run("should return a short ID", func(t *testing.T) {
+		assert.Regexp(t, "^[0-9A-HJKMNP-TV-Z]{14}$", ReferenceID(New("oops")))
+	})
+
+	t.Run("should return the same ID every time", func(t *testing.T) {
+		err := New("oops")
+		assert.Equal(t, ReferenceID(err), ReferenceID(err))
+	})
+
+	t.Run("should return different IDs for different errors", func(t *testing.T) {
+		assert.NotEqual(t, ReferenceID(New("oops")), ReferenceID(New("oops")))
+	})
+
+	t.Run("should not reuse an ID generated for a wrapped error", func(t *testing.T) {
+		shared := New("oops")
+		ref := ReferenceID(shared)
+
+		wrapped1 := Wrap(shared, "wrapped")
+		wrapped2 := Wrap(shared, "wrapped")
+
+		assert.NotEqual(t, ref, ReferenceID(wrapped1))
+		assert.NotEqual(t, ReferenceID(wrapped1), ReferenceID(wrapped2))
+		assert.Equal(t, ref, ReferenceID(shared))
+	})
+
+	t.Run("should be safe to call whilst the error is being wrapped", func(t *testing.T) {
+		shared := New("oops")
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			for i := 0; i < 100; i++ {
+				ReferenceID(shared)
+			}
+		}()
+
+		for i := 0; i < 100; i++ {
+			Wrap(shared, "wrapped")
+		}
+
+		<-done
+	})
+
+	t.Run("should return an empty ID for an error not created by this package", func(t *testing.T) {
+		assert.Equal(t, "", ReferenceID(&Error{Message: "oops"}))
+	})
+
+	t.Run("should be included on the first frame of the stack", func(t *testing.T) {
+		err := Wrap(New("oops"), "wrapped")
+		ReferenceID(err)
+
+		stack := Stack(err)
+
+		require.Len(t, stack, 2)
+		assert.Equal(t, ReferenceID(err), stack[0].ReferenceID)
+		assert.Empty(t, stack[1].ReferenceID)
+
+		bs, jerr := json.Marshal(stack[0])
+		require.NoError(t, jerr)
+		assert.Contains(t, string(bs), `"reference_id":"`+ReferenceID(err)+`"`)
+	})
+
+	t.Run("should not be assigned by Stack", func(t *testing.T) {
+		err := Wrap(New("oops"), "wrapped")
+
+		assert.Empty(t, Stack(err)[0].ReferenceID)
+		assert.Empty(t, referenceID(err, false))
+	})
+}
+
+func TestError_WithReferenceID(t *testing.T) {
+	t.Run("should set the reference ID", func(t *testing.T) {
+		err := New("oops").WithReferenceID("0123456789ABCD")
+		assert.Equal(t, "0123456789ABCD", ReferenceID(err))
+	})
+
+	t.Run("should replace an existing reference ID", func(t *testing.T) {
+		err := New("oops")
+		ReferenceID(err)
+
+		err.WithReferenceID("0123456789ABCD")
+		assert.Equal(t, "0123456789ABCD", ReferenceID(err))
+	})
+
+	t.Run("should be reused when wrapped", func(t *testing.T) {
+		err := Wrap(New("oops").WithReferenceID("0123456789ABCD"), "wrapped")
+		assert.Equal(t, "0123456789ABCD", ReferenceID(err))
+	})
+}
+
+func TestSetReferenceFormat(t *testing.T) {
+	defer SetReferenceFormat("")
+
+	t.Run("should not add reference IDs to messages by default", func(t *testing.T) {
+		assert.Equal(t, "oops", Message(New("oops")))
+	})
+
+	t.Run("should add reference IDs to messages if a format is set", func(t *testing.T) {
+		SetReferenceFormat("{message} (reference: {reference})")
+		defer SetReferenceFormat("")
+
+		err := Wrap(New("oops"))
+		assert.Equal(t, "oops (reference: "+ReferenceID(err)+")", Message(err))
+	})
+}
+
+func TestNewReferenceID(t *testing.T) {
+	t.Run("should sort in the order IDs were generated", func(t *testing.T) {
+		now := time.Now()
+
+		var ids []string
+		for i := 0; i < 10; i++ {
+			ids = append(ids, newReferenceID(now.Add(time.Duration(i)*time.Millisecond)))
+		}
+
+		assert.True(t, sort.StringsAreSorted(ids))
+	})
+}
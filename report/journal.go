@@ -0,0 +1,212 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/icelolly/go-errors"
+)
+
+// Default values used for any Journal fields that are not set.
+const (
+	DefaultJournalMaxSize  = 10 << 20
+	DefaultJournalMaxFiles = 5
+)
+
+// maxJournalLine is the longest line that ReadEntries will read.
+const maxJournalLine = 4 << 20
+
+// Journal is a Sink that appends each entry to a local file as a line of JSON (i.e. NDJSON). When
+// the file reaches MaxSize, it's rotated; the current file is renamed with a ".1" suffix, any
+// existing rotated files have their suffix incremented, and the oldest are removed, so that at most
+// MaxFiles rotated files are kept. The files can be read using ReadEntries, or the errq command.
+// It's safe for concurrent use, but only one Journal should write to each path.
+type Journal struct {
+	// Path is the path of the file that entries are written to, e.g. "/var/log/app/errors.ndjson".
+	Path string
+
+	// MaxSize is the size in bytes that the file may grow to before it's rotated. If it's zero,
+	// DefaultJournalMaxSize is used. A batch that doesn't fit is written to a new file, unless the
+	// current file is empty.
+	MaxSize int64
+
+	// MaxFiles is the number of rotated files that are kept. If it's zero, DefaultJournalMaxFiles is
+	// used. If it's negative, no rotated files are kept.
+	MaxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Send appends each entry in the given batch to the journal's file, rotating it first if needed.
+// The whole batch is written at once.
+func (j *Journal) Send(ctx context.Context, batch []Entry) error {
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+
+	for _, entry := range batch {
+		if err := enc.Encode(entry); err != nil {
+			return errors.Wrap(err, "report: failed to encode entry").
+				WithField("fingerprint", entry.Fingerprint)
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		if err := j.open(); err != nil {
+			return err
+		}
+	}
+
+	if j.size > 0 && j.size+int64(buf.Len()) > j.maxSize() {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := buf.WriteTo(j.file)
+	j.size += n
+
+	if err != nil {
+		return errors.Wrap(err, "report: failed to write to journal").WithField("path", j.Path)
+	}
+
+	return nil
+}
+
+// Close closes the journal's file. The file is opened again if more entries are sent.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+
+	err := j.file.Close()
+	j.file = nil
+
+	if err != nil {
+		return errors.Wrap(err, "report: failed to close journal").WithField("path", j.Path)
+	}
+
+	return nil
+}
+
+// open opens the journal's file for appending, creating it if it doesn't exist. The lock must be
+// held.
+func (j *Journal) open() error {
+	file, err := os.OpenFile(j.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrap(err, "report: failed to open journal").WithField("path", j.Path)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, "report: failed to open journal").WithField("path", j.Path)
+	}
+
+	j.file = file
+	j.size = info.Size()
+
+	return nil
+}
+
+// rotate closes the journal's file, shifts the rotated files along, removing the oldest, and opens
+// a new file. The lock must be held.
+func (j *Journal) rotate() error {
+	if err := j.file.Close(); err != nil {
+		return errors.Wrap(err, "report: failed to close journal").WithField("path", j.Path)
+	}
+
+	j.file = nil
+
+	maxFiles := j.MaxFiles
+	if maxFiles == 0 {
+		maxFiles = DefaultJournalMaxFiles
+	}
+
+	if maxFiles < 0 {
+		maxFiles = 0
+	}
+
+	// The oldest file that's kept is moved past the end, and removed, making room for the others to
+	// be shifted along. Files left over from a larger MaxFiles aren't touched.
+	oldest := rotatedPath(j.Path, maxFiles+1)
+	if err := os.Rename(rotatedPath(j.Path, maxFiles), oldest); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "report: failed to rotate journal").WithField("path", j.Path)
+	}
+
+	if err := os.Remove(oldest); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "report: failed to rotate journal").WithField("path", j.Path)
+	}
+
+	for i := maxFiles - 1; i >= 0; i-- {
+		err := os.Rename(rotatedPath(j.Path, i), rotatedPath(j.Path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "report: failed to rotate journal").WithField("path", j.Path)
+		}
+	}
+
+	return j.open()
+}
+
+// maxSize returns the size the journal's file may grow to before it's rotated.
+func (j *Journal) maxSize() int64 {
+	if j.MaxSize <= 0 {
+		return DefaultJournalMaxSize
+	}
+
+	return j.MaxSize
+}
+
+// rotatedPath returns the path of the rotated file with the given number, where 0 is the current
+// file.
+func rotatedPath(path string, n int) string {
+	if n == 0 {
+		return path
+	}
+
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// ReadEntries reads entries written as lines of JSON, e.g. by a Journal or WriterSink, calling fn
+// with each one. Reading stops at the end of the input, or as soon as fn returns an error, which is
+// returned. Lines that aren't valid entries are returned as errors, with the line number set as
+// the "line" field. Entries that have been read don't have Err set.
+func ReadEntries(r io.Reader, fn func(entry Entry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxJournalLine)
+
+	for line := 1; scanner.Scan(); line++ {
+		bs := bytes.TrimSpace(scanner.Bytes())
+		if len(bs) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(bs, &entry); err != nil {
+			return errors.Wrap(err, "report: invalid entry").WithField("line", line)
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "report: failed to read entries")
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package report
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tempDir returns a temporary directory. It must be removed by the caller.
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "report-test")
+	require.NoError(t, err)
+
+	return dir
+}
+
+// readJournal returns the messages of the entries in the given file.
+func readJournal(t *testing.T, path string) []string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	var messages []string
+	require.NoError(t, ReadEntries(f, func(entry Entry) error {
+		messages = append(messages, entry.Message)
+		return nil
+	}))
+
+	return messages
+}
+
+func TestJournal_Send(t *testing.T) {
+	t.Run("should append entries as lines of JSON", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "errors.ndjson")
+		journal := &Journal{Path: path}
+
+		err := errors.New(kinds.NotFound, "not found").WithField("id", 42)
+
+		entry := newEntry(context.Background(), err, time.Time{})
+		require.NoError(t, journal.Send(context.Background(), []Entry{entry}))
+		require.NoError(t, journal.Send(context.Background(), []Entry{{Message: "second"}}))
+		require.NoError(t, journal.Close())
+
+		// Reopening the journal should append to the existing file.
+		require.NoError(t, journal.Send(context.Background(), []Entry{{Message: "third"}}))
+		require.NoError(t, journal.Close())
+
+		f, ferr := os.Open(path)
+		require.NoError(t, ferr)
+
+		defer f.Close()
+
+		var entries []Entry
+		require.NoError(t, ReadEntries(f, func(entry Entry) error {
+			entries = append(entries, entry)
+			return nil
+		}))
+
+		require.Len(t, entries, 3)
+
+		assert.Equal(t, string(kinds.NotFound), entries[0].Kind)
+		assert.Equal(t, errors.Fingerprint(err), entries[0].Fingerprint)
+		assert.Equal(t, float64(42), entries[0].Fields["id"])
+		assert.Equal(t, errors.FormatStack(errors.Stack(err)), errors.FormatStack(entries[0].Stack))
+		assert.Nil(t, entries[0].Err)
+
+		assert.Equal(t, "second", entries[1].Message)
+		assert.Equal(t, "third", entries[2].Message)
+	})
+
+	t.Run("should rotate files once they reach their maximum size", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "errors.ndjson")
+		journal := &Journal{Path: path, MaxSize: 1, MaxFiles: 2}
+
+		defer journal.Close()
+
+		for _, msg := range []string{"1", "2", "3", "4"} {
+			require.NoError(t, journal.Send(context.Background(), []Entry{{Message: msg}}))
+		}
+
+		assert.Equal(t, []string{"4"}, readJournal(t, path))
+		assert.Equal(t, []string{"3"}, readJournal(t, path+".1"))
+		assert.Equal(t, []string{"2"}, readJournal(t, path+".2"))
+
+		_, err := os.Stat(path + ".3")
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("should not keep rotated files if asked not to", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		journal := &Journal{Path: filepath.Join(dir, "errors.ndjson"), MaxSize: 1, MaxFiles: -1}
+
+		defer journal.Close()
+
+		for _, msg := range []string{"1", "2", "3"} {
+			require.NoError(t, journal.Send(context.Background(), []Entry{{Message: msg}}))
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+
+		require.Len(t, files, 1)
+		assert.Equal(t, []string{"3"}, readJournal(t, journal.Path))
+	})
+
+	t.Run("should return an error if the file can't be opened", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		journal := &Journal{Path: filepath.Join(dir, "missing", "errors.ndjson")}
+
+		err := journal.Send(context.Background(), []Entry{{Message: "oops"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestReadEntries(t *testing.T) {
+	t.Run("should skip blank lines", func(t *testing.T) {
+		var messages []string
+		err := ReadEntries(strings.NewReader("{\"message\":\"a\"}\n\n{\"message\":\"b\"}"), func(entry Entry) error {
+			messages = append(messages, entry.Message)
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, messages)
+	})
+
+	t.Run("should return an error for invalid lines", func(t *testing.T) {
+		err := ReadEntries(strings.NewReader("{}\nnope\n"), func(entry Entry) error {
+			return nil
+		})
+
+		require.Error(t, err)
+		assert.Equal(t, 2, errors.Fields(err)["line"])
+	})
+
+	t.Run("should stop when fn returns an error", func(t *testing.T) {
+		stop := errors.New("stop")
+
+		var calls int
+		err := ReadEntries(strings.NewReader("{}\n{}\n"), func(entry Entry) error {
+			calls++
+			return stop
+		})
+
+		assert.Equal(t, stop, err)
+		assert.Equal(t, 1, calls)
+	})
+}
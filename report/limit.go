@@ -0,0 +1,87 @@
+package report
+
+import (
+	"time"
+)
+
+// decision is what limiter.allow decides to do with an error.
+type decision int
+
+const (
+	allowed decision = iota
+	sampled
+	rateLimited
+)
+
+// limiter samples and rate limits errors by their fingerprint, using a fixed window for each
+// fingerprint. It's only used by the worker, so it isn't safe for concurrent use.
+type limiter struct {
+	limit    int
+	interval time.Duration
+	windows  map[string]*window
+}
+
+// window is the state of a single fingerprint's current window.
+type window struct {
+	start      time.Time
+	sent       int
+	suppressed int
+}
+
+// newLimiter returns a limiter that allows up to limit errors with the same fingerprint in each
+// interval. If limit is zero, there is no limit.
+func newLimiter(limit int, interval time.Duration) *limiter {
+	return &limiter{
+		limit:    limit,
+		interval: interval,
+		windows:  make(map[string]*window),
+	}
+}
+
+// allow decides whether an error with the given fingerprint, that occurred at the given time, should
+// be sent. The first error in each window is always sent. After that, errors are rate limited, and
+// then sampled using the given function. If the error is allowed, the number of errors with the
+// same fingerprint that weren't sent since the last one that was is returned too.
+func (l *limiter) allow(fingerprint string, now time.Time, sample func() bool) (int, decision) {
+	w, ok := l.windows[fingerprint]
+	if !ok {
+		w = &window{start: now}
+		l.windows[fingerprint] = w
+	} else if now.Sub(w.start) >= l.interval {
+		w.start = now
+		w.sent = 0
+	}
+
+	if w.sent > 0 {
+		if l.limit > 0 && w.sent >= l.limit {
+			w.suppressed++
+			return 0, rateLimited
+		}
+
+		if !sample() {
+			w.suppressed++
+			return 0, sampled
+		}
+	}
+
+	suppressed := w.suppressed
+
+	w.sent++
+	w.suppressed = 0
+
+	return suppressed, allowed
+}
+
+// prune forgets the windows that ended before the given time, so that fingerprints that are no
+// longer seen don't use memory. Windows with suppressed errors are kept for one more interval, so
+// that the count can still be added to the next entry if the error is seen again soon, and are then
+// forgotten too, along with the count (it's still included in the reporter's stats).
+func (l *limiter) prune(now time.Time) {
+	for fingerprint, w := range l.windows {
+		age := now.Sub(w.start)
+
+		if age >= 2*l.interval || (w.suppressed == 0 && age >= l.interval) {
+			delete(l.windows, fingerprint)
+		}
+	}
+}
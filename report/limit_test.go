@@ -0,0 +1,80 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	always := func() bool { return true }
+	never := func() bool { return false }
+
+	t.Run("should rate limit errors by fingerprint", func(t *testing.T) {
+		l := newLimiter(2, time.Minute)
+
+		for i := 0; i < 2; i++ {
+			_, d := l.allow("a", start, always)
+			assert.Equal(t, allowed, d)
+		}
+
+		_, d := l.allow("a", start, always)
+		assert.Equal(t, rateLimited, d)
+
+		_, d = l.allow("b", start, always)
+		assert.Equal(t, allowed, d)
+	})
+
+	t.Run("should sample errors after the first in each window", func(t *testing.T) {
+		l := newLimiter(0, time.Minute)
+
+		_, d := l.allow("a", start, never)
+		assert.Equal(t, allowed, d)
+
+		_, d = l.allow("a", start, never)
+		assert.Equal(t, sampled, d)
+
+		_, d = l.allow("a", start.Add(time.Minute), never)
+		assert.Equal(t, allowed, d)
+	})
+
+	t.Run("should return the number of suppressed errors when one is allowed", func(t *testing.T) {
+		l := newLimiter(1, time.Minute)
+
+		l.allow("a", start, always)
+		l.allow("a", start, always)
+		l.allow("a", start.Add(time.Second), always)
+
+		suppressed, d := l.allow("a", start.Add(time.Minute), always)
+		assert.Equal(t, allowed, d)
+		assert.Equal(t, 2, suppressed)
+
+		suppressed, _ = l.allow("a", start.Add(2*time.Minute), always)
+		assert.Equal(t, 0, suppressed)
+	})
+}
+
+func TestLimiter_Prune(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	always := func() bool { return true }
+
+	l := newLimiter(1, time.Minute)
+
+	l.allow("a", start, always)
+	l.allow("b", start, always)
+	l.allow("b", start, always)
+	l.allow("c", start.Add(30*time.Second), always)
+
+	l.prune(start.Add(time.Minute))
+
+	assert.NotContains(t, l.windows, "a")
+	assert.Contains(t, l.windows, "b")
+	assert.Contains(t, l.windows, "c")
+
+	l.prune(start.Add(2 * time.Minute))
+
+	assert.NotContains(t, l.windows, "b")
+	assert.NotContains(t, l.windows, "c")
+}
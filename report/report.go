@@ -0,0 +1,501 @@
+// Package report sends errors to other places, e.g. logs, files, or webhooks, in the background.
+// Report turns errors into entries straight away, on the caller's goroutine, and queues them. A
+// single worker goroutine then samples and rate limits them by fingerprint (see errors.Fingerprint),
+// and sends them to each sink in batches.
+//
+// The queue is bounded. When it's full, Report either drops the error straight away, or waits for
+// room (see Options.Block), so a slow sink can never use an unbounded amount of memory, and never
+// slows down the code reporting errors unless it's been asked to.
+//
+// Example usage:
+//
+//    reporter := report.NewReporter(report.Options{
+//        Sinks: []report.Sink{
+//            &report.WriterSink{Writer: os.Stderr},
+//            &report.Webhook{URL: "https://alerts.example.com/errors"},
+//        },
+//        RateLimit: 10,
+//    })
+//
+//    defer reporter.Shutdown(context.Background())
+//
+//    if err := doSomething(ctx); err != nil {
+//        reporter.Report(ctx, err)
+//    }
+//
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+)
+
+// Default values used for any Options fields that are not set.
+const (
+	DefaultQueueSize     = 1024
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = time.Second
+	DefaultRateInterval  = time.Minute
+	DefaultSendTimeout   = 10 * time.Second
+)
+
+// Kinds of errors returned by Report when an error isn't queued.
+var (
+	ErrQueueFull = kinds.Derive(kinds.ResourceExhausted, "report: queue is full")
+	ErrClosed    = kinds.Derive(kinds.FailedPrecondition, "report: reporter is shut down")
+)
+
+// Options configures a Reporter.
+type Options struct {
+	// Sinks are where entries are sent. Each batch is sent to every sink concurrently.
+	Sinks []Sink
+
+	// QueueSize is the number of errors that can be waiting to be sent. If it's zero,
+	// DefaultQueueSize is used.
+	QueueSize int
+
+	// BatchSize is the maximum number of entries sent to the sinks at once. If it's zero,
+	// DefaultBatchSize is used.
+	BatchSize int
+
+	// FlushInterval is how often entries are sent if a batch hasn't filled up in the meantime. If
+	// it's zero, DefaultFlushInterval is used.
+	FlushInterval time.Duration
+
+	// Block makes Report wait for room in the queue when it's full, until its context is done,
+	// rather than dropping the error.
+	Block bool
+
+	// SampleRate is the fraction of errors with the same fingerprint that are sent, between 0 and 1,
+	// after the first one in each RateInterval, which is always sent. If it's zero, every error is
+	// sent.
+	SampleRate float64
+
+	// RateLimit is the maximum number of errors with the same fingerprint that are sent in each
+	// RateInterval. If it's zero, there is no limit. The number of errors that aren't sent, due to
+	// either sampling or rate limiting, is added to the next entry with the same fingerprint that is
+	// sent (see Entry.Suppressed).
+	RateLimit    int
+	RateInterval time.Duration
+
+	// SendTimeout is how long each sink has to send a batch. If it's zero, DefaultSendTimeout is
+	// used.
+	SendTimeout time.Duration
+
+	// OnError is called when a sink fails to send a batch. If it's nil, the error is logged using
+	// the standard logger.
+	OnError func(err error)
+}
+
+// withDefaults returns a copy of these options with defaults set for any fields that aren't set.
+func (o Options) withDefaults() Options {
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultQueueSize
+	}
+
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = DefaultFlushInterval
+	}
+
+	if o.SampleRate <= 0 || o.SampleRate > 1 {
+		o.SampleRate = 1
+	}
+
+	if o.RateInterval <= 0 {
+		o.RateInterval = DefaultRateInterval
+	}
+
+	if o.SendTimeout <= 0 {
+		o.SendTimeout = DefaultSendTimeout
+	}
+
+	if o.OnError == nil {
+		o.OnError = func(err error) {
+			log.Printf("%v", err)
+		}
+	}
+
+	return o
+}
+
+// Entry is a single reported error, as it's sent to sinks.
+type Entry struct {
+	Time        time.Time              `json:"time"`
+	Fingerprint string                 `json:"fingerprint"`
+	ReferenceID string                 `json:"reference_id,omitempty"`
+	Kind        string                 `json:"kind,omitempty"`
+	Message     string                 `json:"message"`
+	TraceID     string                 `json:"trace_id,omitempty"`
+	SpanID      string                 `json:"span_id,omitempty"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+	Stack       []errors.StackFrame    `json:"stack"`
+
+	// Suppressed is the number of errors with the same fingerprint that weren't sent, due to sampling
+	// or rate limiting, since the last one that was.
+	Suppressed int `json:"suppressed,omitempty"`
+
+	// Err is the error that was reported.
+	Err error `json:"-"`
+}
+
+// Stats are counts of what a Reporter has done with the errors reported to it.
+type Stats struct {
+	// Queued is the number of errors that were added to the queue.
+	Queued uint64
+
+	// Dropped is the number of errors that weren't added to the queue, because it was full, or the
+	// reporter was shut down.
+	Dropped uint64
+
+	// Sampled and RateLimited are the number of queued errors that weren't sent due to sampling and
+	// rate limiting respectively.
+	Sampled     uint64
+	RateLimited uint64
+
+	// Sent is the number of entries that at least one sink accepted. Entries sent to several sinks
+	// are counted once.
+	Sent uint64
+
+	// Failed is the number of times a sink failed to send a batch.
+	Failed uint64
+}
+
+// Reporter sends errors to sinks in the background. It must be created using NewReporter, and
+// should be shut down using Shutdown, so that queued errors aren't lost. It's safe for concurrent
+// use.
+type Reporter struct {
+	// stats is first so that it's 64-bit aligned on 32-bit platforms, as it's accessed atomically.
+	stats Stats
+
+	opts    Options
+	queue   chan Entry
+	flushes chan chan struct{}
+	limiter *limiter
+	rand    *rand.Rand
+
+	// mu is held for reading whilst errors are being added to the queue, so that Shutdown can wait
+	// for every error that might be added to actually be added, before the queue is drained.
+	mu      sync.RWMutex
+	closed  bool
+	closing chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewReporter returns a new Reporter, configured using the given options, and starts its worker.
+func NewReporter(opts Options) *Reporter {
+	opts = opts.withDefaults()
+
+	r := &Reporter{
+		opts:    opts,
+		queue:   make(chan Entry, opts.QueueSize),
+		flushes: make(chan chan struct{}),
+		limiter: newLimiter(opts.RateLimit, opts.RateInterval),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		closing: make(chan struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go r.run()
+
+	return r
+}
+
+// Report adds an entry for the given error to the queue, to be sent in the background. Fields and
+// trace context stored in the given context are added to the entry (see errors.WithContextFields,
+// and errors.TraceFromContext). The entry is made before Report returns, on the caller's goroutine,
+// so the error is given its reference ID here (see errors.ReferenceID), and the ID that's sent is
+// the same as the one shown to users.
+//
+// If the queue is full, an error of the ErrQueueFull kind is returned, unless Options.Block is set,
+// in which case Report waits for room until the context is done. If the reporter has been shut
+// down, an error of the ErrClosed kind is returned. Nil errors are ignored.
+func (r *Reporter) Report(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	entry := newEntry(ctx, err, time.Now())
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		atomic.AddUint64(&r.stats.Dropped, 1)
+		return errors.New(ErrClosed)
+	}
+
+	if !r.opts.Block {
+		select {
+		case r.queue <- entry:
+			atomic.AddUint64(&r.stats.Queued, 1)
+			return nil
+		default:
+			atomic.AddUint64(&r.stats.Dropped, 1)
+			return errors.New(ErrQueueFull)
+		}
+	}
+
+	select {
+	case r.queue <- entry:
+		atomic.AddUint64(&r.stats.Queued, 1)
+		return nil
+	case <-r.closing:
+		atomic.AddUint64(&r.stats.Dropped, 1)
+		return errors.New(ErrClosed)
+	case <-ctx.Done():
+		atomic.AddUint64(&r.stats.Dropped, 1)
+		return errors.WrapClassified(ctx.Err(), ErrQueueFull)
+	}
+}
+
+// Flush sends every error that has been queued so far, waiting until the sinks have finished
+// sending them, or the given context is done.
+func (r *Reporter) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+
+	select {
+	case r.flushes <- done:
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return errors.WrapClassified(ctx.Err(), "report: flush interrupted")
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.WrapClassified(ctx.Err(), "report: flush interrupted")
+	}
+}
+
+// Shutdown stops the reporter accepting errors, sends every error that has already been queued,
+// and stops the worker. It waits until that's done, or the given context is done, in which case the
+// remaining errors are still sent in the background. Calling Shutdown more than once is safe.
+func (r *Reporter) Shutdown(ctx context.Context) error {
+	r.once.Do(func() {
+		// Reports waiting for room in the queue give up once closing is closed, and once the lock
+		// is held, nothing else can be added to the queue, so the worker can safely drain it.
+		close(r.closing)
+
+		r.mu.Lock()
+		r.closed = true
+		r.mu.Unlock()
+
+		close(r.stop)
+	})
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return errors.WrapClassified(ctx.Err(), "report: shutdown interrupted")
+	}
+}
+
+// Stats returns counts of what the reporter has done with the errors reported to it so far.
+func (r *Reporter) Stats() Stats {
+	return Stats{
+		Queued:      atomic.LoadUint64(&r.stats.Queued),
+		Dropped:     atomic.LoadUint64(&r.stats.Dropped),
+		Sampled:     atomic.LoadUint64(&r.stats.Sampled),
+		RateLimited: atomic.LoadUint64(&r.stats.RateLimited),
+		Sent:        atomic.LoadUint64(&r.stats.Sent),
+		Failed:      atomic.LoadUint64(&r.stats.Failed),
+	}
+}
+
+// run is the worker, which batches queued errors and sends them to the sinks, until the reporter is
+// shut down.
+func (r *Reporter) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Entry, 0, r.opts.BatchSize)
+
+	for {
+		select {
+		case entry := <-r.queue:
+			batch = r.add(batch, entry)
+		case <-ticker.C:
+			batch = r.send(batch)
+			r.limiter.prune(time.Now())
+		case done := <-r.flushes:
+			batch = r.send(r.drain(batch))
+			close(done)
+		case <-r.stop:
+			r.send(r.drain(batch))
+			return
+		}
+	}
+}
+
+// drain adds every error that's currently in the queue to the given batch, sending it whenever it
+// fills up.
+func (r *Reporter) drain(batch []Entry) []Entry {
+	for {
+		select {
+		case entry := <-r.queue:
+			batch = r.add(batch, entry)
+		default:
+			return batch
+		}
+	}
+}
+
+// add adds the given queued entry to the given batch, unless it's sampled out or rate limited,
+// sending the batch if it's full.
+func (r *Reporter) add(batch []Entry, entry Entry) []Entry {
+	suppressed, decision := r.limiter.allow(entry.Fingerprint, entry.Time, func() bool {
+		return r.rand.Float64() < r.opts.SampleRate
+	})
+
+	switch decision {
+	case sampled:
+		atomic.AddUint64(&r.stats.Sampled, 1)
+		return batch
+	case rateLimited:
+		atomic.AddUint64(&r.stats.RateLimited, 1)
+		return batch
+	}
+
+	entry.Suppressed = suppressed
+	batch = append(batch, entry)
+
+	if len(batch) >= r.opts.BatchSize {
+		return r.send(batch)
+	}
+
+	return batch
+}
+
+// send sends the given batch to every sink concurrently, waiting for them all to finish, and returns
+// an empty batch to fill next.
+func (r *Reporter) send(batch []Entry) []Entry {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	var accepted int32
+
+	var wg sync.WaitGroup
+	for _, sink := range r.opts.Sinks {
+		wg.Add(1)
+
+		go func(sink Sink) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), r.opts.SendTimeout)
+			defer cancel()
+
+			if err := sink.Send(ctx, batch); err != nil {
+				atomic.AddUint64(&r.stats.Failed, 1)
+				r.opts.OnError(errors.Wrap(err, "report: sink failed to send batch").
+					WithField("entries", len(batch)))
+
+				return
+			}
+
+			atomic.StoreInt32(&accepted, 1)
+		}(sink)
+	}
+
+	wg.Wait()
+
+	if atomic.LoadInt32(&accepted) == 1 {
+		atomic.AddUint64(&r.stats.Sent, uint64(len(batch)))
+	}
+
+	// Sinks may hold on to the batch they were given, so a new one is made rather than reusing it.
+	return make([]Entry, 0, r.opts.BatchSize)
+}
+
+// newEntry returns the entry for the given error, reported at the given time with the given
+// context. The error's reference ID is assigned first, so that it's included in the entry's stack.
+func newEntry(ctx context.Context, err error, now time.Time) Entry {
+	entry := Entry{
+		Time:        now,
+		Fingerprint: errors.Fingerprint(err),
+		ReferenceID: errors.ReferenceID(err),
+		Kind:        string(errors.Classify(err)),
+		Message:     err.Error(),
+		Stack:       errors.Stack(err),
+		Err:         err,
+	}
+
+	trace, _ := errors.TraceFromContext(ctx)
+	if tc, ok := errors.TraceOf(err); ok {
+		trace = tc
+	}
+
+	entry.TraceID = trace.TraceIDHex
+	entry.SpanID = trace.SpanIDHex
+
+	// Fields may return the fields of one of the errors in the stack, so it's only changed if it has
+	// been copied first. Fields on the error take precedence over fields from the context.
+	fields := errors.Fields(err)
+	if ctxFields := errors.ContextFields(ctx); len(ctxFields) > 0 {
+		merged := make(map[string]interface{}, len(fields)+len(ctxFields))
+		for k, v := range ctxFields {
+			merged[k] = v
+		}
+
+		for k, v := range fields {
+			merged[k] = v
+		}
+
+		fields = merged
+	}
+
+	entry.Fields = encodableFields(fields)
+
+	for i := range entry.Stack {
+		entry.Stack[i].Fields = encodableFields(entry.Stack[i].Fields)
+	}
+
+	return entry
+}
+
+// encodableFields returns a copy of the given fields, with any values that can't be encoded as JSON
+// replaced by their formatted value, so that one bad field doesn't stop the whole entry being sent.
+// The fields are always copied, as they belong to an error that may still be changed (e.g. using
+// WithField) whilst the entry is being sent.
+func encodableFields(fields map[string]interface{}) map[string]interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	encodable := make(map[string]interface{}, len(fields))
+
+	for k, v := range fields {
+		if _, err := json.Marshal(v); err != nil {
+			v = fmt.Sprintf("%+v", v)
+		}
+
+		encodable[k] = v
+	}
+
+	return encodable
+}
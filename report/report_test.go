@@ -0,0 +1,371 @@
+package report
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collector is a Sink that keeps every batch it's sent.
+type collector struct {
+	mu      sync.Mutex
+	batches [][]Entry
+}
+
+func (c *collector) Send(ctx context.Context, batch []Entry) error {
+	c.mu.Lock()
+	c.batches = append(c.batches, batch)
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *collector) entries() []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var entries []Entry
+	for _, batch := range c.batches {
+		entries = append(entries, batch...)
+	}
+
+	return entries
+}
+
+func (c *collector) sizes() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sizes := make([]int, len(c.batches))
+	for i, batch := range c.batches {
+		sizes[i] = len(batch)
+	}
+
+	return sizes
+}
+
+// blocker is a Sink that blocks until it's released, signalling each time it starts sending.
+type blocker struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlocker() *blocker {
+	return &blocker{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+}
+
+func (b *blocker) Send(ctx context.Context, batch []Entry) error {
+	b.started <- struct{}{}
+	<-b.release
+	return nil
+}
+
+func TestReporter_Report(t *testing.T) {
+	t.Run("should send reported errors to every sink", func(t *testing.T) {
+		c1, c2 := &collector{}, &collector{}
+		r := NewReporter(Options{Sinks: []Sink{c1, c2}})
+
+		tc := errors.TraceContext{
+			TraceIDHex: "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanIDHex:  "00f067aa0ba902b7",
+		}
+
+		ctx := errors.WithContextFields(context.Background(), "request_id", "abc", "id", 1)
+		ctx = errors.ContextWithTrace(ctx, tc)
+
+		err := errors.Wrap(errors.New(kinds.NotFound, "not found").WithField("id", 42), "wrapped")
+
+		require.NoError(t, r.Report(ctx, err))
+		require.NoError(t, r.Report(nil, nil))
+		require.NoError(t, r.Shutdown(context.Background()))
+
+		for _, c := range []*collector{c1, c2} {
+			entries := c.entries()
+			require.Len(t, entries, 1)
+
+			entry := entries[0]
+			assert.Equal(t, err, entry.Err)
+			assert.Equal(t, string(kinds.NotFound), entry.Kind)
+			assert.Equal(t, err.Error(), entry.Message)
+			assert.Equal(t, errors.Fingerprint(err), entry.Fingerprint)
+			assert.Equal(t, errors.ReferenceID(err), entry.ReferenceID)
+			assert.Equal(t, tc.TraceIDHex, entry.TraceID)
+			assert.Equal(t, tc.SpanIDHex, entry.SpanID)
+			assert.Equal(t, map[string]interface{}{"request_id": "abc", "id": 42}, entry.Fields)
+			assert.Len(t, entry.Stack, 2)
+			assert.False(t, entry.Time.IsZero())
+		}
+
+		assert.Equal(t, Stats{Queued: 1, Sent: 1}, r.Stats())
+	})
+
+	t.Run("should assign the reference ID before returning", func(t *testing.T) {
+		b := newBlocker()
+		r := NewReporter(Options{Sinks: []Sink{b}})
+
+		err := errors.New("oops")
+		require.NoError(t, r.Report(context.Background(), err))
+
+		// The worker hasn't sent anything yet, but the ID must already be assigned, so that it's the
+		// same as the one the caller shows to the user.
+		assert.NotEmpty(t, errors.Stack(err)[0].ReferenceID)
+
+		close(b.release)
+		require.NoError(t, r.Shutdown(context.Background()))
+	})
+
+	t.Run("should not share fields with the reported error", func(t *testing.T) {
+		b := newBlocker()
+		c := &collector{}
+		r := NewReporter(Options{Sinks: []Sink{b, c}})
+
+		err := errors.New("oops").WithField("id", 1)
+		require.NoError(t, r.Report(context.Background(), err))
+
+		// The error may still be changed whilst its entry is waiting to be sent.
+		<-b.started
+		err.WithField("late", true)
+
+		close(b.release)
+		require.NoError(t, r.Shutdown(context.Background()))
+
+		entries := c.entries()
+		require.Len(t, entries, 1)
+		assert.Equal(t, map[string]interface{}{"id": 1}, entries[0].Fields)
+		assert.Equal(t, map[string]interface{}{"id": 1}, entries[0].Stack[0].Fields)
+	})
+
+	t.Run("should replace fields that can't be encoded", func(t *testing.T) {
+		c := &collector{}
+		r := NewReporter(Options{Sinks: []Sink{c}})
+
+		ch := make(chan int)
+		fields := map[string]interface{}{"ch": ch, "id": 1}
+
+		require.NoError(t, r.Report(context.Background(), errors.New("oops", fields)))
+		require.NoError(t, r.Shutdown(context.Background()))
+
+		entry := c.entries()[0]
+		assert.IsType(t, "", entry.Fields["ch"])
+		assert.Equal(t, 1, entry.Fields["id"])
+		assert.IsType(t, "", entry.Stack[0].Fields["ch"])
+
+		// The error's own fields must be left alone.
+		assert.Equal(t, ch, fields["ch"])
+	})
+
+	t.Run("should send errors in batches", func(t *testing.T) {
+		c := &collector{}
+		r := NewReporter(Options{Sinks: []Sink{c}, BatchSize: 2, FlushInterval: time.Hour})
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, r.Report(context.Background(), errors.New("oops")))
+		}
+
+		require.NoError(t, r.Flush(context.Background()))
+		assert.Equal(t, []int{2, 2, 1}, c.sizes())
+
+		require.NoError(t, r.Shutdown(context.Background()))
+	})
+
+	t.Run("should send errors every flush interval", func(t *testing.T) {
+		c := &collector{}
+		r := NewReporter(Options{Sinks: []Sink{c}, FlushInterval: 10 * time.Millisecond})
+		defer r.Shutdown(context.Background())
+
+		require.NoError(t, r.Report(context.Background(), errors.New("oops")))
+
+		deadline := time.Now().Add(time.Second)
+		for len(c.entries()) == 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		assert.Len(t, c.entries(), 1)
+	})
+
+	t.Run("should drop errors when the queue is full", func(t *testing.T) {
+		b := newBlocker()
+		r := NewReporter(Options{Sinks: []Sink{b}, QueueSize: 1, BatchSize: 1})
+
+		require.NoError(t, r.Report(context.Background(), errors.New("oops")))
+		<-b.started
+
+		require.NoError(t, r.Report(context.Background(), errors.New("oops")))
+
+		err := r.Report(context.Background(), errors.New("oops"))
+		assert.True(t, errors.Is(err, ErrQueueFull))
+		assert.True(t, errors.Is(err, kinds.ResourceExhausted))
+
+		close(b.release)
+		require.NoError(t, r.Shutdown(context.Background()))
+
+		assert.Equal(t, Stats{Queued: 2, Dropped: 1, Sent: 2}, r.Stats())
+	})
+
+	t.Run("should wait for room in the queue if asked to", func(t *testing.T) {
+		b := newBlocker()
+		r := NewReporter(Options{Sinks: []Sink{b}, QueueSize: 1, BatchSize: 1, Block: true})
+
+		require.NoError(t, r.Report(context.Background(), errors.New("oops")))
+		<-b.started
+
+		require.NoError(t, r.Report(context.Background(), errors.New("oops")))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := r.Report(ctx, errors.New("oops"))
+		assert.True(t, errors.Is(err, ErrQueueFull))
+
+		result := make(chan error)
+		go func() {
+			result <- r.Report(context.Background(), errors.New("oops"))
+		}()
+
+		close(b.release)
+		assert.NoError(t, <-result)
+
+		require.NoError(t, r.Shutdown(context.Background()))
+
+		assert.Equal(t, Stats{Queued: 3, Dropped: 1, Sent: 3}, r.Stats())
+	})
+
+	t.Run("should return an error once shut down", func(t *testing.T) {
+		r := NewReporter(Options{})
+		require.NoError(t, r.Shutdown(context.Background()))
+
+		err := r.Report(context.Background(), errors.New("oops"))
+		assert.True(t, errors.Is(err, ErrClosed))
+	})
+
+	t.Run("should rate limit errors by fingerprint", func(t *testing.T) {
+		c := &collector{}
+		r := NewReporter(Options{Sinks: []Sink{c}, RateLimit: 2})
+
+		report := func(kind errors.Kind) {
+			require.NoError(t, r.Report(context.Background(), errors.New(kind)))
+		}
+
+		for i := 0; i < 5; i++ {
+			report(kinds.NotFound)
+		}
+
+		report(kinds.Internal)
+
+		require.NoError(t, r.Flush(context.Background()))
+		assert.Len(t, c.entries(), 3)
+
+		r.Shutdown(context.Background())
+
+		assert.Equal(t, Stats{Queued: 6, RateLimited: 3, Sent: 3}, r.Stats())
+	})
+
+	t.Run("should sample errors by fingerprint", func(t *testing.T) {
+		c := &collector{}
+		r := NewReporter(Options{Sinks: []Sink{c}, SampleRate: 0.000001})
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, r.Report(context.Background(), errors.New("oops")))
+		}
+
+		require.NoError(t, r.Shutdown(context.Background()))
+
+		assert.Len(t, c.entries(), 1)
+		assert.Equal(t, uint64(4), r.Stats().Sampled)
+	})
+}
+
+func TestReporter_Flush(t *testing.T) {
+	t.Run("should return once shut down", func(t *testing.T) {
+		r := NewReporter(Options{})
+		require.NoError(t, r.Shutdown(context.Background()))
+
+		assert.NoError(t, r.Flush(context.Background()))
+	})
+
+	t.Run("should stop waiting when the context is done", func(t *testing.T) {
+		b := newBlocker()
+		r := NewReporter(Options{Sinks: []Sink{b}})
+
+		require.NoError(t, r.Report(context.Background(), errors.New("oops")))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := r.Flush(ctx)
+		assert.True(t, errors.Is(err, kinds.DeadlineExceeded))
+
+		close(b.release)
+		require.NoError(t, r.Shutdown(context.Background()))
+	})
+}
+
+func TestReporter_Shutdown(t *testing.T) {
+	t.Run("should be safe to call more than once", func(t *testing.T) {
+		r := NewReporter(Options{})
+
+		assert.NoError(t, r.Shutdown(context.Background()))
+		assert.NoError(t, r.Shutdown(context.Background()))
+	})
+
+	t.Run("should stop waiting when the context is done", func(t *testing.T) {
+		b := newBlocker()
+		r := NewReporter(Options{Sinks: []Sink{b}})
+
+		require.NoError(t, r.Report(context.Background(), errors.New("oops")))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := r.Shutdown(ctx)
+		assert.True(t, errors.Is(err, kinds.DeadlineExceeded))
+
+		close(b.release)
+		assert.NoError(t, r.Shutdown(context.Background()))
+	})
+
+	t.Run("should report sink failures", func(t *testing.T) {
+		var failures []error
+		sink := SinkFunc(func(ctx context.Context, batch []Entry) error {
+			return errors.New(kinds.Unavailable, "down")
+		})
+
+		r := NewReporter(Options{
+			Sinks:   []Sink{sink},
+			OnError: func(err error) { failures = append(failures, err) },
+		})
+
+		require.NoError(t, r.Report(context.Background(), errors.New("oops")))
+		require.NoError(t, r.Shutdown(context.Background()))
+
+		require.Len(t, failures, 1)
+		assert.True(t, errors.Is(failures[0], kinds.Unavailable))
+		assert.Equal(t, uint64(1), r.Stats().Failed)
+		assert.Equal(t, uint64(0), r.Stats().Sent)
+	})
+
+	t.Run("should count entries as sent if any sink accepted them", func(t *testing.T) {
+		failing := SinkFunc(func(ctx context.Context, batch []Entry) error {
+			return errors.New(kinds.Unavailable, "down")
+		})
+
+		r := NewReporter(Options{
+			Sinks:   []Sink{failing, &collector{}},
+			OnError: func(err error) {},
+		})
+
+		require.NoError(t, r.Report(context.Background(), errors.New("oops")))
+		require.NoError(t, r.Shutdown(context.Background()))
+
+		assert.Equal(t, Stats{Queued: 1, Sent: 1, Failed: 1}, r.Stats())
+	})
+}
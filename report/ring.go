@@ -0,0 +1,309 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/icelolly/go-errors"
+)
+
+// DefaultRingSize is the number of entries a Ring keeps if it's not given a size.
+const DefaultRingSize = 100
+
+// Ring is a Sink that keeps the most recent entries in memory, so that they can be seen whilst a
+// process is running, e.g. during an incident, without going through a log pipeline. It's also an
+// http.Handler, similar to the ones in net/http/pprof, that lists the entries it holds, grouped by
+// fingerprint. It's safe for concurrent use.
+//
+// Stacks include fields, and file paths, so like pprof, the handler should only be reachable by
+// the people operating the process.
+//
+// Example usage:
+//
+//    ring := report.NewRing(0)
+//
+//    reporter := report.NewReporter(report.Options{
+//        Sinks: []report.Sink{ring},
+//    })
+//
+//    debugMux.Handle("/debug/errors", ring)
+//
+type Ring struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// Group is a set of entries with the same fingerprint.
+type Group struct {
+	Fingerprint string    `json:"fingerprint"`
+	Kind        string    `json:"kind,omitempty"`
+	Message     string    `json:"message"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+
+	// Count is the number of errors in the group, including errors that weren't sent due to sampling
+	// or rate limiting (see Entry.Suppressed).
+	Count int `json:"count"`
+
+	// Latest is the most recent entry in the group.
+	Latest Entry `json:"latest"`
+}
+
+// NewRing returns a new Ring that keeps the given number of entries. If size is zero or less,
+// DefaultRingSize is used.
+func NewRing(size int) *Ring {
+	if size <= 0 {
+		size = DefaultRingSize
+	}
+
+	return &Ring{
+		entries: make([]Entry, size),
+	}
+}
+
+// Send adds every entry in the given batch, so that Ring can be used as a Sink.
+func (r *Ring) Send(ctx context.Context, batch []Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, entry := range batch {
+		r.add(entry)
+	}
+
+	return nil
+}
+
+// Record adds an entry for the given error straight away, without using a Reporter. Nil errors are
+// ignored.
+func (r *Ring) Record(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	entry := newEntry(ctx, err, time.Now())
+
+	r.mu.Lock()
+	r.add(entry)
+	r.mu.Unlock()
+}
+
+// add adds the given entry, overwriting the oldest entry if the ring is full. The lock must be held.
+func (r *Ring) add(entry Entry) {
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Entries returns the entries in the ring, newest first.
+func (r *Ring) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	size := r.next
+	if r.full {
+		size = len(r.entries)
+	}
+
+	entries := make([]Entry, size)
+	for i := range entries {
+		entries[i] = r.entries[(r.next-1-i+len(r.entries))%len(r.entries)]
+	}
+
+	return entries
+}
+
+// Groups returns the entries in the ring grouped by fingerprint, with the most recently seen group
+// first. If any kinds are given, only entries of those kinds are included (see errors.Is).
+func (r *Ring) Groups(kinds ...errors.Kind) []Group {
+	var groups []Group
+
+	index := make(map[string]int)
+
+	for _, entry := range r.Entries() {
+		if !entryIs(entry, kinds) {
+			continue
+		}
+
+		i, ok := index[entry.Fingerprint]
+		if !ok {
+			i = len(groups)
+			index[entry.Fingerprint] = i
+
+			// Entries are newest first, so the first entry in each group is its latest.
+			groups = append(groups, Group{
+				Fingerprint: entry.Fingerprint,
+				Kind:        entry.Kind,
+				Message:     entry.Message,
+				LastSeen:    entry.Time,
+				Latest:      entry,
+			})
+		}
+
+		groups[i].FirstSeen = entry.Time
+		groups[i].Count += 1 + entry.Suppressed
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].LastSeen.After(groups[j].LastSeen)
+	})
+
+	return groups
+}
+
+// entryIs reports whether the given entry is of any of the given kinds, or if no kinds are given.
+func entryIs(entry Entry, kinds []errors.Kind) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+
+	for _, kind := range kinds {
+		if entry.Err != nil && errors.Is(entry.Err, kind) || entry.Kind == string(kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ringPage is the page written by Ring.ServeHTTP.
+var ringPage = template.Must(template.New("errors").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>/debug/errors</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { padding: .25rem .75rem; text-align: left; vertical-align: top; }
+tr:nth-child(even) { background: #f0f0f0; }
+td.count { text-align: right; }
+pre { padding: .5rem; background: #f0f0f0; overflow-x: auto; }
+</style>
+</head>
+<body>
+{{- if .Detail}}
+<p><a href="?">All errors</a></p>
+<h1>{{.Detail.Message}}</h1>
+<p>
+Kind: <code>{{.Detail.Kind}}</code><br>
+Fingerprint: <code>{{.Detail.Fingerprint}}</code><br>
+Count: {{.Detail.Count}}<br>
+First seen: {{.Detail.FirstSeen.Format "2006-01-02 15:04:05.000 MST"}}<br>
+Last seen: {{.Detail.LastSeen.Format "2006-01-02 15:04:05.000 MST"}}
+{{- if .Detail.Latest.ReferenceID}}<br>
+Latest reference: <code>{{.Detail.Latest.ReferenceID}}</code>
+{{- end}}
+</p>
+<pre>{{.Stack}}</pre>
+{{- else}}
+<h1>/debug/errors</h1>
+<p>The most recent errors, grouped by fingerprint. <a href="?format=json{{if .Kind}}&amp;kind={{.Kind}}{{end}}">JSON</a></p>
+{{- if .Groups}}
+<table>
+<tr><th>Count</th><th>Last seen</th><th>Kind</th><th>Message</th></tr>
+{{- range .Groups}}
+<tr>
+<td class="count">{{.Count}}</td>
+<td>{{.LastSeen.Format "15:04:05.000"}}</td>
+<td>{{if .Kind}}<a href="?kind={{.Kind}}"><code>{{.Kind}}</code></a>{{end}}</td>
+<td><a href="?fingerprint={{.Fingerprint}}">{{.Message}}</a></td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No errors.</p>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// ringData is the data rendered by ringPage.
+type ringData struct {
+	Kind   string
+	Groups []Group
+	Detail *Group
+	Stack  string
+}
+
+// ServeHTTP lists the entries in the ring, grouped by fingerprint, as an HTML page, or as JSON if
+// the "format" query parameter is "json". The "kind" query parameter, which may be repeated, only
+// includes entries of those kinds. The "fingerprint" query parameter shows the stack of the latest
+// entry with that fingerprint.
+func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	var kinds []errors.Kind
+	for _, kind := range query["kind"] {
+		kinds = append(kinds, errors.Kind(kind))
+	}
+
+	groups := r.Groups(kinds...)
+
+	if fingerprint := query.Get("fingerprint"); fingerprint != "" {
+		groups = groupsWithFingerprint(groups, fingerprint)
+		if len(groups) == 0 {
+			http.NotFound(w, req)
+			return
+		}
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if query.Get("format") == "json" {
+		if groups == nil {
+			groups = []Group{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Groups []Group `json:"groups"`
+		}{
+			Groups: groups,
+		})
+
+		return
+	}
+
+	data := ringData{
+		Kind:   query.Get("kind"),
+		Groups: groups,
+	}
+
+	if query.Get("fingerprint") != "" {
+		stack := bytes.Buffer{}
+		errors.NewPrinter(&stack).PrintStack(groups[0].Latest.Stack)
+
+		data.Detail = &groups[0]
+		data.Stack = stack.String()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	ringPage.Execute(w, data)
+}
+
+// groupsWithFingerprint returns the group with the given fingerprint, if there is one.
+func groupsWithFingerprint(groups []Group, fingerprint string) []Group {
+	for _, group := range groups {
+		if group.Fingerprint == fingerprint {
+			return []Group{group}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ringEntry returns an entry for the given error, reported at the given number of seconds past a
+// fixed time.
+func ringEntry(err error, seconds int) Entry {
+	return newEntry(context.Background(), err, time.Date(2020, time.January, 1, 0, 0, seconds, 0, time.UTC))
+}
+
+func TestNewRing(t *testing.T) {
+	assert.Len(t, NewRing(0).entries, DefaultRingSize)
+	assert.Len(t, NewRing(5).entries, 5)
+}
+
+func TestRing_Entries(t *testing.T) {
+	t.Run("should return entries newest first", func(t *testing.T) {
+		ring := NewRing(3)
+		assert.Empty(t, ring.Entries())
+
+		ring.Send(context.Background(), []Entry{{Message: "1"}, {Message: "2"}})
+
+		assert.Equal(t, []Entry{{Message: "2"}, {Message: "1"}}, ring.Entries())
+	})
+
+	t.Run("should overwrite the oldest entries once full", func(t *testing.T) {
+		ring := NewRing(3)
+
+		for _, msg := range []string{"1", "2", "3", "4", "5"} {
+			ring.Send(context.Background(), []Entry{{Message: msg}})
+		}
+
+		assert.Equal(t, []Entry{{Message: "5"}, {Message: "4"}, {Message: "3"}}, ring.Entries())
+	})
+}
+
+func TestRing_Record(t *testing.T) {
+	ring := NewRing(0)
+	ctx := errors.WithContextFields(context.Background(), "request_id", "abc")
+
+	err := errors.New(kinds.NotFound, "not found")
+
+	ring.Record(ctx, err)
+	ring.Record(ctx, nil)
+
+	entries := ring.Entries()
+	require.Len(t, entries, 1)
+
+	assert.Equal(t, err, entries[0].Err)
+	assert.Equal(t, errors.Fingerprint(err), entries[0].Fingerprint)
+	assert.Equal(t, "abc", entries[0].Fields["request_id"])
+	assert.NotEmpty(t, entries[0].Stack)
+	assert.False(t, entries[0].Time.IsZero())
+}
+
+func TestRing_Groups(t *testing.T) {
+	notFound := func() error { return errors.New(kinds.NotFound, "not found") }
+	internal := func() error { return errors.New(kinds.Internal, "oops") }
+	conflict := func() error { return errors.New(kinds.Conflict, "conflict") }
+
+	ring := NewRing(0)
+
+	suppressed := ringEntry(notFound(), 3)
+	suppressed.Suppressed = 5
+
+	ring.Send(context.Background(), []Entry{
+		ringEntry(notFound(), 1),
+		ringEntry(internal(), 2),
+		suppressed,
+		ringEntry(conflict(), 4),
+	})
+
+	t.Run("should group entries by fingerprint", func(t *testing.T) {
+		groups := ring.Groups()
+		require.Len(t, groups, 3)
+
+		assert.Equal(t, string(kinds.Conflict), groups[0].Kind)
+
+		assert.Equal(t, string(kinds.NotFound), groups[1].Kind)
+		assert.Equal(t, 7, groups[1].Count)
+		assert.Equal(t, 1, groups[1].FirstSeen.Second())
+		assert.Equal(t, 3, groups[1].LastSeen.Second())
+		assert.Equal(t, 5, groups[1].Latest.Suppressed)
+
+		assert.Equal(t, string(kinds.Internal), groups[2].Kind)
+		assert.Equal(t, 1, groups[2].Count)
+	})
+
+	t.Run("should filter groups by kind", func(t *testing.T) {
+		groups := ring.Groups(kinds.Internal, kinds.NotFound)
+		require.Len(t, groups, 2)
+
+		assert.Equal(t, string(kinds.NotFound), groups[0].Kind)
+		assert.Equal(t, string(kinds.Internal), groups[1].Kind)
+	})
+
+	t.Run("should include derived kinds", func(t *testing.T) {
+		groups := ring.Groups(kinds.Aborted)
+		require.Len(t, groups, 1)
+
+		assert.Equal(t, string(kinds.Conflict), groups[0].Kind)
+	})
+}
+
+func TestRing_ServeHTTP(t *testing.T) {
+	ring := NewRing(0)
+
+	notFound := errors.New(kinds.NotFound, "<b>not found</b>").WithField("user_id", 42)
+
+	ring.Send(context.Background(), []Entry{
+		ringEntry(notFound, 1),
+		ringEntry(errors.New(kinds.Internal, "oops"), 2),
+	})
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ring.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run("should list groups as HTML", func(t *testing.T) {
+		rec := serve("/debug/errors")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Body.String(), "&lt;b&gt;not found&lt;/b&gt;")
+		assert.Contains(t, rec.Body.String(), "?fingerprint="+errors.Fingerprint(notFound))
+		assert.Contains(t, rec.Body.String(), "oops")
+	})
+
+	t.Run("should list groups as JSON", func(t *testing.T) {
+		rec := serve("/debug/errors?format=json&kind=" + url.QueryEscape(string(kinds.NotFound)))
+
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var body struct {
+			Groups []Group `json:"groups"`
+		}
+
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Groups, 1)
+
+		assert.Equal(t, string(kinds.NotFound), body.Groups[0].Kind)
+		assert.Equal(t, 1, body.Groups[0].Count)
+		assert.Equal(t, errors.Fingerprint(notFound), body.Groups[0].Latest.Fingerprint)
+		assert.NotEmpty(t, body.Groups[0].Latest.Stack)
+	})
+
+	t.Run("should return an empty list as JSON", func(t *testing.T) {
+		rec := serve("/debug/errors?format=json&kind=nope")
+		assert.JSONEq(t, `{"groups":[]}`, rec.Body.String())
+	})
+
+	t.Run("should show the stack of the latest entry in a group", func(t *testing.T) {
+		rec := serve("/debug/errors?fingerprint=" + url.QueryEscape(errors.Fingerprint(notFound)))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "ring_test.go")
+		assert.Contains(t, rec.Body.String(), "user_id")
+	})
+
+	t.Run("should return not found for unknown fingerprints", func(t *testing.T) {
+		rec := serve("/debug/errors?fingerprint=nope")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
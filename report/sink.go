@@ -0,0 +1,121 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/httperr"
+)
+
+// Sink is somewhere that entries are sent to. Sinks are only called by a Reporter's worker, one
+// batch at a time, so they don't need to be safe for concurrent use unless they're shared between
+// reporters. A sink must not change the batch it's given, as the same batch is given to every sink.
+type Sink interface {
+	Send(ctx context.Context, batch []Entry) error
+}
+
+// SinkFunc is a function that can be used as a Sink.
+type SinkFunc func(ctx context.Context, batch []Entry) error
+
+// Send calls fn.
+func (fn SinkFunc) Send(ctx context.Context, batch []Entry) error {
+	return fn(ctx, batch)
+}
+
+// WriterSink is a Sink that writes each entry to Writer as a line of JSON (i.e. NDJSON). Writes are
+// serialised, so the same WriterSink can be shared between reporters.
+type WriterSink struct {
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+// Send writes each entry in the given batch as a line of JSON. The whole batch is written at once.
+func (s *WriterSink) Send(ctx context.Context, batch []Entry) error {
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+
+	for _, entry := range batch {
+		if err := enc.Encode(entry); err != nil {
+			return errors.Wrap(err, "report: failed to encode entry").
+				WithField("fingerprint", entry.Fingerprint)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := buf.WriteTo(s.Writer); err != nil {
+		return errors.Wrap(err, "report: failed to write entries")
+	}
+
+	return nil
+}
+
+// Webhook is a Sink that sends each batch to URL as a JSON object, with the entries in its
+// "errors" property, in a POST request. Responses with a status code of 400 or above are returned
+// as errors (see httperr.FromResponse).
+type Webhook struct {
+	// URL is where batches are sent.
+	URL string
+
+	// Header is added to each request, e.g. for authentication.
+	Header http.Header
+
+	// Client is used to send requests. If it's nil, http.DefaultClient is used. The reporter's send
+	// timeout applies to each request, regardless of the client's timeout.
+	Client *http.Client
+}
+
+// webhookBody is the body of the requests sent by Webhook.
+type webhookBody struct {
+	Errors []Entry `json:"errors"`
+}
+
+// Send sends the given batch to the webhook's URL.
+func (h *Webhook) Send(ctx context.Context, batch []Entry) error {
+	body, err := json.Marshal(webhookBody{Errors: batch})
+	if err != nil {
+		return errors.Wrap(err, "report: failed to encode entries")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "report: failed to create webhook request")
+	}
+
+	req = req.WithContext(ctx)
+
+	for k, vs := range h.Header {
+		req.Header[k] = vs
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.WrapClassified(err, "report: webhook request failed")
+	}
+
+	defer resp.Body.Close()
+
+	if err := httperr.FromResponse(resp); err != nil {
+		return errors.Wrap(err, "report: webhook returned an error")
+	}
+
+	// The body is read so that the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+
+	return nil
+}
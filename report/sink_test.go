@@ -0,0 +1,111 @@
+package report
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSinkFunc_Send(t *testing.T) {
+	var got []Entry
+	sink := SinkFunc(func(ctx context.Context, batch []Entry) error {
+		got = batch
+		return nil
+	})
+
+	batch := []Entry{{Message: "oops"}}
+
+	assert.NoError(t, sink.Send(context.Background(), batch))
+	assert.Equal(t, batch, got)
+}
+
+func TestWriterSink_Send(t *testing.T) {
+	buf := strings.Builder{}
+	sink := &WriterSink{Writer: &buf}
+
+	batch := []Entry{
+		newEntry(context.Background(), errors.New(kinds.NotFound, "not found").WithField("id", 42), time.Time{}),
+		newEntry(context.Background(), errors.New("oops"), time.Time{}),
+	}
+
+	require.NoError(t, sink.Send(context.Background(), batch))
+
+	scanner := bufio.NewScanner(strings.NewReader(buf.String()))
+
+	var lines []map[string]interface{}
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+
+		lines = append(lines, line)
+	}
+
+	require.Len(t, lines, 2)
+
+	assert.Equal(t, string(kinds.NotFound), lines[0]["kind"])
+	assert.Equal(t, map[string]interface{}{"id": float64(42)}, lines[0]["fields"])
+	assert.Equal(t, batch[0].Fingerprint, lines[0]["fingerprint"])
+	assert.NotContains(t, lines[1], "kind")
+}
+
+func TestWebhook_Send(t *testing.T) {
+	t.Run("should post the batch as JSON", func(t *testing.T) {
+		var (
+			body   webhookBody
+			header http.Header
+		)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header
+			json.NewDecoder(r.Body).Decode(&body)
+		}))
+
+		defer server.Close()
+
+		sink := &Webhook{
+			URL:    server.URL,
+			Header: http.Header{"Authorization": []string{"Bearer token"}},
+		}
+
+		batch := []Entry{newEntry(context.Background(), errors.New(kinds.NotFound, "not found"), time.Time{})}
+
+		require.NoError(t, sink.Send(context.Background(), batch))
+
+		assert.Equal(t, "application/json", header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", header.Get("Authorization"))
+
+		require.Len(t, body.Errors, 1)
+		assert.Equal(t, batch[0].Fingerprint, body.Errors[0].Fingerprint)
+	})
+
+	t.Run("should return an error for error responses", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+		defer server.Close()
+
+		sink := &Webhook{URL: server.URL}
+
+		err := sink.Send(context.Background(), []Entry{{Message: "oops"}})
+		assert.True(t, errors.Is(err, kinds.Unavailable))
+	})
+
+	t.Run("should return an error if the request fails", func(t *testing.T) {
+		sink := &Webhook{URL: "http://127.0.0.1:0"}
+
+		assert.Error(t, sink.Send(context.Background(), []Entry{{Message: "oops"}}))
+	})
+}
@@ -0,0 +1,234 @@
+// Package retry provides a helper for retrying operations that fail with errors that may succeed
+// if they're tried again, e.g. timeouts. Whether an error is worth retrying is decided from the
+// error itself, so the same rules apply everywhere an operation is retried.
+//
+// Example usage:
+//
+//    err := retry.Do(ctx, func(ctx context.Context) error {
+//        return client.FetchUser(ctx, id)
+//    }, retry.Policy{MaxAttempts: 5})
+//
+package retry
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/icelolly/go-errors"
+)
+
+// Default values used for any Policy fields that are not set.
+const (
+	DefaultMaxAttempts  = 3
+	DefaultInitialDelay = 100 * time.Millisecond
+	DefaultMaxDelay     = 10 * time.Second
+	DefaultMultiplier   = 2.0
+)
+
+// Policy controls how many times, and how often, an operation is retried.
+type Policy struct {
+	// MaxAttempts is the maximum number of times the operation will be called, including the first
+	// attempt.
+	MaxAttempts int
+
+	// InitialDelay is the delay before the second attempt. The delay before each attempt after that
+	// is multiplied by Multiplier, up to MaxDelay.
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+
+	// Jitter is the fraction of each delay that is randomised, between 0 and 1. Randomising delays
+	// stops many clients that failed at the same time from all retrying at the same time. For
+	// example, with a Jitter of 0.5, a delay of 1s will actually be between 0.5s and 1s.
+	Jitter float64
+
+	// Classifier decides whether an error is worth retrying. If it returns ok as false, the error
+	// is classified using Retryable instead. If Classifier is nil, Retryable is always used.
+	Classifier func(err error) (retryable, ok bool)
+
+	// WrapAll adds the errors from every attempt to the returned error, as the "attempt_errors"
+	// field (see AttemptErrors), rather than only the last one being returned.
+	WrapAll bool
+}
+
+// maxKindDepth is the maximum number of parents of a kind that are checked to see if the kind is
+// retryable, in case a kind has been registered as its own parent.
+const maxKindDepth = 16
+
+// AttemptErrors holds the errors returned by every attempt, in order. It's the value of the
+// "attempt_errors" field of an error returned by Do, if the policy's WrapAll is true, so that the
+// kinds and fields of earlier attempts' errors can still be checked. Errors don't usually have a
+// useful JSON encoding, so it's encoded as JSON as an array of the errors' messages instead.
+type AttemptErrors []error
+
+// MarshalJSON returns the messages of the errors as a JSON array.
+func (ae AttemptErrors) MarshalJSON() ([]byte, error) {
+	messages := make([]string, len(ae))
+	for i, err := range ae {
+		messages[i] = err.Error()
+	}
+
+	return json.Marshal(messages)
+}
+
+// Is reports whether any of the errors is of any of the given kinds or values (see errors.Is).
+func (ae AttemptErrors) Is(kind ...interface{}) bool {
+	for _, err := range ae {
+		if errors.Is(err, kind...) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Do calls fn until it succeeds, the error it returns is not worth retrying, the policy's maximum
+// number of attempts is reached, or the given context is done. If fn never succeeds, the last error
+// it returned is wrapped, with the "attempt" and "elapsed" fields set on it, and returned.
+func Do(ctx context.Context, fn func(ctx context.Context) error, policy Policy) error {
+	policy = policy.withDefaults()
+
+	start := time.Now()
+
+	var errs AttemptErrors
+	var err error
+	var attempt int
+
+	for attempt = 1; attempt <= policy.MaxAttempts; attempt++ {
+		err = fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if policy.WrapAll {
+			errs = append(errs, err)
+		}
+
+		if attempt == policy.MaxAttempts || !policy.retryable(err) {
+			break
+		}
+
+		if !sleep(ctx, policy.delay(attempt)) {
+			break
+		}
+	}
+
+	wrapped := errors.Wrap(err).WithFields(
+		"attempt", attempt,
+		"elapsed", time.Since(start),
+	)
+
+	if policy.WrapAll {
+		wrapped.WithField("attempt_errors", errs)
+	}
+
+	return wrapped
+}
+
+// Retryable reports whether the given error is worth retrying. An error is worth retrying if any
+// error in its stack has a Kind that is registered as retryable, or was derived from a kind that is
+// (see errors.RegisterKind, and KindInfo.Parent), or implements either a Timeout or Temporary method that returns true (e.g. like net.Error).
+func Retryable(err error) bool {
+	for err != nil {
+		if t, ok := err.(interface{ Timeout() bool }); ok && t.Timeout() {
+			return true
+		}
+
+		if t, ok := err.(interface{ Temporary() bool }); ok && t.Temporary() {
+			return true
+		}
+
+		if e, ok := err.(*errors.Error); ok && retryableKind(e.Kind) {
+			return true
+		}
+
+		err = errors.Unwrap(err)
+	}
+
+	return false
+}
+
+// retryableKind reports whether the given kind, or any of the kinds it was derived from, is
+// registered as retryable.
+func retryableKind(kind errors.Kind) bool {
+	for i := 0; i < maxKindDepth && kind != ""; i++ {
+		info, ok := errors.LookupKind(kind)
+		if !ok {
+			return false
+		}
+
+		if info.Retryable {
+			return true
+		}
+
+		kind = info.Parent
+	}
+
+	return false
+}
+
+// retryable reports whether the given error is worth retrying, according to this policy.
+func (p Policy) retryable(err error) bool {
+	if p.Classifier != nil {
+		if retryable, ok := p.Classifier(err); ok {
+			return retryable
+		}
+	}
+
+	return Retryable(err)
+}
+
+// delay returns how long to wait after the given attempt before making the next one.
+func (p Policy) delay(attempt int) time.Duration {
+	d := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+	if d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * rand.Float64()
+	}
+
+	return time.Duration(d)
+}
+
+// withDefaults returns a copy of this policy with defaults set for any fields that are not set.
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = DefaultInitialDelay
+	}
+
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultMaxDelay
+	}
+
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultMultiplier
+	}
+
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+
+	return p
+}
+
+// sleep waits for the given duration, returning false if the given context is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
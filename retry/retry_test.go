@@ -0,0 +1,213 @@
+package retry
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	errKindRetryable errors.Kind = "retry test: retryable"
+	errKindPermanent errors.Kind = "retry test: permanent"
+	errKindChild     errors.Kind = "retry test: child"
+)
+
+func init() {
+	errors.RegisterKind(errKindRetryable, errors.KindInfo{Retryable: true})
+	errors.RegisterKind(errKindPermanent, errors.KindInfo{Retryable: false})
+	errors.RegisterKind(errKindChild, errors.KindInfo{Parent: errKindRetryable})
+}
+
+// timeoutError is an error that satisfies the same Timeout interface as net.Error.
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+// fastPolicy is a policy with short delays, so that tests don't take long.
+var fastPolicy = Policy{
+	MaxAttempts:  3,
+	InitialDelay: time.Millisecond,
+	MaxDelay:     time.Millisecond,
+}
+
+func TestDo(t *testing.T) {
+	t.Run("should return nil if the operation succeeds", func(t *testing.T) {
+		var calls int
+
+		err := Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			return nil
+		}, fastPolicy)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("should retry retryable errors until the operation succeeds", func(t *testing.T) {
+		var calls int
+
+		err := Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return errors.New(errKindRetryable)
+			}
+
+			return nil
+		}, fastPolicy)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("should stop after the maximum number of attempts", func(t *testing.T) {
+		var calls int
+
+		err := Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			return errors.New(errKindRetryable)
+		}, fastPolicy)
+
+		require.Error(t, err)
+		assert.Equal(t, 3, calls)
+		assert.True(t, errors.Is(err, errKindRetryable))
+
+		fields := errors.Fields(err)
+		assert.Equal(t, 3, fields["attempt"])
+		assert.IsType(t, time.Duration(0), fields["elapsed"])
+	})
+
+	t.Run("should not retry errors that aren't retryable", func(t *testing.T) {
+		var calls int
+
+		err := Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			return errors.New(errKindPermanent)
+		}, fastPolicy)
+
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, 1, errors.Fields(err)["attempt"])
+	})
+
+	t.Run("should use the classifier if one is given", func(t *testing.T) {
+		var calls int
+
+		policy := fastPolicy
+		policy.Classifier = func(err error) (bool, bool) {
+			return errors.Is(err, errKindPermanent), true
+		}
+
+		_ = Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			return errors.New(errKindPermanent)
+		}, policy)
+
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("should wrap all attempt errors if configured", func(t *testing.T) {
+		policy := fastPolicy
+		policy.WrapAll = true
+
+		err := Do(context.Background(), func(ctx context.Context) error {
+			return errors.New(errKindRetryable, "attempt failed")
+		}, policy)
+
+		errs, ok := errors.Fields(err)["attempt_errors"].(AttemptErrors)
+		require.True(t, ok)
+		require.Len(t, errs, 3)
+		assert.Contains(t, errs[0].Error(), "attempt failed")
+		assert.True(t, errs.Is(errKindRetryable))
+		assert.False(t, errs.Is(errKindPermanent))
+
+		// The field must still be useful once the error has been logged as JSON.
+		bs, jerr := json.Marshal(errors.Fields(err))
+		require.NoError(t, jerr)
+		assert.Contains(t, string(bs), `"attempt_errors":["`)
+		assert.Contains(t, string(bs), `attempt failed`)
+	})
+
+	t.Run("should stop if the context is done", func(t *testing.T) {
+		var calls int
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		policy := fastPolicy
+		policy.InitialDelay = time.Hour
+		policy.MaxDelay = time.Hour
+
+		err := Do(ctx, func(ctx context.Context) error {
+			calls++
+			cancel()
+			return errors.New(errKindRetryable)
+		}, policy)
+
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestRetryable(t *testing.T) {
+	t.Run("should not retry nil errors", func(t *testing.T) {
+		assert.False(t, Retryable(nil))
+	})
+
+	t.Run("should retry kinds registered as retryable", func(t *testing.T) {
+		assert.True(t, Retryable(errors.Wrap(errors.New(errKindRetryable), "wrapped")))
+	})
+
+	t.Run("should not retry kinds that aren't registered as retryable", func(t *testing.T) {
+		assert.False(t, Retryable(errors.New(errKindPermanent)))
+		assert.False(t, Retryable(errors.New(errors.Kind("retry test: unregistered"))))
+	})
+
+	t.Run("should retry kinds derived from retryable kinds", func(t *testing.T) {
+		assert.True(t, Retryable(errors.New(errKindChild)))
+	})
+
+	t.Run("should retry errors that have timed out", func(t *testing.T) {
+		assert.True(t, Retryable(timeoutError{}))
+		assert.True(t, Retryable(errors.Wrap(timeoutError{}, "wrapped")))
+	})
+
+	t.Run("should retry retryable errors wrapped by other packages' errors", func(t *testing.T) {
+		err := &url.Error{Op: "Get", URL: "http://example.com", Err: errors.New(errKindRetryable)}
+
+		assert.True(t, Retryable(err))
+		assert.True(t, Retryable(errors.Wrap(err, "wrapped")))
+		assert.False(t, Retryable(&url.Error{Op: "Get", URL: "http://example.com", Err: errors.New(errKindPermanent)}))
+	})
+
+	t.Run("should not retry standard errors", func(t *testing.T) {
+		assert.False(t, Retryable(stderrors.New("oops")))
+	})
+}
+
+func TestPolicy_Delay(t *testing.T) {
+	t.Run("should back off exponentially up to the maximum delay", func(t *testing.T) {
+		p := Policy{InitialDelay: time.Second, MaxDelay: 5 * time.Second}.withDefaults()
+
+		assert.Equal(t, time.Second, p.delay(1))
+		assert.Equal(t, 2*time.Second, p.delay(2))
+		assert.Equal(t, 4*time.Second, p.delay(3))
+		assert.Equal(t, 5*time.Second, p.delay(4))
+	})
+
+	t.Run("should randomise delays with jitter", func(t *testing.T) {
+		p := Policy{InitialDelay: time.Second, Jitter: 0.5}.withDefaults()
+
+		for i := 0; i < 100; i++ {
+			d := p.delay(1)
+			assert.True(t, d >= 500*time.Millisecond && d <= time.Second, "delay: %v", d)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+// Package rpcstatus converts errors to and from gRPC status codes, and encodes error stacks into a
+// payload that is wire-compatible with google.rpc.Status, without depending on grpc-go. This keeps
+// gRPC out of the core package, whilst still letting gRPC services return errors that can be turned
+// back into the same kinds on the other side.
+//
+// Example usage with grpc-go, in a server:
+//
+//    st := rpcstatus.Encode(err, rpcstatus.WithPublicFields("user_id"))
+//
+//    var pb spb.Status
+//    if err := proto.Unmarshal(st.Marshal(), &pb); err != nil {
+//        // ...
+//    }
+//
+//    return nil, status.ErrorProto(&pb)
+//
+// And in a client:
+//
+//    bs, _ := proto.Marshal(status.Convert(err).Proto())
+//
+//    st, perr := rpcstatus.Unmarshal(bs)
+//    if perr != nil {
+//        // ...
+//    }
+//
+//    err = rpcstatus.Decode(st)
+//
+package rpcstatus
+
+import (
+	"strconv"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+)
+
+// Code is a canonical gRPC status code.
+type Code uint32
+
+// Canonical gRPC status codes.
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+// maxKindDepth limits how far CodeOf will look through a kind's parents, in case a cycle has been
+// registered.
+const maxKindDepth = 32
+
+// codeNames holds the name of each code, as used by grpc-go's codes package.
+var codeNames = map[Code]string{
+	OK:                 "OK",
+	Canceled:           "Canceled",
+	Unknown:            "Unknown",
+	InvalidArgument:    "InvalidArgument",
+	DeadlineExceeded:   "DeadlineExceeded",
+	NotFound:           "NotFound",
+	AlreadyExists:      "AlreadyExists",
+	PermissionDenied:   "PermissionDenied",
+	ResourceExhausted:  "ResourceExhausted",
+	FailedPrecondition: "FailedPrecondition",
+	Aborted:            "Aborted",
+	OutOfRange:         "OutOfRange",
+	Unimplemented:      "Unimplemented",
+	Internal:           "Internal",
+	Unavailable:        "Unavailable",
+	DataLoss:           "DataLoss",
+	Unauthenticated:    "Unauthenticated",
+}
+
+// kindCodes maps each standard kind to its code.
+var kindCodes = map[errors.Kind]Code{
+	kinds.Canceled:           Canceled,
+	kinds.Unknown:            Unknown,
+	kinds.InvalidArgument:    InvalidArgument,
+	kinds.DeadlineExceeded:   DeadlineExceeded,
+	kinds.NotFound:           NotFound,
+	kinds.AlreadyExists:      AlreadyExists,
+	kinds.PermissionDenied:   PermissionDenied,
+	kinds.ResourceExhausted:  ResourceExhausted,
+	kinds.FailedPrecondition: FailedPrecondition,
+	kinds.Aborted:            Aborted,
+	kinds.OutOfRange:         OutOfRange,
+	kinds.Unimplemented:      Unimplemented,
+	kinds.Internal:           Internal,
+	kinds.Unavailable:        Unavailable,
+	kinds.DataLoss:           DataLoss,
+	kinds.Unauthenticated:    Unauthenticated,
+}
+
+// codeKinds maps each code to its standard kind.
+var codeKinds = make(map[Code]errors.Kind, len(kindCodes))
+
+func init() {
+	for kind, code := range kindCodes {
+		codeKinds[code] = kind
+	}
+}
+
+// String returns the name of the code, e.g. "NotFound".
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+// CodeOf returns the code for the given kind. Kinds derived from a standard kind (see kinds.Derive)
+// have the same code as the standard kind. Unknown is returned for any other kind.
+func CodeOf(kind errors.Kind) Code {
+	for i := 0; i < maxKindDepth && kind != ""; i++ {
+		if code, ok := kindCodes[kind]; ok {
+			return code
+		}
+
+		info, _ := errors.LookupKind(kind)
+		kind = info.Parent
+	}
+
+	return Unknown
+}
+
+// KindOf returns the standard kind for the given code. An empty Kind is returned for OK, and for
+// codes that aren't canonical.
+func KindOf(code Code) errors.Kind {
+	return codeKinds[code]
+}
+
+// FromError returns the code for the given error. OK is returned for a nil error. Otherwise the
+// code is picked using the error's kind, which is found using errors.Classify, so errors from the
+// standard library (e.g. context.DeadlineExceeded) get the right code too.
+func FromError(err error) Code {
+	if err == nil {
+		return OK
+	}
+
+	return CodeOf(errors.Classify(err))
+}
@@ -0,0 +1,53 @@
+package rpcstatus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCode_String(t *testing.T) {
+	assert.Equal(t, "OK", OK.String())
+	assert.Equal(t, "NotFound", NotFound.String())
+	assert.Equal(t, "Code(42)", Code(42).String())
+}
+
+func TestCodeOf(t *testing.T) {
+	t.Run("should map every standard kind to a code", func(t *testing.T) {
+		for _, kind := range kinds.All() {
+			if kind == kinds.Conflict {
+				continue
+			}
+
+			assert.Equal(t, kind, KindOf(CodeOf(kind)), "kind: %s", kind)
+		}
+	})
+
+	t.Run("should use the code of a derived kind's parent", func(t *testing.T) {
+		kind := kinds.Derive(kinds.NotFound, "rpcstatus test: user not found")
+
+		assert.Equal(t, NotFound, CodeOf(kind))
+		assert.Equal(t, Aborted, CodeOf(kinds.Conflict))
+	})
+
+	t.Run("should return Unknown for other kinds", func(t *testing.T) {
+		assert.Equal(t, Unknown, CodeOf("rpcstatus test: unregistered"))
+		assert.Equal(t, Unknown, CodeOf(""))
+	})
+}
+
+func TestKindOf(t *testing.T) {
+	assert.Equal(t, kinds.Unauthenticated, KindOf(Unauthenticated))
+	assert.Equal(t, errors.Kind(""), KindOf(OK))
+	assert.Equal(t, errors.Kind(""), KindOf(Code(42)))
+}
+
+func TestFromError(t *testing.T) {
+	assert.Equal(t, OK, FromError(nil))
+	assert.Equal(t, NotFound, FromError(errors.New(kinds.NotFound)))
+	assert.Equal(t, DeadlineExceeded, FromError(errors.Wrap(context.DeadlineExceeded)))
+	assert.Equal(t, Unknown, FromError(errors.New("oops")))
+}
@@ -0,0 +1,477 @@
+package rpcstatus
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/icelolly/go-errors"
+)
+
+// ChainTypeURL is the type URL of the detail that holds an encoded error stack. The detail is a
+// protocol buffer message equivalent to:
+//
+//    package icelolly.errors.v1;
+//
+//    message ErrorChain {
+//        repeated ErrorFrame frames = 1;
+//        string reference_id = 2;
+//    }
+//
+//    message ErrorFrame {
+//        string kind = 1;
+//        string message = 2;
+//        map<string, string> fields = 3;
+//    }
+//
+const ChainTypeURL = "type.googleapis.com/icelolly.errors.v1.ErrorChain"
+
+// Status is a gRPC status. It has the same fields as google.rpc.Status, and Marshal and Unmarshal
+// use the same wire format, so it can be converted to and from grpc-go's status type.
+type Status struct {
+	Code    Code
+	Message string
+	Details []Any
+}
+
+// Any is an encoded message of any type, like google.protobuf.Any.
+type Any struct {
+	TypeURL string
+	Value   []byte
+}
+
+// Frame is a single error in an encoded error stack.
+type Frame struct {
+	Kind    errors.Kind
+	Message string
+	Fields  map[string]string
+}
+
+// Chain is an encoded error stack, as held by the detail with the type URL ChainTypeURL.
+type Chain struct {
+	Frames      []Frame
+	ReferenceID string
+}
+
+// Option configures how Encode encodes an error.
+type Option func(o *options)
+
+// options holds the configuration set by Options.
+type options struct {
+	publicFields  map[string]bool
+	noMessages    bool
+	causeMessages bool
+}
+
+// WithPublicFields sets the fields that are included in the encoded error stack. Fields often hold
+// internal information, so none are included unless they're listed here.
+func WithPublicFields(keys ...string) Option {
+	return func(o *options) {
+		for _, key := range keys {
+			o.publicFields[key] = true
+		}
+	}
+}
+
+// WithoutMessages leaves the messages of each error out of the encoded error stack, which is useful
+// when responding to clients outside of your own services, as they may hold internal information.
+// The user-facing message (see errors.Message) is still used as the status' message.
+func WithoutMessages() Option {
+	return func(o *options) {
+		o.noMessages = true
+	}
+}
+
+// WithCauseMessages includes the message of the error at the bottom of the stack in the encoded
+// error stack, if it isn't an *errors.Error. These errors usually come from other packages (e.g. a
+// database driver), so their messages may hold internal information, and are left out by default.
+func WithCauseMessages() Option {
+	return func(o *options) {
+		o.causeMessages = true
+	}
+}
+
+// Encode returns the status for the given error. The status' code is picked using FromError, and its
+// message is the error's user-facing message (see errors.Message). The error's stack, including its
+// kinds, messages, public fields, and reference ID, is added as a detail, so that Decode can rebuild
+// it. An error at the bottom of the stack that isn't an *errors.Error is left out of the encoded
+// stack, unless WithCauseMessages is given. A nil error produces a status with the code OK, and no
+// details.
+func Encode(err error, opts ...Option) *Status {
+	if err == nil {
+		return &Status{Code: OK}
+	}
+
+	o := options{publicFields: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	stack := errors.Stack(err)
+	chain := Chain{
+		Frames:      make([]Frame, 0, len(stack)),
+		ReferenceID: errors.ReferenceID(err),
+	}
+
+	for i, sf := range stack {
+		if i == len(stack)-1 && !o.causeMessages && !isError(err) {
+			break
+		}
+
+		frame := Frame{Kind: errors.Kind(sf.Kind)}
+		if !o.noMessages {
+			frame.Message = sf.Message
+		}
+
+		for k, v := range sf.Fields {
+			if !o.publicFields[k] {
+				continue
+			}
+
+			if frame.Fields == nil {
+				frame.Fields = make(map[string]string)
+			}
+
+			frame.Fields[k] = fmt.Sprint(v)
+		}
+
+		chain.Frames = append(chain.Frames, frame)
+	}
+
+	return &Status{
+		Code:    FromError(err),
+		Message: errors.Message(err),
+		Details: []Any{{TypeURL: ChainTypeURL, Value: chain.Marshal()}},
+	}
+}
+
+// isError reports whether the error at the bottom of the given error's stack is an *errors.Error.
+func isError(err error) bool {
+	for {
+		e, ok := err.(*errors.Error)
+		if !ok {
+			return false
+		}
+
+		if e.Cause == nil {
+			return true
+		}
+
+		err = e.Cause
+	}
+}
+
+// Decode returns the error for the given status, or nil if the status' code is OK. If the status
+// has an encoded error stack, the stack is rebuilt, including its reference ID. If none of the
+// errors in the stack have a kind, the top error is given the standard kind for the status' code
+// (see KindOf), so that it can still be handled using errors.Is. Otherwise, a single error is
+// returned, with the standard kind for the status' code, and its message.
+func Decode(st *Status) error {
+	if st == nil || st.Code == OK {
+		return nil
+	}
+
+	chain, ok, err := st.Chain()
+	if err != nil || !ok || len(chain.Frames) == 0 {
+		return errors.New(KindOf(st.Code), st.Message)
+	}
+
+	var cause error
+	for i := len(chain.Frames) - 1; i >= 0; i-- {
+		frame := chain.Frames[i]
+
+		args := []interface{}{frame.Message}
+		if frame.Kind != "" {
+			args = append(args, frame.Kind)
+		}
+
+		var e *errors.Error
+		if cause == nil {
+			e = errors.New(args...)
+		} else {
+			e = errors.Wrap(cause, args...)
+		}
+
+		for k, v := range frame.Fields {
+			e.WithField(k, v)
+		}
+
+		cause = e
+	}
+
+	top := cause.(*errors.Error)
+	if !hasKind(chain) {
+		top.Kind = KindOf(st.Code)
+	}
+
+	if chain.ReferenceID != "" {
+		top.WithReferenceID(chain.ReferenceID)
+	}
+
+	return top
+}
+
+// hasKind reports whether any of the frames in the given chain have a kind.
+func hasKind(chain Chain) bool {
+	for _, frame := range chain.Frames {
+		if frame.Kind != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Chain returns the encoded error stack held in the status' details, and reports whether there was
+// one. An error is returned if the detail can't be decoded.
+func (s *Status) Chain() (Chain, bool, error) {
+	for _, detail := range s.Details {
+		if detail.TypeURL != ChainTypeURL {
+			continue
+		}
+
+		chain, err := UnmarshalChain(detail.Value)
+		if err != nil {
+			return Chain{}, false, err
+		}
+
+		return chain, true, nil
+	}
+
+	return Chain{}, false, nil
+}
+
+// Marshal encodes the status using the same wire format as google.rpc.Status.
+func (s *Status) Marshal() []byte {
+	var e encoder
+
+	e.uint(1, uint64(s.Code))
+	e.string(2, s.Message)
+
+	for _, detail := range s.Details {
+		detail := detail
+		e.message(3, func(e *encoder) {
+			e.string(1, detail.TypeURL)
+			e.bytes(2, detail.Value)
+		})
+	}
+
+	return e.buf
+}
+
+// Unmarshal decodes a status encoded in the same wire format as google.rpc.Status. Unknown fields
+// are ignored.
+func Unmarshal(bs []byte) (*Status, error) {
+	st := &Status{}
+	d := decoder{buf: bs}
+
+	for !d.done() {
+		field, wireType, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case field == 1 && wireType == wireVarint:
+			var v uint64
+			if v, err = d.varint(); err == nil {
+				st.Code = Code(v)
+			}
+		case field == 2 && wireType == wireBytes:
+			st.Message, err = d.string()
+		case field == 3 && wireType == wireBytes:
+			var detail Any
+			if detail, err = unmarshalAny(&d); err == nil {
+				st.Details = append(st.Details, detail)
+			}
+		default:
+			err = d.skip(wireType)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return st, nil
+}
+
+// Marshal encodes the error stack, for use as the value of a detail with the type URL ChainTypeURL.
+func (c Chain) Marshal() []byte {
+	var e encoder
+
+	for _, frame := range c.Frames {
+		frame := frame
+		e.message(1, func(e *encoder) {
+			e.string(1, string(frame.Kind))
+			e.string(2, frame.Message)
+
+			for _, k := range sortedKeys(frame.Fields) {
+				v := frame.Fields[k]
+				e.message(3, func(e *encoder) {
+					e.string(1, k)
+					e.string(2, v)
+				})
+			}
+		})
+	}
+
+	e.string(2, c.ReferenceID)
+
+	return e.buf
+}
+
+// UnmarshalChain decodes an error stack encoded by Chain.Marshal. Unknown fields are ignored.
+func UnmarshalChain(bs []byte) (Chain, error) {
+	var chain Chain
+	d := decoder{buf: bs}
+
+	for !d.done() {
+		field, wireType, err := d.key()
+		if err != nil {
+			return Chain{}, err
+		}
+
+		switch {
+		case field == 1 && wireType == wireBytes:
+			var frame Frame
+			if frame, err = unmarshalFrame(&d); err == nil {
+				chain.Frames = append(chain.Frames, frame)
+			}
+		case field == 2 && wireType == wireBytes:
+			chain.ReferenceID, err = d.string()
+		default:
+			err = d.skip(wireType)
+		}
+
+		if err != nil {
+			return Chain{}, err
+		}
+	}
+
+	return chain, nil
+}
+
+// unmarshalAny decodes an embedded google.protobuf.Any message.
+func unmarshalAny(parent *decoder) (Any, error) {
+	bs, err := parent.bytes()
+	if err != nil {
+		return Any{}, err
+	}
+
+	var detail Any
+	d := decoder{buf: bs}
+
+	for !d.done() {
+		field, wireType, err := d.key()
+		if err != nil {
+			return Any{}, err
+		}
+
+		switch {
+		case field == 1 && wireType == wireBytes:
+			detail.TypeURL, err = d.string()
+		case field == 2 && wireType == wireBytes:
+			var v []byte
+			if v, err = d.bytes(); err == nil {
+				detail.Value = append([]byte(nil), v...)
+			}
+		default:
+			err = d.skip(wireType)
+		}
+
+		if err != nil {
+			return Any{}, err
+		}
+	}
+
+	return detail, nil
+}
+
+// unmarshalFrame decodes an embedded ErrorFrame message.
+func unmarshalFrame(parent *decoder) (Frame, error) {
+	bs, err := parent.bytes()
+	if err != nil {
+		return Frame{}, err
+	}
+
+	var frame Frame
+	d := decoder{buf: bs}
+
+	for !d.done() {
+		field, wireType, err := d.key()
+		if err != nil {
+			return Frame{}, err
+		}
+
+		switch {
+		case field == 1 && wireType == wireBytes:
+			var kind string
+			if kind, err = d.string(); err == nil {
+				frame.Kind = errors.Kind(kind)
+			}
+		case field == 2 && wireType == wireBytes:
+			frame.Message, err = d.string()
+		case field == 3 && wireType == wireBytes:
+			var k, v string
+			if k, v, err = unmarshalMapEntry(&d); err == nil {
+				if frame.Fields == nil {
+					frame.Fields = make(map[string]string)
+				}
+
+				frame.Fields[k] = v
+			}
+		default:
+			err = d.skip(wireType)
+		}
+
+		if err != nil {
+			return Frame{}, err
+		}
+	}
+
+	return frame, nil
+}
+
+// unmarshalMapEntry decodes an embedded map<string, string> entry.
+func unmarshalMapEntry(parent *decoder) (key, value string, err error) {
+	bs, err := parent.bytes()
+	if err != nil {
+		return "", "", err
+	}
+
+	d := decoder{buf: bs}
+
+	for !d.done() {
+		field, wireType, err := d.key()
+		if err != nil {
+			return "", "", err
+		}
+
+		switch {
+		case field == 1 && wireType == wireBytes:
+			key, err = d.string()
+		case field == 2 && wireType == wireBytes:
+			value, err = d.string()
+		default:
+			err = d.skip(wireType)
+		}
+
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	return key, value, nil
+}
+
+// sortedKeys returns the keys of the given map, sorted, so that encoding is deterministic.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,171 @@
+package rpcstatus
+
+import (
+	"database/sql"
+	stderrors "errors"
+	"testing"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus_Marshal(t *testing.T) {
+	t.Run("should use the google.rpc.Status wire format", func(t *testing.T) {
+		st := &Status{
+			Code:    NotFound,
+			Message: "hi",
+			Details: []Any{{TypeURL: "a", Value: []byte{0x01}}},
+		}
+
+		expected := []byte{
+			0x08, 0x05, // code
+			0x12, 0x02, 'h', 'i', // message
+			0x1a, 0x06, 0x0a, 0x01, 'a', 0x12, 0x01, 0x01, // details
+		}
+
+		assert.Equal(t, expected, st.Marshal())
+	})
+
+	t.Run("should round-trip", func(t *testing.T) {
+		st := &Status{
+			Code:    Unavailable,
+			Message: "Please try again later.",
+			Details: []Any{
+				{TypeURL: "type.googleapis.com/example.Detail", Value: []byte{0x08, 0x01}},
+				{TypeURL: ChainTypeURL},
+			},
+		}
+
+		decoded, err := Unmarshal(st.Marshal())
+		require.NoError(t, err)
+		assert.Equal(t, st, decoded)
+	})
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Run("should ignore unknown fields", func(t *testing.T) {
+		bs := []byte{
+			0x08, 0x03, // code
+			0x20, 0x01, // unknown varint field 4
+			0x2d, 1, 2, 3, 4, // unknown fixed32 field 5
+		}
+
+		st, err := Unmarshal(bs)
+		require.NoError(t, err)
+		assert.Equal(t, InvalidArgument, st.Code)
+	})
+
+	t.Run("should return an error for malformed payloads", func(t *testing.T) {
+		_, err := Unmarshal([]byte{0x12, 0x05, 'h'})
+		assert.Error(t, err)
+		assert.True(t, errors.Is(err, kinds.InvalidArgument))
+	})
+}
+
+func TestEncode(t *testing.T) {
+	t.Run("should return OK for nil errors", func(t *testing.T) {
+		st := Encode(nil)
+
+		assert.Equal(t, OK, st.Code)
+		assert.Empty(t, st.Details)
+		assert.Nil(t, Decode(st))
+	})
+
+	t.Run("should use the user-facing message", func(t *testing.T) {
+		st := Encode(errors.Wrap(errors.New(kinds.NotFound)))
+
+		assert.Equal(t, NotFound, st.Code)
+		assert.Equal(t, "The requested resource could not be found.", st.Message)
+	})
+
+	t.Run("should encode the error stack", func(t *testing.T) {
+		err := errors.Wrap(errors.New(kinds.NotFound, "users: user not found").WithFields(
+			"user_id", 42,
+			"query", "SELECT ...",
+		), "handler: failed to get user")
+
+		st := Encode(err, WithPublicFields("user_id"))
+
+		chain, ok, cerr := st.Chain()
+		require.NoError(t, cerr)
+		require.True(t, ok)
+
+		assert.Equal(t, errors.ReferenceID(err), chain.ReferenceID)
+		assert.Equal(t, []Frame{
+			{Message: "handler: failed to get user"},
+			{Kind: kinds.NotFound, Message: "users: user not found", Fields: map[string]string{"user_id": "42"}},
+		}, chain.Frames)
+	})
+
+	t.Run("should leave messages out if asked to", func(t *testing.T) {
+		st := Encode(errors.New(kinds.Internal, "db: connection string is postgres://..."), WithoutMessages())
+
+		chain, _, cerr := st.Chain()
+		require.NoError(t, cerr)
+		require.Len(t, chain.Frames, 1)
+		assert.Empty(t, chain.Frames[0].Message)
+	})
+
+	t.Run("should leave out errors that aren't *errors.Error", func(t *testing.T) {
+		cause := stderrors.New("pq: relation \"users\" does not exist")
+		err := errors.Wrap(cause, kinds.Internal, "users: query failed")
+
+		chain, _, cerr := Encode(err).Chain()
+		require.NoError(t, cerr)
+		assert.Equal(t, []Frame{{Kind: kinds.Internal, Message: "users: query failed"}}, chain.Frames)
+
+		chain, _, cerr = Encode(cause).Chain()
+		require.NoError(t, cerr)
+		assert.Empty(t, chain.Frames)
+	})
+
+	t.Run("should include messages of errors that aren't *errors.Error if asked to", func(t *testing.T) {
+		cause := stderrors.New("pq: relation \"users\" does not exist")
+
+		chain, _, cerr := Encode(errors.Wrap(cause, "users: query failed"), WithCauseMessages()).Chain()
+		require.NoError(t, cerr)
+		assert.Equal(t, []Frame{
+			{Message: "users: query failed"},
+			{Message: cause.Error()},
+		}, chain.Frames)
+	})
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("should rebuild the error stack", func(t *testing.T) {
+		kind := kinds.Derive(kinds.NotFound, "rpcstatus test: user not found")
+		original := errors.Wrap(errors.New(kind, "users: user not found").WithField("user_id", 42), "wrapped")
+
+		st, err := Unmarshal(Encode(original, WithPublicFields("user_id")).Marshal())
+		require.NoError(t, err)
+
+		decoded := Decode(st)
+		require.Error(t, decoded)
+
+		assert.True(t, errors.Is(decoded, kind))
+		assert.True(t, errors.Is(decoded, kinds.NotFound))
+		assert.Equal(t, errors.ReferenceID(original), errors.ReferenceID(decoded))
+
+		stack := errors.Stack(decoded)
+		require.Len(t, stack, 2)
+		assert.Equal(t, "wrapped", stack[0].Message)
+		assert.Equal(t, "users: user not found", stack[1].Message)
+		assert.Equal(t, map[string]interface{}{"user_id": "42"}, stack[1].Fields)
+	})
+
+	t.Run("should use the code's kind when no error in the stack has a kind", func(t *testing.T) {
+		decoded := Decode(Encode(errors.Wrap(sql.ErrNoRows, "users: user not found")))
+
+		assert.True(t, errors.Is(decoded, kinds.NotFound))
+		assert.Equal(t, "users: user not found", errors.Stack(decoded)[0].Message)
+	})
+
+	t.Run("should use the code's kind when there is no error stack", func(t *testing.T) {
+		decoded := Decode(&Status{Code: PermissionDenied, Message: "Access denied."})
+
+		assert.True(t, errors.Is(decoded, kinds.PermissionDenied))
+		assert.Equal(t, "Access denied.", errors.Message(decoded))
+	})
+}
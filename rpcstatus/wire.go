@@ -0,0 +1,173 @@
+package rpcstatus
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+)
+
+// Protocol buffer wire types. Only the types used by the messages in this package are encoded, but
+// all of them can be skipped when decoding, so that newer fields don't break older decoders.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// errMalformed is returned when a payload can't be decoded.
+func errMalformed(reason string) *errors.Error {
+	return errors.New(kinds.InvalidArgument, "rpcstatus: malformed payload: "+reason)
+}
+
+// encoder builds a protocol buffer message.
+type encoder struct {
+	buf []byte
+}
+
+// varint appends the given value as a base 128 varint.
+func (e *encoder) varint(v uint64) {
+	for v >= 0x80 {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+
+	e.buf = append(e.buf, byte(v))
+}
+
+// key appends the key for the given field number and wire type.
+func (e *encoder) key(field int, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// uint appends the given integer field. Zero values are omitted, as in proto3.
+func (e *encoder) uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+
+	e.key(field, wireVarint)
+	e.varint(v)
+}
+
+// bytes appends the given length-delimited field. Empty values are omitted, as in proto3.
+func (e *encoder) bytes(field int, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+
+	e.key(field, wireBytes)
+	e.varint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// string appends the given string field. Empty values are omitted, as in proto3.
+func (e *encoder) string(field int, v string) {
+	if v == "" {
+		return
+	}
+
+	e.key(field, wireBytes)
+	e.varint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// message appends an embedded message field, built by the given function. Unlike other fields, the
+// message is always appended, even if it's empty, as it may be an element of a repeated field.
+func (e *encoder) message(field int, fn func(e *encoder)) {
+	var inner encoder
+	fn(&inner)
+
+	e.key(field, wireBytes)
+	e.varint(uint64(len(inner.buf)))
+	e.buf = append(e.buf, inner.buf...)
+}
+
+// decoder reads a protocol buffer message.
+type decoder struct {
+	buf []byte
+}
+
+// done reports whether the whole message has been read.
+func (d *decoder) done() bool {
+	return len(d.buf) == 0
+}
+
+// key reads the next field's number and wire type.
+func (d *decoder) key() (field int, wireType int, err error) {
+	v, err := d.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if v>>3 == 0 || v>>3 > math.MaxInt32 {
+		return 0, 0, errMalformed("invalid field number")
+	}
+
+	return int(v >> 3), int(v & 0x7), nil
+}
+
+// varint reads a base 128 varint.
+func (d *decoder) varint() (uint64, error) {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		return 0, errMalformed("invalid varint")
+	}
+
+	d.buf = d.buf[n:]
+
+	return v, nil
+}
+
+// bytes reads a length-delimited value. The returned slice refers to the decoder's buffer.
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+
+	if n > uint64(len(d.buf)) {
+		return nil, errMalformed("length exceeds payload")
+	}
+
+	v := d.buf[:n]
+	d.buf = d.buf[n:]
+
+	return v, nil
+}
+
+// string reads a length-delimited value as a string.
+func (d *decoder) string() (string, error) {
+	v, err := d.bytes()
+	return string(v), err
+}
+
+// skip reads and discards a value of the given wire type.
+func (d *decoder) skip(wireType int) error {
+	var n int
+
+	switch wireType {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireBytes:
+		_, err := d.bytes()
+		return err
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	default:
+		return errMalformed("unsupported wire type")
+	}
+
+	if len(d.buf) < n {
+		return errMalformed("truncated fixed-width value")
+	}
+
+	d.buf = d.buf[n:]
+
+	return nil
+}
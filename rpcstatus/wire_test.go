@@ -0,0 +1,87 @@
+package rpcstatus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoder(t *testing.T) {
+	t.Run("should encode varints", func(t *testing.T) {
+		var e encoder
+		e.varint(1)
+		e.varint(300)
+
+		assert.Equal(t, []byte{0x01, 0xac, 0x02}, e.buf)
+	})
+
+	t.Run("should omit zero values", func(t *testing.T) {
+		var e encoder
+		e.uint(1, 0)
+		e.string(2, "")
+		e.bytes(3, nil)
+
+		assert.Empty(t, e.buf)
+	})
+
+	t.Run("should always encode embedded messages", func(t *testing.T) {
+		var e encoder
+		e.message(3, func(e *encoder) {})
+
+		assert.Equal(t, []byte{0x1a, 0x00}, e.buf)
+	})
+}
+
+func TestDecoder(t *testing.T) {
+	t.Run("should decode fields", func(t *testing.T) {
+		d := decoder{buf: []byte{0x08, 0xac, 0x02, 0x12, 0x02, 'h', 'i'}}
+
+		field, wireType, err := d.key()
+		require.NoError(t, err)
+		assert.Equal(t, 1, field)
+		assert.Equal(t, wireVarint, wireType)
+
+		v, err := d.varint()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(300), v)
+
+		field, wireType, err = d.key()
+		require.NoError(t, err)
+		assert.Equal(t, 2, field)
+		assert.Equal(t, wireBytes, wireType)
+
+		s, err := d.string()
+		require.NoError(t, err)
+		assert.Equal(t, "hi", s)
+		assert.True(t, d.done())
+	})
+
+	t.Run("should skip values of every wire type", func(t *testing.T) {
+		d := decoder{buf: []byte{0x01, 0x01, 0x61, 1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4}}
+
+		require.NoError(t, d.skip(wireVarint))
+		require.NoError(t, d.skip(wireBytes))
+		require.NoError(t, d.skip(wireFixed64))
+		require.NoError(t, d.skip(wireFixed32))
+		assert.True(t, d.done())
+	})
+
+	t.Run("should return an error for truncated values", func(t *testing.T) {
+		for _, bs := range [][]byte{{0x80}, {0x05, 'a'}} {
+			d := decoder{buf: bs}
+			_, err := d.bytes()
+			assert.Error(t, err)
+		}
+
+		d := decoder{buf: []byte{1, 2}}
+		assert.Error(t, d.skip(wireFixed32))
+		assert.Error(t, d.skip(3))
+	})
+
+	t.Run("should return an error for field number zero", func(t *testing.T) {
+		d := decoder{buf: []byte{0x02}}
+		_, _, err := d.key()
+		assert.Error(t, err)
+	})
+}
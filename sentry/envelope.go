@@ -0,0 +1,121 @@
+package sentry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+)
+
+// EnvelopeContentType is the media type of a Sentry envelope.
+const EnvelopeContentType = "application/x-sentry-envelope"
+
+// DSN is a parsed Sentry DSN, which says where, and how, events are sent, e.g.
+// "https://public@o0.ingest.sentry.io/42".
+type DSN struct {
+	Scheme    string
+	PublicKey string
+	Host      string
+
+	// Path is the path that Sentry is hosted under, if it's not hosted at the root of Host.
+	Path      string
+	ProjectID string
+}
+
+// ParseDSN parses the given Sentry DSN.
+func ParseDSN(dsn string) (*DSN, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, kinds.InvalidArgument, "sentry: invalid DSN")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New(kinds.InvalidArgument, fmt.Sprintf("sentry: invalid DSN scheme %q", u.Scheme))
+	}
+
+	if u.User == nil || u.User.Username() == "" {
+		return nil, errors.New(kinds.InvalidArgument, "sentry: DSN has no public key")
+	}
+
+	if u.Host == "" {
+		return nil, errors.New(kinds.InvalidArgument, "sentry: DSN has no host")
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+
+	idx := strings.LastIndex(path, "/")
+	if idx < 0 || idx == len(path)-1 {
+		return nil, errors.New(kinds.InvalidArgument, "sentry: DSN has no project ID")
+	}
+
+	return &DSN{
+		Scheme:    u.Scheme,
+		PublicKey: u.User.Username(),
+		Host:      u.Host,
+		Path:      path[:idx],
+		ProjectID: path[idx+1:],
+	}, nil
+}
+
+// String returns the DSN in the format that ParseDSN parses.
+func (d *DSN) String() string {
+	return fmt.Sprintf("%s://%s@%s%s/%s", d.Scheme, d.PublicKey, d.Host, d.Path, d.ProjectID)
+}
+
+// EnvelopeURL returns the URL that envelopes are sent to.
+func (d *DSN) EnvelopeURL() string {
+	return fmt.Sprintf("%s://%s%s/api/%s/envelope/", d.Scheme, d.Host, d.Path, d.ProjectID)
+}
+
+// AuthHeader returns the value of the X-Sentry-Auth header sent with envelopes.
+func (d *DSN) AuthHeader() string {
+	return fmt.Sprintf("Sentry sentry_version=7, sentry_client=go-errors/1.0, sentry_key=%s", d.PublicKey)
+}
+
+// envelopeHeader is the first line of an envelope.
+type envelopeHeader struct {
+	EventID string    `json:"event_id"`
+	SentAt  time.Time `json:"sent_at"`
+	DSN     string    `json:"dsn,omitempty"`
+}
+
+// itemHeader precedes each item in an envelope.
+type itemHeader struct {
+	Type   string `json:"type"`
+	Length int    `json:"length"`
+}
+
+// Envelope returns the given event as a Sentry envelope, which is how events are sent to Sentry.
+// The DSN may be nil, in which case it's left out of the envelope's header.
+func (e *Event) Envelope(dsn *DSN) ([]byte, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, errors.Wrap(err, "sentry: failed to encode event")
+	}
+
+	header := envelopeHeader{
+		EventID: e.EventID,
+		SentAt:  time.Now().UTC(),
+	}
+
+	if dsn != nil {
+		header.DSN = dsn.String()
+	}
+
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+
+	// Neither header can fail to encode, as they only contain strings, numbers, and times.
+	enc.Encode(header)
+	enc.Encode(itemHeader{Type: "event", Length: len(payload)})
+
+	buf.Write(payload)
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
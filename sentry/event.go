@@ -0,0 +1,284 @@
+// Package sentry encodes errors as Sentry events, and sends them to Sentry, without depending on a
+// Sentry SDK. Each error in a stack becomes an exception in the event, with the error's kind as its
+// type, and where it was created as its stack frame, so Sentry shows the whole chain of errors.
+// Events are grouped by the error's fingerprint (see errors.Fingerprint), rather than Sentry's own
+// grouping, so that the same error is grouped the same way everywhere.
+//
+// Example usage:
+//
+//    transport, err := sentry.NewTransport(os.Getenv("SENTRY_DSN"))
+//    if err != nil {
+//        // The DSN is invalid.
+//    }
+//
+//    transport.Environment = "production"
+//
+//    if err := doSomething(ctx); err != nil {
+//        transport.Capture(ctx, err)
+//    }
+//
+// Transport is also a report.Sink, so it can be used with a report.Reporter to send errors in the
+// background.
+package sentry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/report"
+)
+
+// Platform is the platform set on every event.
+const Platform = "go"
+
+// Levels of events, as understood by Sentry.
+const (
+	LevelDebug   = "debug"
+	LevelInfo    = "info"
+	LevelWarning = "warning"
+	LevelError   = "error"
+	LevelFatal   = "fatal"
+)
+
+// levels maps the severity of an error's kind to the level of its event.
+var levels = map[errors.Severity]string{
+	errors.SeverityInfo:     LevelInfo,
+	errors.SeverityWarning:  LevelWarning,
+	errors.SeverityError:    LevelError,
+	errors.SeverityCritical: LevelFatal,
+}
+
+// Event is a Sentry event, as sent to Sentry. Only the attributes used by this package are
+// included.
+type Event struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Platform    string                 `json:"platform"`
+	Level       string                 `json:"level"`
+	Release     string                 `json:"release,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	ServerName  string                 `json:"server_name,omitempty"`
+	Exception   *ExceptionList         `json:"exception,omitempty"`
+	Fingerprint []string               `json:"fingerprint,omitempty"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	Contexts    map[string]interface{} `json:"contexts,omitempty"`
+}
+
+// ExceptionList holds the exceptions in an event. Sentry expects the first error that occurred to
+// be first, so they're in the opposite order to Stack.
+type ExceptionList struct {
+	Values []Exception `json:"values"`
+}
+
+// Exception is a single error in an event.
+type Exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value,omitempty"`
+	Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+}
+
+// Stacktrace holds the frames of an exception.
+type Stacktrace struct {
+	Frames []Frame `json:"frames"`
+}
+
+// Frame is where an exception occurred.
+type Frame struct {
+	Function string `json:"function,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Lineno   int    `json:"lineno,omitempty"`
+	InApp    bool   `json:"in_app"`
+}
+
+// TraceContext is the "trace" context of an event, which links it to a distributed trace.
+type TraceContext struct {
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id,omitempty"`
+}
+
+// Encode returns an event for the given error, or nil if the error is nil.
+//
+// Each error in the stack (see errors.Stack) becomes an exception. Its type is its kind, or for an
+// error at the bottom of the stack that isn't an *errors.Error, its Go type. Its value is its
+// message, and its stack trace is the single frame where it was created. The event's level comes
+// from the severity of the error's kind (see errors.KindSeverity), its "extra" data is the error's
+// fields, and its fingerprint is the error's fingerprint. If the error has a reference ID (see
+// errors.ReferenceID), it's added as the "reference_id" tag, so that errors that users report can
+// be found in Sentry. Encode doesn't assign one, so call errors.ReferenceID first if you need it.
+func Encode(err error) *Event {
+	if err == nil {
+		return nil
+	}
+
+	stack := errors.Stack(err)
+
+	event := newEvent(stack, errors.Classify(err), errors.Fingerprint(err), stack[0].ReferenceID, errors.Fields(err))
+
+	for i, frame := range stack {
+		if frame.Kind == "" {
+			event.Exception.Values[len(stack)-1-i].Type = exceptionType(err, i)
+		}
+	}
+
+	if tc, ok := errors.TraceOf(err); ok {
+		event.Contexts = traceContexts(tc.TraceIDHex, tc.SpanIDHex)
+	}
+
+	return event
+}
+
+// EncodeEntry returns an event for the given entry, the same way as Encode does for an error, but
+// using the entry's kind, stack, fields, and other properties, rather than its error. This means it
+// also works for entries that don't have an error, e.g. entries read back from a report.Journal. As
+// the Go types of errors aren't kept in entries, exceptions without a kind have the type "error".
+// The event's timestamp is the time the entry was reported. Nil is returned for empty entries.
+func EncodeEntry(entry report.Entry) *Event {
+	stack := entry.Stack
+	if len(stack) == 0 {
+		if entry.Message == "" {
+			return nil
+		}
+
+		stack = []errors.StackFrame{{Message: entry.Message}}
+	}
+
+	event := newEvent(stack, errors.Kind(entry.Kind), entry.Fingerprint, entry.ReferenceID, entry.Fields)
+
+	if !entry.Time.IsZero() {
+		event.Timestamp = entry.Time.UTC()
+	}
+
+	if entry.TraceID != "" {
+		event.Contexts = traceContexts(entry.TraceID, entry.SpanID)
+	}
+
+	return event
+}
+
+// newEvent returns an event for an error with the given stack, kind, fingerprint, reference ID, and
+// fields.
+func newEvent(stack []errors.StackFrame, kind errors.Kind, fingerprint, referenceID string, fields map[string]interface{}) *Event {
+	event := &Event{
+		EventID:   newEventID(),
+		Timestamp: time.Now().UTC(),
+		Platform:  Platform,
+		Level:     LevelError,
+		Exception: &ExceptionList{Values: exceptions(stack)},
+		Tags:      map[string]string{},
+		Extra:     extra(fields),
+	}
+
+	if fingerprint != "" {
+		event.Fingerprint = []string{fingerprint}
+	}
+
+	if referenceID != "" {
+		event.Tags["reference_id"] = referenceID
+	}
+
+	if kind != "" {
+		event.Tags["kind"] = string(kind)
+	}
+
+	if level, ok := levels[errors.KindSeverity(kind)]; ok {
+		event.Level = level
+	}
+
+	return event
+}
+
+// traceContexts returns an event's contexts, with the "trace" context set to the given IDs.
+func traceContexts(traceID, spanID string) map[string]interface{} {
+	return map[string]interface{}{
+		"trace": TraceContext{
+			TraceID: traceID,
+			SpanID:  spanID,
+		},
+	}
+}
+
+// exceptions returns an exception for each of the given stack frames, with the first error that
+// occurred first. Exceptions for errors without a kind have the type "error".
+func exceptions(stack []errors.StackFrame) []Exception {
+	values := make([]Exception, len(stack))
+
+	for i, frame := range stack {
+		exception := Exception{
+			Type:  frame.Kind,
+			Value: frame.Message,
+		}
+
+		if frame.File != "" || frame.Caller != "" {
+			exception.Stacktrace = &Stacktrace{
+				Frames: []Frame{{
+					Function: frame.Caller,
+					Filename: frame.File,
+					Lineno:   frame.Line,
+					InApp:    true,
+				}},
+			}
+		}
+
+		if exception.Type == "" {
+			exception.Type = "error"
+		}
+
+		values[len(stack)-1-i] = exception
+	}
+
+	return values
+}
+
+// exceptionType returns the type of the exception for the error at the given depth of the given
+// error's stack, for errors without a kind. Errors that aren't an *errors.Error use their Go type.
+func exceptionType(err error, depth int) string {
+	for i := 0; i < depth; i++ {
+		e, ok := err.(*errors.Error)
+		if !ok {
+			break
+		}
+
+		err = e.Cause
+	}
+
+	if _, ok := err.(*errors.Error); ok {
+		return "error"
+	}
+
+	return fmt.Sprintf("%T", err)
+}
+
+// extra returns the given fields as an event's extra data. Values that can't be encoded as JSON
+// are replaced by their formatted value, so that one bad field doesn't stop the event being sent.
+func extra(fields map[string]interface{}) map[string]interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	extra := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if _, err := json.Marshal(v); err != nil {
+			v = fmt.Sprintf("%+v", v)
+		}
+
+		extra[k] = v
+	}
+
+	return extra
+}
+
+// newEventID returns a random event ID, as 32 lowercase hex characters.
+func newEventID() string {
+	var id [16]byte
+
+	// Reading from crypto/rand only fails if the operating system can't provide random numbers, in
+	// which case an all-zero ID is still better than not sending the event at all.
+	rand.Read(id[:])
+
+	return hex.EncodeToString(id[:])
+}
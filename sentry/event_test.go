@@ -0,0 +1,162 @@
+package sentry
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/icelolly/go-errors/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	t.Run("should return nil for nil errors", func(t *testing.T) {
+		assert.Nil(t, Encode(nil))
+	})
+
+	t.Run("should add an exception for each error in the stack", func(t *testing.T) {
+		inner := errors.New(kinds.NotFound, "user not found").WithField("user_id", 42)
+		err := errors.Wrap(errors.Wrap(inner, "lookup failed"), kinds.Internal)
+		ref := errors.ReferenceID(err)
+
+		event := Encode(err)
+		require.NotNil(t, event)
+		require.NotNil(t, event.Exception)
+
+		values := event.Exception.Values
+		require.Len(t, values, 3)
+
+		assert.Equal(t, string(kinds.NotFound), values[0].Type)
+		assert.Equal(t, "user not found", values[0].Value)
+		assert.Equal(t, "error", values[1].Type)
+		assert.Equal(t, "lookup failed", values[1].Value)
+		assert.Equal(t, string(kinds.Internal), values[2].Type)
+
+		for _, value := range values {
+			require.NotNil(t, value.Stacktrace)
+			require.Len(t, value.Stacktrace.Frames, 1)
+
+			frame := value.Stacktrace.Frames[0]
+			assert.True(t, strings.HasSuffix(frame.Filename, "event_test.go"))
+			assert.Contains(t, frame.Function, "TestEncode.func2")
+			assert.NotZero(t, frame.Lineno)
+			assert.True(t, frame.InApp)
+		}
+
+		assert.Equal(t, Platform, event.Platform)
+		assert.Equal(t, LevelError, event.Level)
+		assert.Equal(t, []string{errors.Fingerprint(err)}, event.Fingerprint)
+		assert.Equal(t, ref, event.Tags["reference_id"])
+		assert.Equal(t, string(kinds.Internal), event.Tags["kind"])
+		assert.Equal(t, map[string]interface{}{"user_id": 42}, event.Extra)
+		assert.Regexp(t, regexp.MustCompile("^[0-9a-f]{32}$"), event.EventID)
+		assert.False(t, event.Timestamp.IsZero())
+	})
+
+	t.Run("should not assign a reference ID", func(t *testing.T) {
+		err := errors.New("oops")
+
+		event := Encode(err)
+		assert.NotContains(t, event.Tags, "reference_id")
+		assert.Empty(t, errors.Stack(err)[0].ReferenceID)
+
+		event = Encode(stderrors.New("oops"))
+		assert.NotContains(t, event.Tags, "reference_id")
+	})
+
+	t.Run("should use the Go type of errors that aren't *errors.Error", func(t *testing.T) {
+		event := Encode(errors.Wrap(io.EOF, "read failed"))
+
+		values := event.Exception.Values
+		require.Len(t, values, 2)
+
+		assert.Equal(t, "*errors.errorString", values[0].Type)
+		assert.Equal(t, "EOF", values[0].Value)
+		assert.Nil(t, values[0].Stacktrace)
+	})
+
+	t.Run("should set the level from the kind's severity", func(t *testing.T) {
+		assert.Equal(t, LevelInfo, Encode(errors.New(kinds.NotFound)).Level)
+		assert.Equal(t, LevelWarning, Encode(errors.New(kinds.Unavailable)).Level)
+		assert.Equal(t, LevelFatal, Encode(errors.New(kinds.DataLoss)).Level)
+		assert.Equal(t, LevelError, Encode(errors.New("oops")).Level)
+	})
+
+	t.Run("should add the trace context", func(t *testing.T) {
+		tc := errors.TraceContext{
+			TraceIDHex: "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanIDHex:  "00f067aa0ba902b7",
+		}
+
+		ctx := errors.ContextWithTrace(context.Background(), tc)
+		event := Encode(errors.NewCtx(ctx, "oops"))
+
+		assert.Equal(t, TraceContext{TraceID: tc.TraceIDHex, SpanID: tc.SpanIDHex}, event.Contexts["trace"])
+	})
+
+	t.Run("should replace fields that can't be encoded", func(t *testing.T) {
+		event := Encode(errors.New("oops").WithField("fn", func() {}))
+
+		assert.IsType(t, "", event.Extra["fn"])
+	})
+}
+
+func TestEncodeEntry(t *testing.T) {
+	t.Run("should return nil for empty entries", func(t *testing.T) {
+		assert.Nil(t, EncodeEntry(report.Entry{}))
+	})
+
+	t.Run("should build the event from the entry, without its error", func(t *testing.T) {
+		reported := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+		entry := report.Entry{
+			Time:        reported,
+			Fingerprint: "abc123",
+			ReferenceID: "ref-1",
+			Kind:        string(kinds.NotFound),
+			Message:     "lookup failed: user not found",
+			TraceID:     "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanID:      "00f067aa0ba902b7",
+			Fields:      map[string]interface{}{"user_id": 42},
+			Stack: []errors.StackFrame{
+				{Message: "lookup failed", Caller: "users.Find", File: "users/find.go", Line: 12},
+				{Message: "user not found", Kind: string(kinds.NotFound), Caller: "users.query", File: "users/query.go", Line: 40},
+			},
+		}
+
+		event := EncodeEntry(entry)
+		require.NotNil(t, event)
+
+		values := event.Exception.Values
+		require.Len(t, values, 2)
+
+		assert.Equal(t, string(kinds.NotFound), values[0].Type)
+		assert.Equal(t, "user not found", values[0].Value)
+		assert.Equal(t, "users/query.go", values[0].Stacktrace.Frames[0].Filename)
+		assert.Equal(t, "error", values[1].Type)
+		assert.Equal(t, "lookup failed", values[1].Value)
+
+		assert.True(t, reported.Equal(event.Timestamp))
+		assert.Equal(t, LevelInfo, event.Level)
+		assert.Equal(t, []string{"abc123"}, event.Fingerprint)
+		assert.Equal(t, map[string]string{"reference_id": "ref-1", "kind": string(kinds.NotFound)}, event.Tags)
+		assert.Equal(t, map[string]interface{}{"user_id": 42}, event.Extra)
+		assert.Equal(t, TraceContext{TraceID: entry.TraceID, SpanID: entry.SpanID}, event.Contexts["trace"])
+	})
+
+	t.Run("should use the message of entries without a stack", func(t *testing.T) {
+		event := EncodeEntry(report.Entry{Message: "oops"})
+		require.NotNil(t, event)
+
+		require.Len(t, event.Exception.Values, 1)
+		assert.Equal(t, "oops", event.Exception.Values[0].Value)
+		assert.Empty(t, event.Tags)
+	})
+}
@@ -0,0 +1,152 @@
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/httperr"
+	"github.com/icelolly/go-errors/report"
+)
+
+// Transport sends events to Sentry, as envelopes posted to the project identified by its DSN. It
+// satisfies report.Sink, so that it can be used with a report.Reporter. Create one using
+// NewTransport, so that its DSN is checked straight away, though a Transport created as a struct
+// literal works too.
+type Transport struct {
+	// DSN identifies the Sentry project that events are sent to. If it's empty, events are
+	// silently discarded, which makes it easy to disable Sentry, e.g. when developing locally.
+	DSN string
+
+	// Release, Environment, and ServerName are set on events that don't already have them.
+	Release     string
+	Environment string
+	ServerName  string
+
+	// Client is used to send requests. If it's nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// The DSN is only parsed once, the first time it's needed, if NewTransport wasn't used.
+	dsnOnce   sync.Once
+	parsedDSN *DSN
+	dsnErr    error
+}
+
+// NewTransport returns a new Transport that sends events to the project identified by the given
+// DSN. The DSN is parsed once, here, and an error is returned if it's invalid. If the DSN is empty,
+// the Transport silently discards events.
+func NewTransport(dsn string) (*Transport, error) {
+	t := &Transport{DSN: dsn}
+
+	if dsn != "" {
+		if _, err := t.dsn(); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// dsn returns the Transport's parsed DSN, parsing it the first time it's called.
+func (t *Transport) dsn() (*DSN, error) {
+	t.dsnOnce.Do(func() {
+		t.parsedDSN, t.dsnErr = ParseDSN(t.DSN)
+	})
+
+	return t.parsedDSN, t.dsnErr
+}
+
+// Capture encodes the given error as an event (see Encode), and sends it. Nil errors are ignored.
+func (t *Transport) Capture(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return t.SendEvent(ctx, Encode(err))
+}
+
+// Send sends an event for each entry in the given batch (see EncodeEntry), so that Transport can be
+// used as a report.Sink. Events are built from the entries themselves rather than their errors, so
+// entries without an error (e.g. read back from a report.Journal) are sent too. Events are sent one
+// at a time, and the first error that occurs is returned once they've all been tried.
+func (t *Transport) Send(ctx context.Context, batch []report.Entry) error {
+	var first error
+
+	for _, entry := range batch {
+		event := EncodeEntry(entry)
+		if event == nil {
+			continue
+		}
+
+		if err := t.SendEvent(ctx, event); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// SendEvent sends the given event to Sentry. Responses with a status code of 400 or above are
+// returned as errors (see httperr.FromResponse), so e.g. Sentry's rate limiting can be detected
+// using errors.Is with kinds.ResourceExhausted.
+func (t *Transport) SendEvent(ctx context.Context, event *Event) error {
+	if t.DSN == "" || event == nil {
+		return nil
+	}
+
+	dsn, err := t.dsn()
+	if err != nil {
+		return err
+	}
+
+	if event.Release == "" {
+		event.Release = t.Release
+	}
+
+	if event.Environment == "" {
+		event.Environment = t.Environment
+	}
+
+	if event.ServerName == "" {
+		event.ServerName = t.ServerName
+	}
+
+	body, err := event.Envelope(dsn)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, dsn.EnvelopeURL(), bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "sentry: failed to create request")
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", EnvelopeContentType)
+	req.Header.Set("X-Sentry-Auth", dsn.AuthHeader())
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.WrapClassified(err, "sentry: request failed")
+	}
+
+	defer resp.Body.Close()
+
+	if err := httperr.FromResponse(resp); err != nil {
+		return errors.Wrap(err, "sentry: failed to send event").WithField("event_id", event.EventID)
+	}
+
+	// The body is read so that the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/icelolly/go-errors"
+	"github.com/icelolly/go-errors/kinds"
+	"github.com/icelolly/go-errors/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Transport must be usable with a report.Reporter.
+var _ report.Sink = &Transport{}
+
+// server is a fake Sentry server, which keeps the events sent to it.
+type server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	status  int
+	paths   []string
+	headers []http.Header
+	events  []Event
+}
+
+func newServer(status int) *server {
+	s := &server{status: status}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lines := bytes.Split(bytes.TrimSpace(body), []byte("\n"))
+
+		var event Event
+		if len(lines) == 3 {
+			json.Unmarshal(lines[2], &event)
+		}
+
+		s.mu.Lock()
+		s.paths = append(s.paths, r.URL.Path)
+		s.headers = append(s.headers, r.Header)
+		s.events = append(s.events, event)
+		s.mu.Unlock()
+
+		w.WriteHeader(s.status)
+	}))
+
+	return s
+}
+
+func (s *server) dsn() string {
+	return strings.Replace(s.URL, "http://", "http://public@", 1) + "/42"
+}
+
+func TestTransport_Capture(t *testing.T) {
+	t.Run("should post the event to the DSN", func(t *testing.T) {
+		srv := newServer(http.StatusOK)
+		defer srv.Close()
+
+		transport := &Transport{
+			DSN:         srv.dsn(),
+			Release:     "v1.0.0",
+			Environment: "test",
+			ServerName:  "host-1",
+		}
+
+		err := errors.New(kinds.NotFound, "not found")
+		require.NoError(t, transport.Capture(context.Background(), err))
+
+		require.Len(t, srv.events, 1)
+		assert.Equal(t, "/api/42/envelope/", srv.paths[0])
+		assert.Equal(t, EnvelopeContentType, srv.headers[0].Get("Content-Type"))
+		assert.Contains(t, srv.headers[0].Get("X-Sentry-Auth"), "sentry_key=public")
+
+		event := srv.events[0]
+		assert.Equal(t, "v1.0.0", event.Release)
+		assert.Equal(t, "test", event.Environment)
+		assert.Equal(t, "host-1", event.ServerName)
+		assert.Equal(t, []string{errors.Fingerprint(err)}, event.Fingerprint)
+	})
+
+	t.Run("should do nothing for nil errors, or without a DSN", func(t *testing.T) {
+		srv := newServer(http.StatusOK)
+		defer srv.Close()
+
+		assert.NoError(t, (&Transport{DSN: srv.dsn()}).Capture(context.Background(), nil))
+		assert.NoError(t, (&Transport{}).Capture(context.Background(), errors.New("oops")))
+
+		assert.Empty(t, srv.events)
+	})
+
+	t.Run("should return an error for error responses", func(t *testing.T) {
+		srv := newServer(http.StatusTooManyRequests)
+		defer srv.Close()
+
+		err := (&Transport{DSN: srv.dsn()}).Capture(context.Background(), errors.New("oops"))
+		assert.True(t, errors.Is(err, kinds.ResourceExhausted))
+	})
+
+	t.Run("should return an error for invalid DSNs", func(t *testing.T) {
+		transport := &Transport{DSN: "nope"}
+
+		err := transport.Capture(context.Background(), errors.New("oops"))
+		assert.True(t, errors.Is(err, kinds.InvalidArgument))
+
+		err = transport.Capture(context.Background(), errors.New("oops"))
+		assert.True(t, errors.Is(err, kinds.InvalidArgument))
+	})
+}
+
+func TestNewTransport(t *testing.T) {
+	t.Run("should parse the DSN", func(t *testing.T) {
+		srv := newServer(http.StatusOK)
+		defer srv.Close()
+
+		transport, err := NewTransport(srv.dsn())
+		require.NoError(t, err)
+
+		dsn, err := transport.dsn()
+		require.NoError(t, err)
+		assert.Equal(t, "public", dsn.PublicKey)
+
+		require.NoError(t, transport.Capture(context.Background(), errors.New("oops")))
+		assert.Len(t, srv.events, 1)
+	})
+
+	t.Run("should return an error for invalid DSNs", func(t *testing.T) {
+		transport, err := NewTransport("nope")
+		assert.Nil(t, transport)
+		assert.True(t, errors.Is(err, kinds.InvalidArgument))
+	})
+
+	t.Run("should allow an empty DSN", func(t *testing.T) {
+		transport, err := NewTransport("")
+		require.NoError(t, err)
+		assert.NoError(t, transport.Capture(context.Background(), errors.New("oops")))
+	})
+}
+
+func TestTransport_Send(t *testing.T) {
+	srv := newServer(http.StatusOK)
+	defer srv.Close()
+
+	reported := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// Entries read back from a journal don't have an error.
+	batch := []report.Entry{
+		{
+			Message: "first",
+			Stack:   []errors.StackFrame{{Message: "first"}},
+			Time:    reported,
+			Fields:  map[string]interface{}{"request_id": "abc"},
+			TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{Message: "second", Stack: []errors.StackFrame{{Message: "second"}}, Time: reported},
+		{},
+	}
+
+	require.NoError(t, (&Transport{DSN: srv.dsn()}).Send(context.Background(), batch))
+
+	require.Len(t, srv.events, 2)
+
+	assert.True(t, reported.Equal(srv.events[0].Timestamp))
+	assert.Equal(t, map[string]interface{}{"request_id": "abc"}, srv.events[0].Extra)
+	assert.Equal(t, map[string]interface{}{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"}, srv.events[0].Contexts["trace"])
+	assert.Equal(t, "second", srv.events[1].Exception.Values[0].Value)
+}
@@ -0,0 +1,144 @@
+package errors
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// sourceContextLines is the number of lines shown either side of the line an error occurred on when
+// source snippets are shown.
+const sourceContextLines = 2
+
+// developmentMode is accessed atomically, as it may be toggled whilst errors are being formatted.
+var developmentMode int32
+
+// SetDevelopmentMode enables or disables development mode. In development mode, the verbose (%+v)
+// format and Printer include a snippet of source code around the line each error occurred on, and
+// the httperr package responds to browsers with the page rendered by FprintHTML. This means reading
+// source files from disk, and showing them to whoever made the request, so it should not be enabled
+// in production.
+func SetDevelopmentMode(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+
+	atomic.StoreInt32(&developmentMode, v)
+}
+
+// DevelopmentMode reports whether development mode is enabled.
+func DevelopmentMode() bool {
+	return atomic.LoadInt32(&developmentMode) == 1
+}
+
+// sourceLine is a single line of source code, as shown in a snippet.
+type sourceLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+// sourceCache holds the lines of every source file that has been read so far. Files that could not
+// be read are stored as nil, so that we don't keep trying to read them.
+var sourceCache = struct {
+	sync.Mutex
+	files map[string][]string
+}{
+	files: make(map[string][]string),
+}
+
+// sourceSnippet returns the lines of source code surrounding the given line in the given file. If
+// the source isn't available (e.g. in a production container, or with a binary built using
+// -trimpath), nil is returned.
+func sourceSnippet(file string, line int) []sourceLine {
+	if file == "" || line <= 0 {
+		return nil
+	}
+
+	lines := sourceLines(file)
+	if line > len(lines) {
+		return nil
+	}
+
+	start := line - sourceContextLines
+	if start < 1 {
+		start = 1
+	}
+
+	end := line + sourceContextLines
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	snippet := make([]sourceLine, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		snippet = append(snippet, sourceLine{
+			Number:  i,
+			Text:    strings.Replace(lines[i-1], "\t", "    ", -1),
+			Current: i == line,
+		})
+	}
+
+	return snippet
+}
+
+// sourceLines returns the lines in the given file, reading it from disk the first time it's asked
+// for, and from the cache thereafter.
+func sourceLines(file string) []string {
+	sourceCache.Lock()
+	defer sourceCache.Unlock()
+
+	if lines, ok := sourceCache.files[file]; ok {
+		return lines
+	}
+
+	var lines []string
+
+	f, err := os.Open(file)
+	if err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+
+		if scanner.Err() != nil {
+			lines = nil
+		}
+
+		f.Close()
+	}
+
+	sourceCache.files[file] = lines
+
+	return lines
+}
+
+// writeSnippet writes a snippet of source code to the given buffer, with the current line marked,
+// and each line indented by the given prefix. The given paint function is used to style lines, so
+// that a Printer can highlight the current line.
+func writeSnippet(buf *bytes.Buffer, snippet []sourceLine, prefix string, paint func(codes, s string) string) {
+	width := len(strconv.Itoa(snippet[len(snippet)-1].Number))
+
+	for _, sl := range snippet {
+		num := strconv.Itoa(sl.Number)
+		num = strings.Repeat(" ", width-len(num)) + num
+
+		buf.WriteString(prefix)
+		if sl.Current {
+			buf.WriteString(paint(ansiBold, "> "+num+" | "+sl.Text))
+		} else {
+			buf.WriteString(paint(ansiDim, "  "+num+" | "+sl.Text))
+		}
+		buf.WriteString("\n")
+	}
+}
+
+// noPaint is a paint function that leaves strings as they are.
+func noPaint(_, s string) string {
+	return s
+}
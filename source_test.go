@@ -0,0 +1,78 @@
+package errors
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetDevelopmentMode(t *testing.T) {
+	defer SetDevelopmentMode(false)
+
+	t.Run("should be disabled by default", func(t *testing.T) {
+		assert.False(t, DevelopmentMode())
+	})
+
+	t.Run("should include source snippets in verbose output when enabled", func(t *testing.T) {
+		SetDevelopmentMode(true)
+		defer SetDevelopmentMode(false)
+
+		err := New("oops") // This line should be shown.
+
+		out := fmt.Sprintf("%+v", err)
+		assert.Contains(t, out, fmt.Sprintf("> %d | ", err.line))
+		assert.Contains(t, out, "// This line should be shown.")
+	})
+
+	t.Run("should not include source snippets in verbose output when disabled", func(t *testing.T) {
+		err := New("oops") // This line should not be shown.
+
+		assert.NotContains(t, fmt.Sprintf("%+v", err), "// This line should not be shown.")
+	})
+
+	t.Run("should include source snippets in printer output when enabled", func(t *testing.T) {
+		SetDevelopmentMode(true)
+		defer SetDevelopmentMode(false)
+
+		buf := bytes.Buffer{}
+
+		err := New("oops") // This line should be printed.
+		require.NoError(t, NewPrinter(&buf).Print(err))
+
+		assert.Contains(t, buf.String(), "// This line should be printed.")
+	})
+}
+
+func TestSourceSnippet(t *testing.T) {
+	t.Run("should return the surrounding lines", func(t *testing.T) {
+		snippet := sourceSnippet("source_test.go", 5)
+		require.Len(t, snippet, 2*sourceContextLines+1)
+
+		assert.Equal(t, 3, snippet[0].Number)
+		assert.Equal(t, `import (`, snippet[0].Text)
+		assert.True(t, snippet[sourceContextLines].Current)
+		assert.False(t, snippet[0].Current)
+	})
+
+	t.Run("should truncate the snippet at the start of the file", func(t *testing.T) {
+		snippet := sourceSnippet("source_test.go", 1)
+		require.Len(t, snippet, sourceContextLines+1)
+
+		assert.Equal(t, "package errors", snippet[0].Text)
+	})
+
+	t.Run("should return nil if the file doesn't exist", func(t *testing.T) {
+		assert.Nil(t, sourceSnippet("/does/not/exist.go", 10))
+	})
+
+	t.Run("should return nil if the line is out of range", func(t *testing.T) {
+		assert.Nil(t, sourceSnippet("source_test.go", 100000))
+	})
+
+	t.Run("should return nil for frames without file information", func(t *testing.T) {
+		assert.Nil(t, sourceSnippet("", 0))
+	})
+}
@@ -0,0 +1,104 @@
+package errors
+
+import "fmt"
+
+// MessageTemplate is a message that is built from a format string and arguments, like those given
+// to fmt.Sprintf. Unlike building a message with fmt.Sprintf before passing it to New, the format
+// string is kept separate from the arguments, so errors from the same place can still be grouped
+// even though their messages differ (see Fingerprint). Create one using Fmt, and pass it to New or
+// Wrap, or use Newf or Wrapf.
+type MessageTemplate struct {
+	// Format is a format string, as accepted by fmt.Sprintf.
+	Format string
+
+	// Args are the arguments for the format string. Any NamedArgs are replaced by their value when
+	// the message is rendered.
+	Args []interface{}
+}
+
+// NamedArg is an argument for a MessageTemplate that is also added to the error as a field. Create
+// one using Arg.
+type NamedArg struct {
+	Name  string
+	Value interface{}
+}
+
+// Fmt returns a new MessageTemplate for the given format string and arguments.
+//
+// Example usage:
+//
+//    err := errors.New(ErrNotFound, errors.Fmt("user %q not found", errors.Arg("username", name)))
+//
+func Fmt(format string, args ...interface{}) *MessageTemplate {
+	return &MessageTemplate{
+		Format: format,
+		Args:   args,
+	}
+}
+
+// Arg returns a NamedArg, which can be used as an argument to Fmt, Newf, or Wrapf. Its value is
+// used when rendering the message, and it's added to the error as a field with the given name.
+func Arg(name string, value interface{}) NamedArg {
+	return NamedArg{
+		Name:  name,
+		Value: value,
+	}
+}
+
+// Newf returns a new error with a message built from the given format string and arguments. The
+// message is only rendered when it's needed, and the format string is available for grouping
+// errors. Any arguments created using Arg are also added to the error as fields.
+func Newf(format string, args ...interface{}) *Error {
+	err := newError(Fmt(format, args...))
+
+	updateCaller(err)
+
+	return err
+}
+
+// Wrapf works the same way as Newf, but wraps the given cause. If the given cause is nil, Wrapf
+// will return nil, just like Wrap.
+func Wrapf(cause error, format string, args ...interface{}) *Error {
+	if cause == nil {
+		return nil
+	}
+
+	err := newError(Fmt(format, args...), cause)
+
+	updateCaller(err)
+
+	return err
+}
+
+// Template returns the format string used to build this error's message, or an empty string if
+// the message wasn't built from a MessageTemplate.
+func (e *Error) Template() string {
+	if e.template == nil {
+		return ""
+	}
+
+	return e.template.Format
+}
+
+// render returns the message built from the template.
+func (t *MessageTemplate) render() string {
+	args := make([]interface{}, len(t.Args))
+	for i, arg := range t.Args {
+		if na, ok := arg.(NamedArg); ok {
+			arg = na.Value
+		}
+
+		args[i] = arg
+	}
+
+	return fmt.Sprintf(t.Format, args...)
+}
+
+// namedArgs calls fn for each NamedArg in the template's arguments.
+func (t *MessageTemplate) namedArgs(fn func(na NamedArg)) {
+	for _, arg := range t.Args {
+		if na, ok := arg.(NamedArg); ok {
+			fn(na)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package errors
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewf(t *testing.T) {
+	t.Run("should render the message lazily", func(t *testing.T) {
+		err := Newf("user %q not found", "laureen")
+
+		assert.Equal(t, "", err.Message)
+		assert.Equal(t, `user "laureen" not found`, Message(err))
+		assert.Equal(t, `[go-errors.TestNewf.func1]: user "laureen" not found`, err.Error())
+	})
+
+	t.Run("should keep the template", func(t *testing.T) {
+		err := Newf("user %q not found", "laureen")
+
+		assert.Equal(t, "user %q not found", err.Template())
+		assert.Equal(t, "user %q not found", Stack(err)[0].Template)
+		assert.Equal(t, `user "laureen" not found`, Stack(err)[0].Message)
+	})
+
+	t.Run("should add named arguments as fields", func(t *testing.T) {
+		err := Newf("user %q not found in %s", Arg("username", "laureen"), "database")
+
+		assert.Equal(t, `user "laureen" not found in database`, Message(err))
+		assert.Equal(t, map[string]interface{}{"username": "laureen"}, err.Fields)
+	})
+
+	t.Run("should record the caller", func(t *testing.T) {
+		assert.Equal(t, "go-errors.TestNewf.func4", Stack(Newf("oops"))[0].Caller)
+	})
+}
+
+func TestWrapf(t *testing.T) {
+	t.Run("should return nil if the given cause is nil", func(t *testing.T) {
+		assert.Nil(t, Wrapf(nil, "oops"))
+	})
+
+	t.Run("should wrap the given cause", func(t *testing.T) {
+		err := Wrapf(io.EOF, "failed to read %d bytes", 10)
+
+		assert.Equal(t, io.EOF, err.Cause)
+		assert.Equal(t, "failed to read 10 bytes", Message(err))
+		assert.Equal(t, "go-errors.TestWrapf.func2", Stack(err)[0].Caller)
+	})
+}
+
+func TestFmt(t *testing.T) {
+	t.Run("should be usable with New", func(t *testing.T) {
+		err := New(ErrKindTest, Fmt("user %v not found", Arg("user_id", 123)))
+
+		assert.Equal(t, ErrKindTest, err.Kind)
+		assert.Equal(t, "user 123 not found", Message(err))
+		assert.Equal(t, 123, err.Fields["user_id"])
+	})
+
+	t.Run("should not lose named arguments when fields are given", func(t *testing.T) {
+		err := New(Fmt("user %v not found", Arg("user_id", 123)), map[string]interface{}{"foo": "bar"})
+
+		require.Len(t, err.Fields, 2)
+		assert.Equal(t, 123, err.Fields["user_id"])
+	})
+
+	t.Run("should not change the given fields", func(t *testing.T) {
+		fields := map[string]interface{}{"service": "users"}
+
+		err := New(Fmt("user %v not found", Arg("user_id", 123)), fields)
+
+		assert.Equal(t, map[string]interface{}{"service": "users"}, fields)
+		assert.Equal(t, map[string]interface{}{"service": "users", "user_id": 123}, err.Fields)
+	})
+}
+
+func TestFingerprint_Templates(t *testing.T) {
+	newUserError := func(id int) error {
+		return Newf("user %d not found", id)
+	}
+
+	t.Run("should use templates rather than messages", func(t *testing.T) {
+		err1 := newUserError(1)
+		err2 := newUserError(2)
+
+		assert.Equal(t, Fingerprint(err1, FingerprintMessages), Fingerprint(err2, FingerprintMessages))
+	})
+}
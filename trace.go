@@ -0,0 +1,258 @@
+package errors
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// traceparentVersion is the version of the W3C Trace Context traceparent header that's produced.
+const traceparentVersion = "00"
+
+// SpanContext identifies the current span of a distributed trace. It's deliberately minimal, so
+// that it's easy to adapt whichever tracing library is in use (see SetSpanContextFunc).
+type SpanContext interface {
+	// TraceID returns the trace ID, as 32 lowercase hex characters.
+	TraceID() string
+
+	// SpanID returns the span ID, as 16 lowercase hex characters.
+	SpanID() string
+}
+
+// spanContextFunc is used to get the current span from a context.Context, if one has been set.
+var spanContextFunc = struct {
+	sync.RWMutex
+	fn func(ctx context.Context) SpanContext
+}{}
+
+// SetSpanContextFunc sets the function used to get the current span from a context.Context, e.g.
+// using a tracing library. If it returns nil, or an invalid span, the trace context stored using
+// ContextWithTrace is used instead, if there is one.
+//
+// Example usage with OpenTelemetry:
+//
+//    errors.SetSpanContextFunc(func(ctx context.Context) errors.SpanContext {
+//        sc := trace.SpanContextFromContext(ctx)
+//        return errors.TraceContext{
+//            TraceIDHex: sc.TraceID().String(),
+//            SpanIDHex:  sc.SpanID().String(),
+//        }
+//    })
+//
+func SetSpanContextFunc(fn func(ctx context.Context) SpanContext) {
+	spanContextFunc.Lock()
+	spanContextFunc.fn = fn
+	spanContextFunc.Unlock()
+}
+
+// TraceContext identifies a span of a distributed trace, as carried by the W3C Trace Context
+// traceparent header (see https://www.w3.org/TR/trace-context/). It implements SpanContext.
+type TraceContext struct {
+	// TraceIDHex is the trace ID, as 32 lowercase hex characters.
+	TraceIDHex string
+
+	// SpanIDHex is the span ID, as 16 lowercase hex characters. When parsed from an incoming
+	// request's traceparent header, this is the ID of the caller's span.
+	SpanIDHex string
+
+	// Flags are the trace flags, e.g. 0x01 if the trace is sampled.
+	Flags byte
+}
+
+// ErrInvalidTraceparent is returned by ParseTraceparent for values that aren't valid. It's a plain
+// error, rather than an *Error, as traceparent headers usually come from clients, and a bad header
+// isn't worth a stack, or calling the hook set by SetCreateHook (e.g. to count it in metrics).
+var ErrInvalidTraceparent error = traceparentError{}
+
+// traceparentError is the type of ErrInvalidTraceparent.
+type traceparentError struct{}
+
+// Error returns the error's message.
+func (traceparentError) Error() string {
+	return "errors: invalid traceparent"
+}
+
+// ParseTraceparent parses the given W3C Trace Context traceparent header value. Values with a
+// version newer than the one this package produces are accepted, as long as they start with the
+// fields it knows about, as the specification requires. If the value isn't valid,
+// ErrInvalidTraceparent is returned.
+func ParseTraceparent(s string) (TraceContext, error) {
+	s = strings.TrimSpace(s)
+
+	parts := strings.SplitN(s, "-", 5)
+	if len(parts) < 4 {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	if !isHex(version, 2) || version == "ff" {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+
+	if version == traceparentVersion && len(parts) > 4 {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+
+	if !isHex(flags, 2) {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+
+	fb, _ := hex.DecodeString(flags)
+
+	tc := TraceContext{
+		TraceIDHex: traceID,
+		SpanIDHex:  spanID,
+		Flags:      fb[0],
+	}
+
+	if !tc.Valid() {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+
+	return tc, nil
+}
+
+// Child returns a trace context for a new span in the same trace, i.e. with the same trace ID and
+// flags, and a new random span ID. This is what a service that receives a trace context should use
+// for its own work, so that the caller's span ID isn't passed on to other services as its own.
+func (tc TraceContext) Child() TraceContext {
+	tc.SpanIDHex = newSpanID()
+	return tc
+}
+
+// TraceID returns the trace ID.
+func (tc TraceContext) TraceID() string {
+	return tc.TraceIDHex
+}
+
+// SpanID returns the span ID.
+func (tc TraceContext) SpanID() string {
+	return tc.SpanIDHex
+}
+
+// Valid reports whether the trace context has valid trace and span IDs.
+func (tc TraceContext) Valid() bool {
+	return isHex(tc.TraceIDHex, 32) && !isZero(tc.TraceIDHex) &&
+		isHex(tc.SpanIDHex, 16) && !isZero(tc.SpanIDHex)
+}
+
+// String returns the trace context formatted as a traceparent header value, or an empty string if
+// the trace context isn't valid.
+func (tc TraceContext) String() string {
+	if !tc.Valid() {
+		return ""
+	}
+
+	return traceparentVersion + "-" + tc.TraceIDHex + "-" + tc.SpanIDHex + "-" + hex.EncodeToString([]byte{tc.Flags})
+}
+
+// traceContextKey is the key that a TraceContext is stored under in a context.Context.
+type traceContextKey struct{}
+
+// ContextWithTrace returns a copy of the given context with the given trace context stored in it.
+// Errors created with the returned context (e.g. using NewCtx) will have the trace context's IDs.
+// This is mostly useful for middleware that parses the traceparent header of incoming requests.
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceFromContext returns the trace context for the given context, and reports whether there is a
+// valid one. The function set using SetSpanContextFunc is tried first, followed by the trace context
+// stored using ContextWithTrace.
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	if ctx == nil {
+		return TraceContext{}, false
+	}
+
+	spanContextFunc.RLock()
+	fn := spanContextFunc.fn
+	spanContextFunc.RUnlock()
+
+	if fn != nil {
+		if sc := fn(ctx); sc != nil {
+			tc := TraceContext{
+				TraceIDHex: sc.TraceID(),
+				SpanIDHex:  sc.SpanID(),
+			}
+
+			if stc, ok := sc.(TraceContext); ok {
+				tc.Flags = stc.Flags
+			}
+
+			if tc.Valid() {
+				return tc, true
+			}
+		}
+	}
+
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	if !ok || !tc.Valid() {
+		return TraceContext{}, false
+	}
+
+	return tc, true
+}
+
+// TraceOf returns the trace context of the first error in the given error's stack that has one,
+// and reports whether one was found.
+func TraceOf(err error) (TraceContext, bool) {
+	for err != nil {
+		e, ok := err.(*Error)
+		if !ok {
+			break
+		}
+
+		if e.trace.Valid() {
+			return e.trace, true
+		}
+
+		err = e.Cause
+	}
+
+	return TraceContext{}, false
+}
+
+// newSpanID returns a new random span ID, as 16 lowercase hex characters.
+func newSpanID() string {
+	var b [8]byte
+
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			// If we can't get random bytes, the best we can do is fall back to the clock.
+			binary.BigEndian.PutUint64(b[:], uint64(time.Now().UnixNano()))
+		}
+
+		// An ID of all zeros is invalid, so in the unlikely event of getting one, try again.
+		if id := hex.EncodeToString(b[:]); !isZero(id) {
+			return id
+		}
+	}
+}
+
+// isHex reports whether the given string is made up of the given number of lowercase hex
+// characters.
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isZero reports whether the given hex string is made up only of zeros, which the W3C Trace Context
+// specification forbids for IDs.
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
@@ -0,0 +1,167 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	t.Run("should parse valid values", func(t *testing.T) {
+		tc, err := ParseTraceparent("00-" + testTraceID + "-" + testSpanID + "-01")
+		require.NoError(t, err)
+
+		assert.Equal(t, TraceContext{TraceIDHex: testTraceID, SpanIDHex: testSpanID, Flags: 0x01}, tc)
+	})
+
+	t.Run("should accept future versions with extra fields", func(t *testing.T) {
+		tc, err := ParseTraceparent("cc-" + testTraceID + "-" + testSpanID + "-00-what-the-future-holds")
+		require.NoError(t, err)
+
+		assert.Equal(t, testTraceID, tc.TraceID())
+		assert.Equal(t, testSpanID, tc.SpanID())
+	})
+
+	t.Run("should reject invalid values", func(t *testing.T) {
+		invalid := []string{
+			"",
+			"00-" + testTraceID + "-" + testSpanID,
+			"ff-" + testTraceID + "-" + testSpanID + "-01",
+			"00-" + testTraceID + "-" + testSpanID + "-01-extra",
+			"00-00000000000000000000000000000000-" + testSpanID + "-01",
+			"00-" + testTraceID + "-0000000000000000-01",
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-" + testSpanID + "-01",
+			"00-" + testTraceID + "-" + testSpanID + "-zz",
+			"00-" + testTraceID[:31] + "-" + testSpanID + "-01",
+		}
+
+		for _, s := range invalid {
+			_, err := ParseTraceparent(s)
+			assert.Equal(t, ErrInvalidTraceparent, err, "traceparent: %q", s)
+		}
+	})
+
+	t.Run("should not call the create hook for invalid values", func(t *testing.T) {
+		var calls int
+		SetCreateHook(func(err *Error) { calls++ })
+		defer SetCreateHook(nil)
+
+		_, err := ParseTraceparent("nope")
+		assert.Error(t, err)
+		assert.Equal(t, 0, calls)
+	})
+}
+
+func TestTraceContext_Child(t *testing.T) {
+	tc := TraceContext{TraceIDHex: testTraceID, SpanIDHex: testSpanID, Flags: 0x01}
+
+	child := tc.Child()
+	assert.True(t, child.Valid())
+	assert.Equal(t, testTraceID, child.TraceIDHex)
+	assert.Equal(t, byte(0x01), child.Flags)
+	assert.NotEqual(t, testSpanID, child.SpanIDHex)
+	assert.NotEqual(t, child.SpanIDHex, tc.Child().SpanIDHex)
+}
+
+func TestTraceContext_String(t *testing.T) {
+	tc := TraceContext{TraceIDHex: testTraceID, SpanIDHex: testSpanID, Flags: 0x01}
+	assert.Equal(t, "00-"+testTraceID+"-"+testSpanID+"-01", tc.String())
+
+	assert.Equal(t, "", TraceContext{}.String())
+}
+
+func TestTraceFromContext(t *testing.T) {
+	tc := TraceContext{TraceIDHex: testTraceID, SpanIDHex: testSpanID}
+
+	t.Run("should return the stored trace context", func(t *testing.T) {
+		actual, ok := TraceFromContext(ContextWithTrace(context.Background(), tc))
+
+		assert.True(t, ok)
+		assert.Equal(t, tc, actual)
+	})
+
+	t.Run("should return false if there isn't one", func(t *testing.T) {
+		_, ok := TraceFromContext(context.Background())
+		assert.False(t, ok)
+
+		_, ok = TraceFromContext(ContextWithTrace(context.Background(), TraceContext{}))
+		assert.False(t, ok)
+	})
+
+	t.Run("should prefer the span context func", func(t *testing.T) {
+		other := TraceContext{TraceIDHex: "0af7651916cd43dd8448eb211c80319c", SpanIDHex: "b7ad6b7169203331"}
+
+		SetSpanContextFunc(func(ctx context.Context) SpanContext {
+			return testSpan{trace: other.TraceIDHex, span: other.SpanIDHex}
+		})
+		defer SetSpanContextFunc(nil)
+
+		actual, ok := TraceFromContext(ContextWithTrace(context.Background(), tc))
+
+		assert.True(t, ok)
+		assert.Equal(t, other, actual)
+	})
+
+	t.Run("should fall back if the span context func has no span", func(t *testing.T) {
+		SetSpanContextFunc(func(ctx context.Context) SpanContext {
+			return nil
+		})
+		defer SetSpanContextFunc(nil)
+
+		actual, ok := TraceFromContext(ContextWithTrace(context.Background(), tc))
+
+		assert.True(t, ok)
+		assert.Equal(t, tc, actual)
+	})
+}
+
+func TestTraceOf(t *testing.T) {
+	tc := TraceContext{TraceIDHex: testTraceID, SpanIDHex: testSpanID}
+	ctx := ContextWithTrace(context.Background(), tc)
+
+	t.Run("should capture the trace context when an error is created", func(t *testing.T) {
+		err := Wrap(NewCtx(ctx, "oops"), "wrapped")
+
+		actual, ok := TraceOf(err)
+		assert.True(t, ok)
+		assert.Equal(t, tc, actual)
+	})
+
+	t.Run("should return false if no error has a trace context", func(t *testing.T) {
+		_, ok := TraceOf(Wrap(New("oops")))
+		assert.False(t, ok)
+
+		_, ok = TraceOf(nil)
+		assert.False(t, ok)
+	})
+
+	t.Run("should be included in the stack", func(t *testing.T) {
+		err := Wrap(NewCtx(ctx, "oops"), "wrapped")
+
+		stack := Stack(err)
+		require.Len(t, stack, 2)
+		assert.Empty(t, stack[0].TraceID)
+		assert.Equal(t, testTraceID, stack[1].TraceID)
+		assert.Equal(t, testSpanID, stack[1].SpanID)
+
+		bs, jerr := json.Marshal(stack[1])
+		require.NoError(t, jerr)
+		assert.Contains(t, string(bs), `"trace_id":"`+testTraceID+`","span_id":"`+testSpanID+`"`)
+	})
+}
+
+// testSpan is a SpanContext, like one that might be provided by a tracing library.
+type testSpan struct {
+	trace, span string
+}
+
+func (s testSpan) TraceID() string { return s.trace }
+func (s testSpan) SpanID() string  { return s.span }
@@ -1,14 +1,17 @@
 package errors
 
 import (
+	"bytes"
 	"fmt"
 	"sort"
+	"strconv"
 )
 
 // Fatal will panic if given a non-nil error. If the given error is an *Error, the output format of
 // the panic will be slightly different, so as to include as much relevant information as possible
 // in an easy format for operators to digest. If a regular error is given, it will simply be passed
-// to panic as normal.
+// to panic as normal. Nothing else is written anywhere, as the panic may be recovered (e.g. by
+// httperr), so the panic value is plain text. Use Fprint first if you want coloured output.
 func Fatal(err error) {
 	if err == nil {
 		return
@@ -31,7 +34,13 @@ func Fatal(err error) {
 		}
 	}
 
-	panic(fmt.Sprintf("fatal error: %s\n\n%+v", Message(wrapped), wrapped))
+	buf := bytes.Buffer{}
+	buf.WriteString("fatal error: ")
+	buf.WriteString(Message(wrapped))
+	buf.WriteString("\n\n")
+	fmt.Fprintf(&buf, "%+v", wrapped)
+
+	panic(buf.String())
 }
 
 // Fields returns all fields from all errors in a stack of errors, recursively checking for fields
@@ -107,7 +116,9 @@ func FieldsSlice(err error) []interface{} {
 // Is reports whether the err is an *Error of the given kind/value. If the given kind is of type Kind/string, it will be
 // checked against the error's Kind. If the given kind is of any other type, it will be checked against the error's
 // cause. This is done recursively until a matching error is found. Calling Is with multiple kinds reports whether the
-// error is one of the given kind/values, not all of.
+// error is one of the given kind/values, not all of. An error is also of any Kind that its Kind was derived from (see
+// KindInfo.Parent). Errors that aren't an *Error are unwrapped in the same way as Classify unwraps them, so an *Error
+// wrapped by another package's error (e.g. a *url.Error) is still found.
 func Is(err error, kind ...interface{}) bool {
 	if err == nil {
 		return false
@@ -115,13 +126,19 @@ func Is(err error, kind ...interface{}) bool {
 
 	e, ok := err.(*Error)
 	if !ok {
-		return false
+		// Errors from other packages may wrap an *Error, e.g. http.Client wraps errors returned by an
+		// http.RoundTripper in a *url.Error, so keep looking through them.
+		return Is(unwrapCause(err), kind...)
 	}
 
 	for _, k := range kind {
 		switch val := k.(type) {
-		case Kind, string:
-			if e.Kind == val {
+		case Kind:
+			if isKindOf(e.Kind, val) {
+				return true
+			}
+		case string:
+			if isKindOf(e.Kind, Kind(val)) {
 				return true
 			}
 		default:
@@ -139,33 +156,82 @@ func Is(err error, kind ...interface{}) bool {
 }
 
 // Message returns what is supposed to be a human-readable error message. It is designed to not leak
-// internal implementation details (unlike calling *Error.Error()). If the given error is not an
-// *Error, then a generic message will be returned. If the given error is nil, then an empty string
-// will be returned.
+// internal implementation details (unlike calling *Error.Error()). If no error in the stack has a
+// message, the default message registered for the first Kind in the stack that has one is returned
+// (see RegisterKind). Failing that, or if the given error is not an *Error, a generic message will
+// be returned (see SetDefaultMessage). Errors with a LocalMessage are looked up in the Catalog using
+// its default language (see MessageFor). If the given error is nil, then an empty string will be
+// returned. If a reference format has been set (see SetReferenceFormat), the error's reference ID
+// will be added to the message.
 func Message(err error) string {
 	if err == nil {
 		return ""
 	}
 
-	e, ok := err.(*Error)
-	if ok && e.Message != "" {
-		return e.Message
-	} else if ok && e.Cause != nil {
-		return Message(e.Cause)
+	return withReference(err, message(err, ""))
+}
+
+// message returns the human-readable message for the given error in the given language, checking
+// each error in the stack until one with a message is found.
+func message(err error, lang string) string {
+	var kindMessage string
+
+	for err != nil {
+		e, ok := err.(*Error)
+		if !ok {
+			break
+		}
+
+		if e.localMessage != nil {
+			if msg, ok := localize(e.localMessage, lang); ok {
+				return msg
+			}
+		}
+
+		if msg := e.message(); msg != "" {
+			return msg
+		}
+
+		if kindMessage == "" && e.Kind != "" {
+			if info, ok := LookupKind(e.Kind); ok {
+				kindMessage = info.Message
+			}
+		}
+
+		err = e.Cause
+	}
+
+	if kindMessage != "" {
+		return kindMessage
 	}
 
-	return "An internal error has occurred. Please contact technical support."
+	return fallbackMessage()
 }
 
 // StackFrame represents a single error in a stack of errors. All fields could be empty, because we
-// may even be dealing with a regular error.
+// may even be dealing with a regular error. URL is only set if source links are enabled (see
+// SetSourceURLTemplate).
 type StackFrame struct {
 	Kind    string                 `json:"kind,omitempty"`
 	Message string                 `json:"message,omitempty"`
 	Caller  string                 `json:"caller,omitempty"`
 	File    string                 `json:"file,omitempty"`
 	Line    int                    `json:"line,omitempty"`
+	URL     string                 `json:"url,omitempty"`
 	Fields  map[string]interface{} `json:"fields,omitempty"`
+
+	// Template is the format string used to build Message, if it was built using a template (see
+	// Newf). It's useful for grouping errors, as it doesn't contain any variable data.
+	Template string `json:"template,omitempty"`
+
+	// ReferenceID is only set on the first frame in a stack, and only if the error has already been
+	// assigned one (see ReferenceID).
+	ReferenceID string `json:"reference_id,omitempty"`
+
+	// TraceID and SpanID identify the distributed trace and span that the error occurred in, if it
+	// was created with a context that has them (see ContextWithTrace, and SetSpanContextFunc).
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
 }
 
 // Stack produces a slice of StackFrame structs that can easily be encoded to JSON. The main
@@ -176,7 +242,21 @@ type StackFrame struct {
 // because of the nature of slices, this implementation is considerably faster than using a
 // recursive solution (i.e. this only has 1 allocation, whereas a recursive solution may have 1 or 2
 // allocations per stack frame).
+//
+// Stack doesn't change the given error, so a reference ID is only included if the error already has
+// one. Call ReferenceID first if the stack should always have one.
 func Stack(err error) []StackFrame {
+	frames := stack(err, currentPathStyle(), currentFullCallerNames())
+	if len(frames) > 0 {
+		frames[0].ReferenceID = referenceID(err, false)
+	}
+
+	return frames
+}
+
+// stack produces a slice of StackFrame structs, the same as Stack, but with file paths normalised
+// using the given PathStyle, and full or trimmed caller names, rather than the current settings.
+func stack(err error, style PathStyle, fullCallers bool) []StackFrame {
 	if err == nil {
 		return []StackFrame{}
 	}
@@ -215,12 +295,16 @@ func Stack(err error) []StackFrame {
 
 		// Produce a stack frame for this *Error.
 		stack = append(stack, StackFrame{
-			Kind:    string(e.Kind),
-			Message: e.Message,
-			Fields:  e.Fields,
-			Caller:  e.caller,
-			File:    e.file,
-			Line:    e.line,
+			Kind:     string(e.Kind),
+			Message:  e.text(),
+			Template: e.Template(),
+			Fields:   e.Fields,
+			Caller:   formatCaller(fullCallers, e.caller),
+			File:     normalisePath(style, e.caller, e.file),
+			Line:     e.line,
+			URL:      sourceURL(e.caller, e.file, e.line),
+			TraceID:  e.trace.TraceIDHex,
+			SpanID:   e.trace.SpanIDHex,
 		})
 
 		// Set err to the next error in the stack. If it's nil, the loop condition will break.
@@ -229,3 +313,95 @@ func Stack(err error) []StackFrame {
 
 	return stack
 }
+
+// FormatStack returns the given stack frames in the same format as the verbose (%+v) format. This
+// is useful if you have a stack that has already been produced by Stack, e.g. one that has been
+// decoded from JSON, and want to show it the same way as the error it came from would have been.
+// If the last frame has no kind, caller, or file (i.e. it's an error that isn't an *Error at the
+// bottom of the stack), it's shown with just its message.
+func FormatStack(stack []StackFrame) string {
+	var foreign bool
+	if len(stack) > 0 {
+		last := stack[len(stack)-1]
+		foreign = last.Kind == "" && last.Caller == "" && last.File == ""
+	}
+
+	return formatStack(stack, frameSources(stack), foreign)
+}
+
+// formatStack renders the given stack frames in the verbose (%+v) format, using the given source
+// file paths to find snippets. If foreign is true, the last frame is for an error that isn't an
+// *Error, so it's shown with just its message.
+func formatStack(stack []StackFrame, sources []string, foreign bool) string {
+	buf := bytes.Buffer{}
+
+	for i, frame := range stack {
+		if i == 0 {
+			buf.WriteString("Error")
+		} else {
+			buf.WriteString("Caused by")
+		}
+
+		if foreign && i == len(stack)-1 {
+			pad(&buf, ": ")
+			buf.WriteString(frame.Message)
+			break
+		}
+
+		if frame.Caller != "" {
+			pad(&buf, ": ")
+			buf.WriteString("[")
+			buf.WriteString(frame.Caller)
+			buf.WriteString("]")
+		}
+
+		if frame.Message != "" {
+			pad(&buf, ": ")
+			buf.WriteString(frame.Message)
+		}
+
+		if frame.Kind != "" {
+			pad(&buf, " ")
+			buf.WriteString("(")
+			buf.WriteString(frame.Kind)
+			buf.WriteString(")")
+		}
+
+		buf.WriteString("\n")
+		buf.WriteString("    ")
+		buf.WriteString("File: \"")
+		buf.WriteString(frame.File)
+		buf.WriteString("\", line ")
+		buf.WriteString(strconv.Itoa(frame.Line))
+		buf.WriteString("\n")
+
+		if DevelopmentMode() {
+			if snippet := sourceSnippet(sources[i], frame.Line); snippet != nil {
+				writeSnippet(&buf, snippet, "    ", noPaint)
+			}
+		}
+
+		if len(frame.Fields) > 0 {
+			buf.WriteString("    ")
+			buf.WriteString("With fields:\n")
+
+			fieldKeys := make([]string, 0, len(frame.Fields))
+			for k := range frame.Fields {
+				fieldKeys = append(fieldKeys, k)
+			}
+
+			sort.Strings(fieldKeys)
+
+			for _, k := range fieldKeys {
+				buf.WriteString("    ")
+				buf.WriteString("- \"")
+				buf.WriteString(k)
+				buf.WriteString("\": ")
+				buf.WriteString(fmt.Sprintf("%v", frame.Fields[k]))
+				buf.WriteString("\n")
+			}
+		}
+	}
+
+	return buf.String()
+}
@@ -3,6 +3,8 @@ package errors
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -96,6 +98,18 @@ func TestFatal(t *testing.T) {
 
 		Fatal(Wrap(Wrap(New(message1), message2), message3))
 	})
+
+	t.Run("error should not contain colour codes", func(t *testing.T) {
+		defer func() {
+			str, ok := recover().(string)
+			require.True(t, ok)
+
+			assert.NotContains(t, str, "\x1b[")
+			assert.Contains(t, str, "File: ")
+		}()
+
+		Fatal(New(Kind("utils test: fatal"), "oops"))
+	})
 }
 
 func TestFields(t *testing.T) {
@@ -297,6 +311,12 @@ func TestIs(t *testing.T) {
 		err = Wrap(context.Canceled)
 		assert.True(t, Is(err, context.Canceled))
 	})
+
+	t.Run("should look through errors from other packages", func(t *testing.T) {
+		err := &url.Error{Op: "Get", URL: "http://example.com", Err: Wrap(New(kind1))}
+		assert.True(t, Is(err, kind1))
+		assert.False(t, Is(err, kind2))
+	})
 }
 
 func TestMessage(t *testing.T) {
@@ -366,3 +386,68 @@ func TestStack(t *testing.T) {
 		assert.Len(t, stack[1].Fields, 2)
 	})
 }
+
+func TestFormatStack(t *testing.T) {
+	t.Run("should match the verbose format", func(t *testing.T) {
+		errs := []error{
+			New("oops"),
+			New(ErrKindTest),
+			Wrap(New(ErrKindTest, "layer 1").WithField("foo", "bar"), "layer 2").WithFields("a", 1, "b", 2),
+			Wrap(errors.New("plain")),
+			Wrap(Wrap(errors.New("plain"), "layer 1"), ErrKindTest),
+		}
+
+		for _, err := range errs {
+			assert.Equal(t, fmt.Sprintf("%+v", err), FormatStack(Stack(err)))
+		}
+	})
+
+	t.Run("should include snippets in development mode", func(t *testing.T) {
+		SetDevelopmentMode(true)
+		defer SetDevelopmentMode(false)
+
+		err := New("oops")
+
+		assert.Equal(t, fmt.Sprintf("%+v", err), FormatStack(Stack(err)))
+		assert.Contains(t, FormatStack(Stack(err)), "| ")
+	})
+
+	t.Run("should find snippets for errors with normalised paths", func(t *testing.T) {
+		SetDevelopmentMode(true)
+		defer SetDevelopmentMode(false)
+
+		SetPathStyle(PathModule)
+		defer SetPathStyle(PathAbsolute)
+
+		err := New("oops")
+
+		assert.Contains(t, fmt.Sprintf("%+v", err), "| ")
+	})
+
+	t.Run("should show every error in stacks built from struct literals", func(t *testing.T) {
+		err := &Error{
+			Kind:    ErrKindTest,
+			Message: "outer",
+			Fields:  map[string]interface{}{"foo": "bar"},
+			Cause: &Error{
+				Message: "inner",
+				Cause:   errors.New("plain"),
+			},
+		}
+
+		expected := "Error: outer (" + string(ErrKindTest) + ")\n" +
+			"    File: \"\", line 0\n" +
+			"    With fields:\n" +
+			"    - \"foo\": bar\n" +
+			"Caused by: inner\n" +
+			"    File: \"\", line 0\n" +
+			"Caused by: plain"
+
+		assert.Equal(t, expected, fmt.Sprintf("%+v", err))
+		assert.Equal(t, expected, FormatStack(Stack(err)))
+	})
+
+	t.Run("should return an empty string for empty stacks", func(t *testing.T) {
+		assert.Equal(t, "", FormatStack(nil))
+	})
+}